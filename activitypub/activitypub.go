@@ -1,21 +1,47 @@
 package activitypub
 
 import (
+	"bytes"
 	"context"
+	"crypto"
 	"crypto/rsa"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/exlibris-fed/exlibris/activitypub/clock"
 	"github.com/exlibris-fed/exlibris/activitypub/database"
+	"github.com/exlibris-fed/exlibris/activitypub/delivery"
+	"github.com/exlibris-fed/exlibris/activitypub/resolver"
+	"github.com/exlibris-fed/exlibris/activitypub/signature"
 	"github.com/exlibris-fed/exlibris/config"
+	"github.com/exlibris-fed/exlibris/infrastructure/actors"
+	"github.com/exlibris-fed/exlibris/infrastructure/blocks"
+	"github.com/exlibris-fed/exlibris/infrastructure/deliveries"
+	"github.com/exlibris-fed/exlibris/infrastructure/domainblocks"
+	"github.com/exlibris-fed/exlibris/infrastructure/followers"
+	"github.com/exlibris-fed/exlibris/infrastructure/following"
+	"github.com/exlibris-fed/exlibris/infrastructure/forwards"
+	"github.com/exlibris-fed/exlibris/infrastructure/keys"
+	"github.com/exlibris-fed/exlibris/infrastructure/reactions"
+	"github.com/exlibris-fed/exlibris/infrastructure/reads"
+	"github.com/exlibris-fed/exlibris/infrastructure/relays"
+	"github.com/exlibris-fed/exlibris/infrastructure/replies"
+	"github.com/exlibris-fed/exlibris/infrastructure/reviews"
+	"github.com/exlibris-fed/exlibris/infrastructure/users"
 	"github.com/exlibris-fed/exlibris/key"
 	"github.com/exlibris-fed/exlibris/model"
 
 	"github.com/go-fed/activity/pub"
+	"github.com/go-fed/activity/streams"
 	"github.com/go-fed/activity/streams/vocab"
 	"github.com/go-fed/httpsig"
 	"github.com/gorilla/mux"
@@ -23,22 +49,91 @@ import (
 )
 
 const (
+	// Software is the name exlibris identifies itself by to other servers.
+	Software = "exlibris"
+
+	// Version is the version of exlibris being run.
+	Version = "0.1.0"
+
 	// UserAgentString is used to identify exlibris in http requests.
-	UserAgentString = "exlibris-fed" // TODO version number
+	UserAgentString = Software + "/" + Version
+
+	// DeliveryTimeout is how long to wait on a remote server when delivering to or dereferencing from it.
+	DeliveryTimeout = 30 * time.Second
+)
+
+var (
+	// getHeaders are the headers signed on requests for objects on other servers.
+	getHeaders = []string{"(request-target)", "host", "date"}
+
+	// postHeaders are the headers signed on deliveries to other servers. The digest covers the body, so that it can't be swapped out.
+	postHeaders = []string{"(request-target)", "host", "date", "digest"}
 )
 
 // ActivityPub represents the federating server connection.
 type ActivityPub struct {
-	db    *database.Database
-	clock *clock.Clock
+	cfg      *config.Config
+	db       *database.Database
+	clock    *clock.Clock
+	verifier *signature.Verifier
+	queue    *delivery.Queue
+	resolver *resolver.Resolver
+	client   *http.Client
+
+	// softwares is the software other servers run, for the ones that need to be sent activities differently.
+	softwares softwareCache
+
+	// instance is the actor that represents the server itself, once it has been loaded.
+	instance instanceActor
+
+	followersRepo    *followers.Repository
+	followingRepo    *following.Repository
+	blocksRepo       *blocks.Repository
+	domainBlocksRepo *domainblocks.Repository
+	usersRepo        *users.Repository
+	readsRepo        *reads.Repository
+	reviewsRepo      *reviews.Repository
+	reactionsRepo    *reactions.Repository
+	repliesRepo      *replies.Repository
+	forwardsRepo     *forwards.Repository
+	relaysRepo       *relays.Repository
 }
 
 // New returns a new ActivityPub object.
 func New(db *gorm.DB, cfg *config.Config) *ActivityPub {
-	return &ActivityPub{
-		db:    database.New(db, cfg),
-		clock: clock.New(),
+	c := clock.New()
+	ap := &ActivityPub{
+		cfg:    cfg,
+		clock:  c,
+		client: &http.Client{Timeout: DeliveryTimeout},
+
+		softwares: softwareCache{hosts: make(map[string]hostSoftware)},
+
+		followersRepo:    followers.New(db),
+		followingRepo:    following.New(db),
+		blocksRepo:       blocks.New(db),
+		domainBlocksRepo: domainblocks.New(db),
+		usersRepo:        users.New(db),
+		readsRepo:        reads.New(db),
+		reviewsRepo:      reviews.New(db),
+		reactionsRepo:    reactions.New(db),
+		repliesRepo:      replies.New(db),
+		forwardsRepo:     forwards.New(db),
+		relaysRepo:       relays.New(db),
 	}
+	ap.db = database.New(db, cfg, ap.rejectsMedia)
+	ap.queue = delivery.New(deliveries.New(db), ap.transport, ap.rejects, c)
+	ap.resolver = resolver.New(actors.New(db), keys.New(db), ap.fetch, ap.finger, ap.purge, c)
+	ap.verifier = signature.New(keys.New(db), ap.resolver.FetchKey, c)
+	return ap
+}
+
+// Start runs the background workers that deliver activities to remote inboxes, until the context is cancelled. The instance actor is created first if it doesn't exist yet, so that other servers can look it up.
+func (ap *ActivityPub) Start(c context.Context) {
+	if _, err := ap.InstanceActor(); err != nil {
+		log.Printf("error loading the instance actor: %s", err.Error())
+	}
+	ap.queue.Start(c)
 }
 
 // NewFederatingActor creates a federating actor that can be reused by a http handler to create and send ActivityPub objects.
@@ -51,31 +146,51 @@ func (ap *ActivityPub) NewFederatingActor() pub.FederatingActor {
 	)
 }
 
-// NewStreamsHandler creates a handler that can parse and handle ActivityPub requests.
+// Send publishes an activity to a user's outbox and federates it in the background, so that the caller doesn't wait on remote servers. Recipients are resolved and a delivery is queued for each of their inboxes.
+func (ap *ActivityPub) Send(user *model.User, t vocab.Type) {
+	go func() {
+		if err := ap.send(user, t); err != nil {
+			log.Printf("error sending to outbox for %s: %s", user.Username, err.Error())
+		}
+	}()
+}
+
+// send publishes an activity to a user's outbox and queues its deliveries, waiting until it has been. Public reads are also sent to the relays that publish them.
+func (ap *ActivityPub) send(user *model.User, t vocab.Type) error {
+	c := context.WithValue(context.Background(), model.ContextKeyAuthenticatedUser, user)
+	if _, err := ap.NewFederatingActor().Send(c, user.OutboxIRI(), t); err != nil {
+		return err
+	}
+	if read, ok := t.(vocab.ActivityStreamsRead); ok && isPublic(read) {
+		return ap.publishToRelays(user, read)
+	}
+	return nil
+}
+
+// NewStreamsHandler creates a handler that can parse and handle ActivityPub requests. What it serves includes the exlibris vocabulary.
 func (ap *ActivityPub) NewStreamsHandler() pub.HandlerFunc {
-	return pub.NewActivityStreamsHandler(ap.db, ap.clock)
+	return WithVocabulary(pub.NewActivityStreamsHandler(ap.db, ap.clock))
 }
 
 // ----- Common ----- //
-// AuthenticateGetInbox determines if the request is allowed to access the inbox for a given user.
-//
-// TODO should it return an error instead of just not authenticated?
+// AuthenticateGetInbox determines if the request is allowed to access the inbox for a given user, which only they may. If not, the status will already have been written.
 func (ap *ActivityPub) AuthenticateGetInbox(c context.Context, w http.ResponseWriter, r *http.Request) (out context.Context, authenticated bool, err error) {
-	log.Println("auth get inbox")
-	// TODO http signatures
 	vars := mux.Vars(r)
 	username, ok := vars["username"]
 	if !ok {
+		w.WriteHeader(http.StatusNotFound)
 		return
 	}
-	user, ok := c.Value(model.ContextKeyAuthenticatedUser).(model.User)
+	user, ok := c.Value(model.ContextKeyAuthenticatedUser).(*model.User)
 	if !ok {
 		// not logged in at all
+		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
 	// determine if the user is accessing their own
 	if strings.ToLower(username) != strings.ToLower(user.Username) {
+		w.WriteHeader(http.StatusForbidden)
 		return
 	}
 
@@ -85,101 +200,435 @@ func (ap *ActivityPub) AuthenticateGetInbox(c context.Context, w http.ResponseWr
 	return
 }
 
+// AuthenticateGetOutbox determines if the request is allowed to access a user's outbox. Anyone may, unless secure mode is on.
 func (ap *ActivityPub) AuthenticateGetOutbox(c context.Context, w http.ResponseWriter, r *http.Request) (out context.Context, authenticated bool, err error) {
-	// TODO
-	log.Println("auth get outbox, DEFAULTING TO TRUE")
-	authenticated = true
+	out, authenticated = ap.authenticateFetch(c, w, r)
+	return
+}
+
+// AuthorizeFetch determines if the request is allowed to retrieve the object at its url, based on who the object is addressed to. go-fed leaves this up to the caller when serving objects. Requests for objects that don't exist are answered with a 404.
+func (ap *ActivityPub) AuthorizeFetch(c context.Context, w http.ResponseWriter, r *http.Request) (out context.Context, authorized bool, err error) {
+	out, authenticated := ap.authenticateFetch(c, w, r)
+	if !authenticated {
+		return
+	}
+
+	id := ap.requestIRI(r)
+	requester := Requester(out)
+	if err = ap.db.Lock(out, id); err != nil {
+		return
+	}
+	authorized, err = ap.db.CanView(out, id, requester)
+	ap.db.Unlock(out, id)
+	if errors.Is(err, database.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return out, false, nil
+	} else if err != nil || authorized {
+		return
+	}
+
+	if requester == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+	} else {
+		w.WriteHeader(http.StatusForbidden)
+	}
 	return
 }
 
+// authenticateFetch identifies who is making a GET request, from either a logged in user or the HTTP Signature of a remote actor. Anonymous requests are allowed unless secure mode is on, but a bad signature is always rejected. If the request is not authenticated the status will already have been written.
+func (ap *ActivityPub) authenticateFetch(c context.Context, w http.ResponseWriter, r *http.Request) (context.Context, bool) {
+	if _, ok := c.Value(model.ContextKeyAuthenticatedUser).(*model.User); ok {
+		return c, true
+	}
+
+	k, err := ap.verifier.Verify(r)
+	if err == nil {
+		if owner, err := url.Parse(k.Owner); err == nil {
+			return context.WithValue(c, model.ContextKeySignedBy, owner), true
+		}
+	} else if !errors.Is(err, signature.ErrUnsigned) {
+		log.Printf("rejecting fetch of %s: %s", r.URL.Path, err.Error())
+		w.WriteHeader(signature.StatusCode(err))
+		return c, false
+	}
+
+	if ap.cfg.SecureMode {
+		w.WriteHeader(http.StatusUnauthorized)
+		return c, false
+	}
+	return c, true
+}
+
+// Requester returns the IRI of the actor making a request, either a logged in user or a remote actor who signed it. It returns nil if the request is anonymous.
+func Requester(c context.Context) *url.URL {
+	if user, ok := c.Value(model.ContextKeyAuthenticatedUser).(*model.User); ok {
+		return user.IRI()
+	}
+	if owner, ok := c.Value(model.ContextKeySignedBy).(*url.URL); ok {
+		return owner
+	}
+	return nil
+}
+
+// GetOutbox returns the page of a user's outbox asked for in the request, as seen by whoever is making it. AuthenticateGetOutbox has already been called.
 func (ap *ActivityPub) GetOutbox(c context.Context, r *http.Request) (vocab.ActivityStreamsOrderedCollectionPage, error) {
-	// TODO
-	log.Println("get outbox")
-	return nil, nil
+	p, _ := database.PageFromQuery(r.URL.Query())
+	return ap.db.OutboxPage(c, ap.requestIRI(r), p, Requester(c))
+}
+
+// GetOutboxCollection serves the OrderedCollection of a user's outbox for ActivityPub requests that aren't for a specific page, which go-fed doesn't handle. It returns false if the request should be passed on to go-fed or the caller.
+func (ap *ActivityPub) GetOutboxCollection(c context.Context, w http.ResponseWriter, r *http.Request) (handled bool, err error) {
+	if !IsActivityPubRequest(r) {
+		return
+	}
+	if _, ok := database.PageFromQuery(r.URL.Query()); ok {
+		return
+	}
+
+	handled = true
+	c, authenticated, err := ap.AuthenticateGetOutbox(c, w, r)
+	if err != nil || !authenticated {
+		return
+	}
+	collection, err := ap.db.OutboxCollection(c, ap.requestIRI(r))
+	if err != nil {
+		return
+	}
+	err = writeActivityStreams(w, collection)
+	return
+}
+
+// GetInboxCollection serves the OrderedCollection of a user's inbox for ActivityPub requests that aren't for a specific page, which go-fed doesn't handle. It returns false if the request should be passed on to go-fed or the caller.
+func (ap *ActivityPub) GetInboxCollection(c context.Context, w http.ResponseWriter, r *http.Request) (handled bool, err error) {
+	if !IsActivityPubRequest(r) {
+		return
+	}
+	if _, ok := database.PageFromQuery(r.URL.Query()); ok {
+		return
+	}
+
+	handled = true
+	c, authenticated, err := ap.AuthenticateGetInbox(c, w, r)
+	if err != nil || !authenticated {
+		return
+	}
+	collection, err := ap.db.InboxCollection(c, ap.requestIRI(r))
+	if err != nil {
+		return
+	}
+	err = writeActivityStreams(w, collection)
+	return
+}
+
+// IsActivityPubRequest determines whether a GET request is asking for ActivityStreams rather than a webpage.
+func IsActivityPubRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	for _, accept := range r.Header.Values("Accept") {
+		if strings.Contains(accept, "application/activity+json") || strings.Contains(accept, "application/ld+json") {
+			return true
+		}
+	}
+	return false
+}
+
+// PrefersHTML determines whether a GET request would rather have a webpage than ActivityStreams, going by the quality the Accept header gives each. ActivityStreams wins ties, so that clients that accept anything, or don't say, get what other servers expect.
+func PrefersHTML(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	accept := strings.Join(r.Header.Values("Accept"), ",")
+	html := acceptQuality(accept, "text/html")
+	activityStreams := acceptQuality(accept, "application/activity+json")
+	if q := acceptQuality(accept, "application/ld+json"); q > activityStreams {
+		activityStreams = q
+	}
+	return html > activityStreams
 }
 
+// acceptQuality returns the quality an Accept header gives a media type, from the most specific range that matches it. It's 0 if none do.
+func acceptQuality(accept, mediaType string) float64 {
+	quality, specificity := 0.0, -1
+	for _, mediaRange := range strings.Split(accept, ",") {
+		t, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		var s int
+		switch {
+		case t == mediaType:
+			s = 2
+		case t == strings.SplitN(mediaType, "/", 2)[0]+"/*":
+			s = 1
+		case t == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s < specificity {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if s > specificity || q > quality {
+			quality, specificity = q, s
+		}
+	}
+	return quality
+}
+
+// requestIRI returns the IRI of the local object a request is for, without its query string.
+func (ap *ActivityPub) requestIRI(r *http.Request) *url.URL {
+	return &url.URL{
+		Scheme: ap.cfg.Scheme,
+		Host:   ap.cfg.Domain,
+		Path:   r.URL.Path,
+	}
+}
+
+// writeActivityStreams serializes an ActivityStreams value, along with the exlibris vocabulary, as the response to a request.
+func writeActivityStreams(w http.ResponseWriter, t vocab.Type) error {
+	m, err := streams.Serialize(t)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(model.WithVocabulary(m))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/activity+json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(b)
+	return err
+}
+
+// NewTransport returns a transport for the user in the context, or the instance actor outside of a user's request. Deliveries are queued to be sent in the background with the exlibris vocabulary added, and in the form BookWyrm understands to BookWyrm servers, and actors are dereferenced through the cache.
 func (ap *ActivityPub) NewTransport(c context.Context, actorBoxIRI *url.URL, gofedAgent string) (t pub.Transport, err error) {
-	userI := c.Value(model.ContextKeyAuthenticatedUser)
-	if userI == nil {
-		return nil, fmt.Errorf("no authenticated user in context")
+	user, err := ap.signingUser(c)
+	if err != nil {
+		return
 	}
-	user, ok := userI.(*model.User)
-	if !ok {
-		return nil, fmt.Errorf("can't cast to user")
+
+	signed, err := ap.transport(user)
+	if err != nil {
+		return
 	}
+	t = vocabularyTransport{bookwyrmTransport{ap.queue.Transport(user, ap.resolver.Transport(signed)), ap.isBookWyrm}}
+	return
+}
 
+// transport returns a transport that signs requests as the user and sends them immediately. Requests are signed with the user's current key, which other servers find in their actor.
+func (ap *ActivityPub) transport(user *model.User) (pub.Transport, error) {
 	pk, err := key.DeserializeRSAPrivateKey(user.PrivateKey)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	t = pub.NewHttpSigTransport(
-		&http.Client{},
-		gofedAgent+"/"+UserAgentString,
+	return pub.NewHttpSigTransport(
+		delivery.Client{HttpClient: &http.Client{Timeout: DeliveryTimeout}},
+		UserAgentString,
 		ap.clock,
-		ap.signer([]string{}), // TODO headers
-		ap.signer([]string{}), // TODO headers
-		"",                    // TODO THIS NEEDS TO BE A PATH TO A PUBLIC KEY (ie /keys/%s)
+		ap.signer(getHeaders),
+		ap.signer(postHeaders),
+		user.PublicKeyID(),
 		pk.(*rsa.PrivateKey),
-	)
-	return
+	), nil
 }
 
+// signer returns an HTTP Signatures signer covering the given headers. The signature is sent in the Signature header, which is the only one Mastodon looks at.
 func (ap *ActivityPub) signer(headers []string) httpsig.Signer {
 	signer, _, err := httpsig.NewSigner(
 		[]httpsig.Algorithm{httpsig.RSA_SHA256},
 		httpsig.DigestSha256,
 		headers,
-		httpsig.Authorization,
+		httpsig.Signature,
 		60,
 	)
 	if err != nil {
 		log.Println("error creating signer: " + err.Error())
 	}
-	return signer
+	return hostSigner{signer}
+}
+
+// hostSigner adds the Host header to requests before signing them. go-fed leaves it to the http client, but it has to be in the request to be signed.
+type hostSigner struct {
+	httpsig.Signer
+}
+
+// SignRequest signs a request, including its Host header.
+func (s hostSigner) SignRequest(pKey crypto.PrivateKey, pubKeyID string, r *http.Request, body []byte) error {
+	if r.Header.Get("Host") == "" {
+		r.Header.Set("Host", r.URL.Host)
+	}
+	return s.Signer.SignRequest(pKey, pubKeyID, r, body)
 }
 
 // ----- Federating ----- //
+// PostInboxRequestBodyHook adds the owner of the inbox being delivered to to the context, so that their blocks can be checked.
 func (ap *ActivityPub) PostInboxRequestBodyHook(c context.Context, r *http.Request, activity pub.Activity) (context.Context, error) {
-	// TODO
-	log.Println("post inbox req body hook")
-	// This is to make your life easier in the HTTP request-handling cycle. This is a hook for you in case you need to set up context.Context based on the http.Request after the body has been read and interpreted, but before it has been checked for authorization, blocks, etc.
-	return c, nil
+	owner, err := ap.db.InboxOwner(c, r.URL)
+	if err != nil || owner == nil {
+		return c, err
+	}
+	return context.WithValue(c, model.ContextKeyInboxOwner, owner), nil
 }
 
+// AuthenticatePostInbox verifies the HTTP Signature of a delivery to an inbox, and that the key used to sign it belongs to the actor of the activity being delivered. Activities signed by someone else, such as ones forwarded by another server, are fetched from the actor's server instead. On success the actor's IRI is added to the context under model.ContextKeySignedBy.
 func (ap *ActivityPub) AuthenticatePostInbox(c context.Context, w http.ResponseWriter, r *http.Request) (out context.Context, authenticated bool, err error) {
-	// TODO
-	log.Println("auth post inbox, DEFAULTING TO TRUE")
+	// deliveries to the shared inbox have already been verified by the time they're handed to go-fed
+	if _, ok := c.Value(model.ContextKeySignedBy).(*url.URL); ok {
+		return c, true, nil
+	}
+
+	k, verifyErr := ap.verifier.Verify(r)
+	if verifyErr != nil {
+		log.Printf("rejecting delivery to %s: %s", r.URL.Path, verifyErr.Error())
+		w.WriteHeader(signature.StatusCode(verifyErr))
+		return
+	}
+
+	actor, actorErr := actorFromBody(r)
+	if actorErr != nil {
+		log.Printf("rejecting delivery to %s: %s", r.URL.Path, actorErr.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if actor != k.Owner {
+		if fetchErr := ap.dereferenceForwarded(c, r, actor); fetchErr != nil {
+			log.Printf("rejecting delivery to %s: signed by %s on behalf of %s: %s", r.URL.Path, k.Owner, actor, fetchErr.Error())
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	owner, parseErr := url.Parse(actor)
+	if parseErr != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	out = context.WithValue(c, model.ContextKeySignedBy, owner)
 	authenticated = true
 	return
 }
 
-func (ap *ActivityPub) Blocked(c context.Context, actorIRIs []*url.URL) (blocked bool, err error) {
-	// TODO
-	log.Println("blocked, DEFAULTING TO FALSE")
-	return
+// dereferenceForwarded replaces the body of a delivery signed by someone other than the activity's actor, such as a server forwarding a reply to its followers, with the activity fetched from the actor's server. The signature only vouches for whoever forwarded it, so the delivered activity can't be trusted.
+func (ap *ActivityPub) dereferenceForwarded(c context.Context, r *http.Request, actor string) error {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	var activity struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(b, &activity); err != nil {
+		return err
+	}
+	id, err := url.Parse(activity.ID)
+	if err != nil {
+		return err
+	}
+	actorIRI, err := url.Parse(actor)
+	if err != nil {
+		return err
+	}
+	if id.Host == "" || !strings.EqualFold(id.Host, actorIRI.Host) {
+		return fmt.Errorf("%s isn't on the server of its actor", id)
+	}
+	if ap.rejects(id.Hostname()) {
+		return fmt.Errorf("the server of %s is rejected", id)
+	}
+
+	if b, err = ap.fetch(c, id); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &activity); err != nil {
+		return err
+	}
+	if activity.ID != id.String() {
+		return fmt.Errorf("fetching %s returned %s", id, activity.ID)
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if fetched, err := actorFromBody(r); err != nil {
+		return err
+	} else if fetched != actor {
+		return fmt.Errorf("%s was fetched with the actor %s", id, fetched)
+	}
+	return nil
+}
+
+// actorFromBody returns the id of the actor of the activity in a request body, leaving the body intact to be read again.
+func actorFromBody(r *http.Request) (string, error) {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	var activity struct {
+		Actor json.RawMessage `json:"actor"`
+	}
+	if err := json.Unmarshal(b, &activity); err != nil {
+		return "", err
+	}
+
+	var id string
+	if err := json.Unmarshal(activity.Actor, &id); err == nil {
+		return id, nil
+	}
+	var actor struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(activity.Actor, &actor); err != nil || actor.ID == "" {
+		return "", fmt.Errorf("activity has no actor")
+	}
+	return actor.ID, nil
 }
 
 func (ap *ActivityPub) FederatingCallbacks(c context.Context) (wrapped pub.FederatingWrappedCallbacks, other []interface{}, err error) {
 	other = []interface{}{
-		func(c context.Context, flag vocab.ActivityStreamsRead) error {
-			log.Println("its happening!!!!")
-			return nil
-		},
+		ap.onRead,
+		ap.onFollow,
+		ap.onAccept,
+		ap.onReject,
+		ap.onUndo,
+		ap.onBlock,
+		ap.onLike,
+		ap.onAnnounce,
 	}
 	return
 }
 
+// onRead saves a read delivered from another server. go-fed has usually already saved it while considering it for inbox forwarding, in which case there's nothing left to do.
+func (ap *ActivityPub) onRead(c context.Context, read vocab.ActivityStreamsRead) error {
+	id, err := pub.GetId(read)
+	if err != nil {
+		return err
+	}
+	if err := ap.db.Lock(c, id); err != nil {
+		return err
+	}
+	defer ap.db.Unlock(c, id)
+	if exists, err := ap.db.Exists(c, id); err != nil || exists {
+		return err
+	}
+	return ap.db.Create(c, read)
+}
+
 func (ap *ActivityPub) DefaultCallback(c context.Context, activity pub.Activity) error {
 	// TODO
 	log.Println("default cb")
 	return nil
 }
 
+// MaxInboxForwardingRecursionDepth is how deep go-fed looks through the objects of an activity, and what they reply to, for something of ours before it considers forwarding the activity. A reply to a reply to a review is found within a few levels, and going further would let anyone make us dereference long chains.
 func (ap *ActivityPub) MaxInboxForwardingRecursionDepth(c context.Context) int {
-	// TODO
-	log.Println("max inbox fwd recursion")
-	return 25
+	return maxForwardingDepth
 }
 
 func (ap *ActivityPub) MaxDeliveryRecursionDepth(c context.Context) int {
@@ -189,16 +638,16 @@ func (ap *ActivityPub) MaxDeliveryRecursionDepth(c context.Context) int {
 	return depth
 }
 
+// FilterForwarding is called by go-fed with the local collections an activity from another server is addressed to, once it has found that the activity concerns something of ours (https://www.w3.org/TR/activitypub/#inbox-forwarding). The activity is forwarded here, on behalf of the author of the review it's about, so no collections are returned for go-fed to deliver to itself. Forwarding is best effort: failing it doesn't fail the delivery to us.
 func (ap *ActivityPub) FilterForwarding(c context.Context, potentialRecipients []*url.URL, a pub.Activity) (filteredRecipients []*url.URL, err error) {
-	// TODO
-	// see https://www.w3.org/TR/activitypub/#inbox-forwarding
-	log.Println("filter forwarding")
+	if forwardErr := ap.forward(c, potentialRecipients, a); forwardErr != nil {
+		log.Printf("error forwarding %s: %s", a.GetJSONLDId().Get(), forwardErr.Error())
+	}
 	return
 }
 
-// GetInbox retrieves a user's inbox. AuthenticateGetInbox already verified that the authenticated user exists and is accessing their own profile.
+// GetInbox returns the page of a user's inbox asked for in the request. AuthenticateGetInbox already verified that the authenticated user exists and is accessing their own inbox.
 func (ap *ActivityPub) GetInbox(c context.Context, r *http.Request) (vocab.ActivityStreamsOrderedCollectionPage, error) {
-	// TODO
-	log.Println("get inbox")
-	return ap.db.GetInbox(c, r.URL)
+	p, _ := database.PageFromQuery(r.URL.Query())
+	return ap.db.InboxPage(c, ap.requestIRI(r), p)
 }
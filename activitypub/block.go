@@ -0,0 +1,224 @@
+package activitypub
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/exlibris-fed/exlibris/infrastructure/blocks"
+	"github.com/exlibris-fed/exlibris/infrastructure/domainblocks"
+	"github.com/exlibris-fed/exlibris/infrastructure/followers"
+	"github.com/exlibris-fed/exlibris/infrastructure/following"
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+)
+
+// Block stops an actor interacting with a local user. They stop following each other, and if the actor is on another server it is sent a Block so that it can hide the user from them too.
+func (ap *ActivityPub) Block(user *model.User, actorIRI *url.URL) (*model.Block, error) {
+	b, err := ap.blocksRepo.Get(user, actorIRI.String())
+	if errors.Is(err, blocks.ErrNotFound) {
+		b = &model.Block{
+			ID:      actorIRI.String(),
+			User:    *user,
+			UserID:  user.ID,
+			BlockID: ap.newActivityIRI(user, "block").String(),
+		}
+		err = ap.blocksRepo.Save(b)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ap.sever(user, actorIRI); err != nil {
+		return nil, err
+	}
+
+	if local, err := ap.db.LocalUser(context.Background(), actorIRI); err != nil {
+		return nil, err
+	} else if local == nil {
+		ap.Send(user, b.ToType())
+	}
+	return b, nil
+}
+
+// Unblock lets an actor interact with a local user again. They don't follow each other again until they ask to.
+func (ap *ActivityPub) Unblock(user *model.User, actorIRI *url.URL) error {
+	b, err := ap.blocksRepo.Get(user, actorIRI.String())
+	if err != nil {
+		return err
+	}
+	if err := ap.blocksRepo.Delete(b); err != nil {
+		return err
+	}
+
+	if local, err := ap.db.LocalUser(context.Background(), actorIRI); err != nil {
+		return err
+	} else if local == nil {
+		ap.Send(user, ap.address(streams.NewActivityStreamsUndo(), user, actorIRI, b.ToType()))
+	}
+	return nil
+}
+
+// sever removes any follows between a local user and an actor, in either direction.
+func (ap *ActivityPub) sever(user *model.User, actorIRI *url.URL) error {
+	if f, err := ap.followersRepo.Get(user, actorIRI.String()); err == nil {
+		if err := ap.followersRepo.Delete(f); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, followers.ErrNotFound) {
+		return err
+	}
+
+	if f, err := ap.followingRepo.Get(user, actorIRI.String()); err == nil {
+		if err := ap.followingRepo.Delete(f); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, following.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// BlockDomain limits federation with another server. Rejecting a server also removes every follow between its actors and local users.
+func (ap *ActivityPub) BlockDomain(block *model.DomainBlock) error {
+	block.Domain = strings.ToLower(block.Domain)
+	if err := ap.domainBlocksRepo.Save(block); err != nil {
+		return err
+	}
+	if block.Level != model.DomainBlockReject {
+		return nil
+	}
+	if err := ap.followersRepo.DeleteByDomain(block.Domain); err != nil {
+		return err
+	}
+	return ap.followingRepo.DeleteByDomain(block.Domain)
+}
+
+// UnblockDomain federates with another server normally again.
+func (ap *ActivityPub) UnblockDomain(domain string) error {
+	block, err := ap.domainBlocksRepo.Get(strings.ToLower(domain))
+	if err != nil {
+		return err
+	}
+	return ap.domainBlocksRepo.Delete(block)
+}
+
+// domainBlock returns the level a host is blocked at, or an empty string if it isn't. Blocking a domain also blocks its subdomains.
+func (ap *ActivityPub) domainBlock(host string) (string, error) {
+	host = strings.ToLower(host)
+	for {
+		block, err := ap.domainBlocksRepo.Get(host)
+		if err == nil {
+			return block.Level, nil
+		} else if !errors.Is(err, domainblocks.ErrNotFound) {
+			return "", err
+		}
+
+		i := strings.Index(host, ".")
+		if i < 0 {
+			return "", nil
+		}
+		host = host[i+1:]
+	}
+}
+
+// rejects returns whether nothing is to be delivered to or accepted from a host.
+func (ap *ActivityPub) rejects(host string) bool {
+	level, err := ap.domainBlock(host)
+	if err != nil {
+		log.Printf("error looking up domain block of %s: %s", host, err.Error())
+		return false
+	}
+	return level == model.DomainBlockReject
+}
+
+// rejectsMedia returns whether media attached to activities from a host, such as book covers, is to be left out.
+func (ap *ActivityPub) rejectsMedia(host string) bool {
+	level, err := ap.domainBlock(host)
+	if err != nil {
+		log.Printf("error looking up domain block of %s: %s", host, err.Error())
+		return false
+	}
+	return level == model.DomainBlockReject || level == model.DomainBlockMediaReject
+}
+
+// Hides determines whether content by an actor should be hidden from a viewer, who is nil if they're not logged in. Content is hidden if the viewer has blocked the actor, or the actor's server is rejected, or it is silenced and the viewer doesn't follow them.
+func (ap *ActivityPub) Hides(viewer *model.User, actorIRI *url.URL) (bool, error) {
+	if viewer != nil {
+		if blocked, err := ap.blocksRepo.Blocks(viewer, actorIRI.String()); err != nil || blocked {
+			return blocked, err
+		}
+	}
+
+	level, err := ap.domainBlock(actorIRI.Hostname())
+	if err != nil {
+		return false, err
+	}
+	switch level {
+	case model.DomainBlockReject:
+		return true, nil
+	case model.DomainBlockSilence:
+		if viewer == nil {
+			return true, nil
+		}
+		f, err := ap.followingRepo.Get(viewer, actorIRI.String())
+		if errors.Is(err, following.ErrNotFound) {
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+		return !f.Accepted, nil
+	}
+	return false, nil
+}
+
+// Blocked determines whether an activity involving any of the actors should be refused. Activities are refused from servers that are rejected, and from actors blocked by the owner of the inbox they're delivered to.
+func (ap *ActivityPub) Blocked(c context.Context, actorIRIs []*url.URL) (blocked bool, err error) {
+	owner, _ := c.Value(model.ContextKeyInboxOwner).(*model.User)
+	for _, iri := range actorIRIs {
+		level, err := ap.domainBlock(iri.Hostname())
+		if err != nil {
+			return false, err
+		}
+		if level == model.DomainBlockReject {
+			log.Printf("refusing activity from %s, whose server is rejected", iri)
+			return true, nil
+		}
+
+		if owner == nil {
+			continue
+		}
+		if blocked, err := ap.blocksRepo.Blocks(owner, iri.String()); err != nil {
+			return false, err
+		} else if blocked {
+			log.Printf("refusing activity from %s, who %s has blocked", iri, owner.Username)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// onBlock handles an actor blocking local users, by removing any follows between them.
+func (ap *ActivityPub) onBlock(c context.Context, block vocab.ActivityStreamsBlock) error {
+	actor, err := firstActor(block)
+	if err != nil {
+		return err
+	}
+	for _, object := range objectIRIs(block) {
+		user, err := ap.db.LocalUser(c, object)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			continue
+		}
+		if err := ap.sever(user, actor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
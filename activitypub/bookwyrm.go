@@ -0,0 +1,183 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/exlibris-fed/exlibris/activitypub/bookwyrm"
+	"github.com/exlibris-fed/exlibris/dto"
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/pub"
+)
+
+const (
+	// SoftwareTTL is how long to remember which software another server runs.
+	SoftwareTTL = 24 * time.Hour
+
+	// nodeInfoSchema is the rel of NodeInfo discovery links, which is followed by the schema version.
+	nodeInfoSchema = "http://nodeinfo.diaspora.software/ns/schema/2."
+)
+
+// PostInbox handles a delivery to a user's inbox. Activities from BookWyrm are translated into ours before go-fed handles them. It returns false if the request isn't an ActivityPub delivery.
+func (ap *ActivityPub) PostInbox(c context.Context, w http.ResponseWriter, r *http.Request) (handled bool, err error) {
+	if !IsActivityPubPost(r) {
+		return
+	}
+
+	handled = true
+	c, authenticated, err := ap.AuthenticatePostInbox(c, w, r)
+	if err != nil || !authenticated {
+		return
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	c = context.WithValue(c, model.ContextKeyDelivery, b)
+	b, ok, err := ap.translateBookWyrm(c, w, b)
+	if err != nil || !ok {
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return ap.NewFederatingActor().PostInbox(c, w, r)
+}
+
+// translateBookWyrm translates an activity delivered from BookWyrm into ours, returning anything else as it is. Activities about books that can't be matched to OpenLibrary are accepted and dropped, in which case it returns false.
+func (ap *ActivityPub) translateBookWyrm(c context.Context, w http.ResponseWriter, b []byte) ([]byte, bool, error) {
+	var activity map[string]interface{}
+	if err := json.Unmarshal(b, &activity); err != nil {
+		// leave it to go-fed to reject
+		return b, true, nil
+	}
+	translated, err := bookwyrm.Translate(c, activity, ap.fetch)
+	if errors.Is(err, bookwyrm.ErrUnknownBook) {
+		log.Printf("ignoring %v: %s", activity["id"], err)
+		w.WriteHeader(http.StatusAccepted)
+		return nil, false, nil
+	} else if err != nil || !translated {
+		return b, err == nil, err
+	}
+	b, err = json.Marshal(activity)
+	return b, err == nil, err
+}
+
+// bookwyrmTransport sends activities to inboxes on BookWyrm servers in the form BookWyrm understands, and to everyone else as they are.
+type bookwyrmTransport struct {
+	pub.Transport
+	isBookWyrm func(c context.Context, inbox *url.URL) bool
+}
+
+// Deliver delivers an activity to a single inbox.
+func (t bookwyrmTransport) Deliver(c context.Context, b []byte, to *url.URL) error {
+	return t.BatchDeliver(c, b, []*url.URL{to})
+}
+
+// BatchDeliver delivers an activity to each of the inboxes, rewriting it for the ones on BookWyrm servers.
+func (t bookwyrmTransport) BatchDeliver(c context.Context, b []byte, recipients []*url.URL) error {
+	var others, bookwyrms []*url.URL
+	for _, inbox := range recipients {
+		if t.isBookWyrm(c, inbox) {
+			bookwyrms = append(bookwyrms, inbox)
+		} else {
+			others = append(others, inbox)
+		}
+	}
+	if len(bookwyrms) == 0 {
+		return t.Transport.BatchDeliver(c, b, recipients)
+	}
+
+	var activity map[string]interface{}
+	if err := json.Unmarshal(b, &activity); err != nil {
+		return err
+	}
+	if activity, rewritten := bookwyrm.Outgoing(activity); rewritten {
+		translated, err := json.Marshal(activity)
+		if err != nil {
+			return err
+		}
+		if err := t.Transport.BatchDeliver(c, translated, bookwyrms); err != nil {
+			return err
+		}
+	} else {
+		others = recipients
+	}
+	return t.Transport.BatchDeliver(c, b, others)
+}
+
+// softwareCache remembers which software other servers run, so that NodeInfo only has to be fetched once a day for each.
+type softwareCache struct {
+	mu    sync.Mutex
+	hosts map[string]hostSoftware
+}
+
+type hostSoftware struct {
+	name      string
+	fetchedAt time.Time
+}
+
+// isBookWyrm returns whether the server an inbox is on runs BookWyrm.
+func (ap *ActivityPub) isBookWyrm(c context.Context, inbox *url.URL) bool {
+	return ap.software(c, inbox) == bookwyrm.Software
+}
+
+// software returns the name of the software the server of a URL runs, as given in its NodeInfo. Servers whose NodeInfo can't be fetched are taken to run nothing in particular until it is tried again.
+func (ap *ActivityPub) software(c context.Context, u *url.URL) string {
+	now := ap.clock.Now()
+	ap.softwares.mu.Lock()
+	cached, ok := ap.softwares.hosts[u.Host]
+	ap.softwares.mu.Unlock()
+	if ok && now.Sub(cached.fetchedAt) < SoftwareTTL {
+		return cached.name
+	}
+
+	name, err := ap.nodeInfoSoftware(c, u)
+	if err != nil {
+		log.Printf("error fetching NodeInfo of %s: %s", u.Host, err)
+	}
+	ap.softwares.mu.Lock()
+	ap.softwares.hosts[u.Host] = hostSoftware{name: name, fetchedAt: now}
+	ap.softwares.mu.Unlock()
+	return name
+}
+
+// nodeInfoSoftware fetches the NodeInfo of the server of a URL and returns the name of its software.
+func (ap *ActivityPub) nodeInfoSoftware(c context.Context, u *url.URL) (string, error) {
+	discovery := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/.well-known/nodeinfo"}
+	b, err := ap.get(c, discovery, "application/json", false)
+	if err != nil {
+		return "", err
+	}
+	var links dto.NodeInfoLinks
+	if err := json.Unmarshal(b, &links); err != nil {
+		return "", err
+	}
+	for _, link := range links.Links {
+		if !strings.HasPrefix(link.Rel, nodeInfoSchema) {
+			continue
+		}
+		href, err := url.Parse(link.Href)
+		if err != nil || href.Host != u.Host {
+			continue
+		}
+		if b, err = ap.get(c, href, "application/json", false); err != nil {
+			return "", err
+		}
+		var nodeInfo dto.NodeInfo
+		if err := json.Unmarshal(b, &nodeInfo); err != nil {
+			return "", err
+		}
+		return strings.ToLower(nodeInfo.Software.Name), nil
+	}
+	return "", errors.New("no NodeInfo 2.x document")
+}
@@ -0,0 +1,103 @@
+// Package bookwyrm translates between the ActivityPub BookWyrm speaks and ours. BookWyrm describes books with types of its own, Edition and Work, and talks about them with Review, Comment and Quotation statuses, none of which go-fed knows. Activities from BookWyrm servers are rewritten into the Documents, Notes and Reads we use before go-fed sees them, and the activities we send to BookWyrm servers are rewritten the other way, since BookWyrm ignores Notes that don't reply to or mention one of its users.
+package bookwyrm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+const (
+	// Software is the name BookWyrm servers give in their NodeInfo.
+	Software = "bookwyrm"
+
+	// The types BookWyrm uses which ActivityStreams doesn't have. Review, ReviewRating, Comment and Quotation are all kinds of Note.
+	TypeEdition      = "Edition"
+	TypeWork         = "Work"
+	TypeAuthor       = "Author"
+	TypeReview       = "Review"
+	TypeReviewRating = "ReviewRating"
+	TypeComment      = "Comment"
+	TypeQuotation    = "Quotation"
+	TypeShelfItem    = "ShelfItem"
+
+	// ReadShelf is the shelf BookWyrm users put the books they've finished on.
+	ReadShelf = "read"
+
+	activityStreamsContext = "https://www.w3.org/ns/activitystreams"
+)
+
+var (
+	// ErrUnknownBook is returned for books which can't be matched to an OpenLibrary work, which we need to store them.
+	ErrUnknownBook = errors.New("book is not on OpenLibrary")
+
+	regexpWorkKey    = regexp.MustCompile("^OL[0-9]+W$")
+	regexpEditionKey = regexp.MustCompile("^OL[0-9]+M$")
+	regexpAuthorKey  = regexp.MustCompile("^OL[0-9]+A$")
+)
+
+// A Fetcher dereferences an IRI on a BookWyrm server.
+type Fetcher func(c context.Context, iri *url.URL) ([]byte, error)
+
+// fetchObject dereferences an object and checks that it's the one asked for, so that a server can't speak for objects on another.
+func fetchObject(c context.Context, iri string, fetch Fetcher) (map[string]interface{}, error) {
+	u, err := url.Parse(iri)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an IRI", iri)
+	}
+	b, err := fetch(c, u)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(b, &object); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", iri, err)
+	}
+	if id, _ := object["id"].(string); id != iri {
+		return nil, fmt.Errorf("fetching %s returned %q instead", iri, id)
+	}
+	return object, nil
+}
+
+// id returns the id of a property which is either an IRI or an object.
+func id(property interface{}) string {
+	switch p := property.(type) {
+	case string:
+		return p
+	case map[string]interface{}:
+		id, _ := p["id"].(string)
+		return id
+	}
+	return ""
+}
+
+// stringList returns the strings in a property which is either a single string or an array.
+func stringList(property interface{}) []string {
+	switch p := property.(type) {
+	case string:
+		return []string{p}
+	case []interface{}:
+		var values []string
+		for _, v := range p {
+			if s, ok := v.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// list returns a property as an array, which is how BookWyrm expects addressing to be.
+func list(property interface{}) []interface{} {
+	switch p := property.(type) {
+	case nil:
+		return []interface{}{}
+	case []interface{}:
+		return p
+	}
+	return []interface{}{property}
+}
@@ -0,0 +1,217 @@
+package bookwyrm
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	regexpParagraphEnd = regexp.MustCompile(`(?i)</p>\s*`)
+	regexpLineBreak    = regexp.MustCompile(`(?i)<br\s*/?>`)
+	regexpTag          = regexp.MustCompile(`<[^>]*>`)
+)
+
+// Translate rewrites an activity from a BookWyrm server into the form we use, fetching the books it refers to. Creates and Updates of statuses about a book become Notes with the book tagged as a Document, and adding a book to the read shelf becomes a Read of it. It returns false, and leaves the activity as it is, if it isn't one of those. ErrUnknownBook is returned for books which can't be matched to an OpenLibrary work.
+func Translate(c context.Context, activity map[string]interface{}, fetch Fetcher) (bool, error) {
+	switch activity["type"] {
+	case "Create", "Update":
+		status, ok := activity["object"].(map[string]interface{})
+		if !ok || !isStatus(status) {
+			return false, nil
+		}
+		return true, translateStatus(c, status, fetch)
+	case "Add":
+		if !isReadShelf(id(activity["target"])) {
+			return false, nil
+		}
+		return translateShelving(c, activity, fetch)
+	}
+	return false, nil
+}
+
+// isStatus returns whether an object is one of the statuses BookWyrm writes about books.
+func isStatus(object map[string]interface{}) bool {
+	switch object["type"] {
+	case TypeReview, TypeReviewRating, TypeComment, TypeQuotation:
+		return true
+	}
+	return false
+}
+
+// isReadShelf returns whether a shelf is a user's read shelf, which is at /user/<name>/books/read on BookWyrm servers.
+func isReadShelf(shelf string) bool {
+	u, err := url.Parse(shelf)
+	if err != nil {
+		return false
+	}
+	return path.Base(u.Path) == ReadShelf && path.Base(path.Dir(u.Path)) == "books"
+}
+
+// translateStatus turns a BookWyrm status into a Note about the book it's in reply to. Its text is kept as plain text as well as HTML, the way we send reviews.
+func translateStatus(c context.Context, status map[string]interface{}, fetch Fetcher) error {
+	book, err := Book(c, id(status["inReplyToBook"]), fetch)
+	if err != nil {
+		return err
+	}
+
+	// the content of a rating is generated from the rating, which we show ourselves
+	content, _ := status["content"].(string)
+	if status["type"] == TypeReviewRating {
+		content = ""
+	}
+	rendered := content
+	var text []string
+	if name, ok := status["name"].(string); ok && name != "" && status["type"] == TypeReview {
+		text = append(text, name)
+		rendered = "<p><strong>" + html.EscapeString(name) + "</strong></p>" + rendered
+	}
+	if quote, ok := status["quote"].(string); ok && quote != "" {
+		text = append(text, "“"+plainText(quote)+"”")
+		rendered = "<blockquote>" + quote + "</blockquote>" + rendered
+	}
+	if plain := plainText(content); plain != "" {
+		text = append(text, plain)
+	}
+
+	status["type"] = "Note"
+	status["content"] = rendered
+	status["source"] = map[string]interface{}{
+		"type":      "Object",
+		"content":   strings.Join(text, "\n\n"),
+		"mediaType": "text/plain",
+	}
+	status["tag"] = append(list(status["tag"]), book)
+	// BookWyrm sends an empty inReplyTo for statuses that aren't replies
+	if inReplyTo, ok := status["inReplyTo"].(string); ok && inReplyTo == "" {
+		delete(status, "inReplyTo")
+	}
+	// BookWyrm allows half stars, which we round up
+	if rating, ok := status["rating"].(float64); ok {
+		status["rating"] = math.Round(rating)
+	}
+	return nil
+}
+
+// translateShelving turns adding a book to the read shelf into a Read of the book. The Read takes the id of the shelf item, since BookWyrm gives every Add to a shelf the same id. BookWyrm doesn't address shelvings, so the Read is addressed to the actor's followers, whose servers BookWyrm delivers it to.
+func translateShelving(c context.Context, activity map[string]interface{}, fetch Fetcher) (bool, error) {
+	item := activity["object"]
+	bookIRI := id(item)
+	if object, ok := item.(map[string]interface{}); ok && object["type"] == TypeShelfItem {
+		bookIRI = id(object["book"])
+		if itemID := id(object); itemID != "" {
+			activity["id"] = itemID
+		}
+	}
+	book, err := Book(c, bookIRI, fetch)
+	if err != nil {
+		return false, err
+	}
+
+	activity["type"] = "Read"
+	activity["object"] = book
+	delete(activity, "target")
+	if activity["to"] == nil && activity["cc"] == nil {
+		actor := strings.TrimSuffix(id(activity["actor"]), "/")
+		if actor == "" {
+			return false, fmt.Errorf("shelving %v has no actor", activity["id"])
+		}
+		activity["to"] = []interface{}{actor + "/followers"}
+	}
+	return true, nil
+}
+
+// Book fetches a BookWyrm edition or work and returns it as the Document we describe books with. The OpenLibrary work it's matched by is the one BookWyrm knows for the work, so an edition's work is fetched as well. The authors are included if OpenLibrary knows them too.
+func Book(c context.Context, iri string, fetch Fetcher) (map[string]interface{}, error) {
+	if iri == "" {
+		return nil, fmt.Errorf("status isn't about a book")
+	}
+	edition, err := fetchObject(c, iri, fetch)
+	if err != nil {
+		return nil, err
+	}
+	work := edition
+	switch edition["type"] {
+	case TypeWork:
+	case TypeEdition:
+		if work, err = fetchObject(c, id(edition["work"]), fetch); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s is a %v rather than a book", iri, edition["type"])
+	}
+
+	key, _ := work["openlibraryKey"].(string)
+	if !regexpWorkKey.MatchString(key) {
+		return nil, fmt.Errorf("%s: %w", iri, ErrUnknownBook)
+	}
+
+	title, _ := edition["title"].(string)
+	if subtitle, ok := edition["subtitle"].(string); ok && subtitle != "" {
+		title += ": " + subtitle
+	}
+	document := map[string]interface{}{
+		"type":           "Document",
+		"id":             iri,
+		"name":           title,
+		"openlibraryKey": key,
+	}
+	if published, ok := edition["publishedDate"].(string); ok && published != "" {
+		document["published"] = published
+	}
+	for _, property := range []string{"isbn10", "isbn13"} {
+		if isbn, ok := edition[property].(string); ok && isbn != "" {
+			document[property] = isbn
+		}
+	}
+	if key, ok := edition["openlibraryKey"].(string); ok && regexpEditionKey.MatchString(key) {
+		document["edition"] = "https://openlibrary.org/books/" + key
+	}
+	if wikidata, ok := work["wikidata"].(string); ok && wikidata != "" {
+		document["wikidata"] = wikidata
+	}
+	if pages, ok := edition["pages"].(float64); ok && pages > 0 {
+		document["pages"] = pages
+	}
+	if subjects := stringList(work["subjects"]); len(subjects) > 0 {
+		document["subjects"] = subjects
+	}
+	if cover, ok := edition["cover"].(map[string]interface{}); ok {
+		document["cover"] = cover
+	}
+
+	var authors []interface{}
+	for _, authorIRI := range stringList(work["authors"]) {
+		author, err := fetchObject(c, authorIRI, fetch)
+		if err != nil {
+			continue
+		}
+		key, _ := author["openlibraryKey"].(string)
+		name, _ := author["name"].(string)
+		if !regexpAuthorKey.MatchString(key) || name == "" {
+			continue
+		}
+		authors = append(authors, map[string]interface{}{
+			"type": "Person",
+			"id":   "https://openlibrary.org/authors/" + key,
+			"name": name,
+		})
+	}
+	if len(authors) > 0 {
+		document["attributedTo"] = authors
+	}
+	return document, nil
+}
+
+// plainText returns the text of the HTML BookWyrm renders statuses in, with paragraphs separated by blank lines.
+func plainText(content string) string {
+	content = regexpParagraphEnd.ReplaceAllString(content, "\n\n")
+	content = regexpLineBreak.ReplaceAllString(content, "\n")
+	content = regexpTag.ReplaceAllString(content, "")
+	return strings.TrimSpace(html.UnescapeString(content))
+}
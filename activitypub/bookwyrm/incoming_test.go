@@ -0,0 +1,209 @@
+package bookwyrm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixtures are the objects served by the BookWyrm server in testdata, by IRI.
+var fixtures = map[string]string{
+	"https://bookwyrm.example/book/1234": "edition.json",
+	"https://bookwyrm.example/book/1233": "work.json",
+	"https://bookwyrm.example/author/56": "author.json",
+}
+
+// fixture returns the JSON in a file in testdata.
+func fixture(t *testing.T, name string) map[string]interface{} {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &m))
+	return m
+}
+
+// fetchFixtures dereferences IRIs from the fixtures, counting how often each is fetched.
+type fetchFixtures map[string]int
+
+func (f fetchFixtures) fetch(c context.Context, iri *url.URL) ([]byte, error) {
+	name, ok := fixtures[iri.String()]
+	if !ok {
+		return nil, fmt.Errorf("fetching %s returned 404", iri)
+	}
+	f[iri.String()]++
+	return ioutil.ReadFile(filepath.Join("testdata", name))
+}
+
+// translatedNote returns the Note a translated Create is of, as go-fed sees it.
+func translatedNote(t *testing.T, activity map[string]interface{}) vocab.ActivityStreamsNote {
+	var note vocab.ActivityStreamsNote
+	resolver, err := streams.NewJSONResolver(func(c context.Context, create vocab.ActivityStreamsCreate) error {
+		objects := create.GetActivityStreamsObject()
+		if objects == nil || objects.Len() != 1 || !objects.At(0).IsActivityStreamsNote() {
+			return errors.New("create is not of a note")
+		}
+		note = objects.At(0).GetActivityStreamsNote()
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, resolver.Resolve(context.Background(), activity))
+	return note
+}
+
+func TestTranslateReview(t *testing.T) {
+	activity := fixture(t, "create_review.json")
+	fetched := fetchFixtures{}
+
+	translated, err := Translate(context.Background(), activity, fetched.fetch)
+	require.NoError(t, err)
+	assert.True(t, translated)
+	assert.Equal(t, 1, fetched["https://bookwyrm.example/book/1234"])
+	assert.Equal(t, 1, fetched["https://bookwyrm.example/book/1233"])
+
+	note := translatedNote(t, activity)
+	assert.Nil(t, model.InReplyTo(note), "empty inReplyTo should be dropped")
+
+	review, err := model.ReviewFromType(note)
+	require.NoError(t, err)
+	assert.Equal(t, "https://bookwyrm.example/user/mouse/review/789", review.URI)
+	assert.Equal(t, "Cold and warm\n\nLe Guin at her best.\n\nThe ice journey stays with you & won't let go.", review.Text)
+	assert.Equal(t, 5, review.Rating, "half stars should be rounded up")
+
+	document := model.BookDocument(note)
+	require.NotNil(t, document)
+	book, err := model.BookFromType(document)
+	require.NoError(t, err)
+	assert.Equal(t, "/works/OL59863W", book.OpenLibraryID)
+	assert.Equal(t, "The Left Hand of Darkness", book.Title)
+	assert.Equal(t, "9780441007318", book.ISBN)
+	assert.Equal(t, "0441007317", book.ISBN10)
+	assert.Equal(t, "/books/OL24382006M", book.EditionID)
+	assert.Equal(t, "Q645136", book.WikidataID)
+	assert.Equal(t, 304, book.Pages)
+	assert.Equal(t, []string{"Science fiction", "Gender"}, []string(book.Subjects))
+	assert.Empty(t, book.Covers, "covers from other servers shouldn't be kept")
+	require.Len(t, book.Authors, 1)
+	assert.Equal(t, "/authors/OL31353A", book.Authors[0].OpenLibraryID)
+	assert.Equal(t, "Ursula K. Le Guin", book.Authors[0].Name)
+}
+
+func TestTranslateComment(t *testing.T) {
+	activity := fixture(t, "create_comment.json")
+
+	translated, err := Translate(context.Background(), activity, fetchFixtures{}.fetch)
+	require.NoError(t, err)
+	assert.True(t, translated)
+
+	review, err := model.ReviewFromType(translatedNote(t, activity))
+	require.NoError(t, err)
+	assert.Equal(t, "Halfway through,\nand Genly still doesn't get it.", review.Text)
+	assert.Equal(t, 0, review.Rating)
+}
+
+func TestTranslateQuotation(t *testing.T) {
+	activity := fixture(t, "create_quotation.json")
+
+	translated, err := Translate(context.Background(), activity, fetchFixtures{}.fetch)
+	require.NoError(t, err)
+	assert.True(t, translated)
+
+	note := translatedNote(t, activity)
+	review, err := model.ReviewFromType(note)
+	require.NoError(t, err)
+	assert.Equal(t, "“Light is the left hand of darkness.”\n\nThe whole book in a sentence.", review.Text)
+	assert.Equal(t, "Ending spoilers", review.Spoiler)
+
+	content := note.GetActivityStreamsContent().At(0).GetXMLSchemaString()
+	assert.Equal(t, "<blockquote><p>Light is the left hand of darkness.</p></blockquote><p>The whole book in a sentence.</p>", content)
+}
+
+func TestTranslateShelving(t *testing.T) {
+	activity := fixture(t, "add_read.json")
+
+	translated, err := Translate(context.Background(), activity, fetchFixtures{}.fetch)
+	require.NoError(t, err)
+	assert.True(t, translated)
+
+	var read vocab.ActivityStreamsRead
+	resolver, err := streams.NewJSONResolver(func(c context.Context, r vocab.ActivityStreamsRead) error {
+		read = r
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, resolver.Resolve(context.Background(), activity))
+
+	assert.Equal(t, "https://bookwyrm.example/user/mouse/shelfbook/4321", read.GetJSONLDId().Get().String(), "the read should take the shelf item's id")
+	to := read.GetActivityStreamsTo()
+	require.NotNil(t, to)
+	require.Equal(t, 1, to.Len())
+	assert.Equal(t, "https://bookwyrm.example/user/mouse/followers", to.At(0).GetIRI().String())
+
+	objects := read.GetActivityStreamsObject()
+	require.Equal(t, 1, objects.Len())
+	require.True(t, objects.At(0).IsActivityStreamsDocument())
+	book, err := model.BookFromType(objects.At(0).GetActivityStreamsDocument())
+	require.NoError(t, err)
+	assert.Equal(t, "/works/OL59863W", book.OpenLibraryID)
+}
+
+func TestTranslateOtherShelf(t *testing.T) {
+	activity := fixture(t, "add_read.json")
+	activity["target"] = "https://bookwyrm.example/user/mouse/books/to-read"
+	fetched := fetchFixtures{}
+
+	translated, err := Translate(context.Background(), activity, fetched.fetch)
+	assert.NoError(t, err)
+	assert.False(t, translated)
+	assert.Equal(t, "Add", activity["type"])
+	assert.Empty(t, fetched)
+}
+
+func TestTranslateOther(t *testing.T) {
+	activity := map[string]interface{}{
+		"id":     "https://bookwyrm.example/user/mouse#follows/1",
+		"type":   "Follow",
+		"actor":  "https://bookwyrm.example/user/mouse",
+		"object": "https://exlibris.example/user/alice",
+	}
+
+	translated, err := Translate(context.Background(), activity, fetchFixtures{}.fetch)
+	assert.NoError(t, err)
+	assert.False(t, translated)
+}
+
+func TestTranslateUnknownBook(t *testing.T) {
+	work := fixture(t, "work.json")
+	work["openlibraryKey"] = ""
+	fetch := func(c context.Context, iri *url.URL) ([]byte, error) {
+		if iri.String() == "https://bookwyrm.example/book/1233" {
+			return json.Marshal(work)
+		}
+		return fetchFixtures{}.fetch(c, iri)
+	}
+
+	_, err := Translate(context.Background(), fixture(t, "create_review.json"), fetch)
+	assert.True(t, errors.Is(err, ErrUnknownBook))
+}
+
+func TestTranslateSpoofedBook(t *testing.T) {
+	activity := fixture(t, "create_review.json")
+	activity["object"].(map[string]interface{})["inReplyToBook"] = "https://elsewhere.example/book/1"
+	fetch := func(c context.Context, iri *url.URL) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join("testdata", "edition.json"))
+	}
+
+	_, err := Translate(context.Background(), activity, fetch)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrUnknownBook))
+}
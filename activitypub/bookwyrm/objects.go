@@ -0,0 +1,93 @@
+package bookwyrm
+
+import (
+	"strings"
+
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/exlibris-fed/openlibrary-go"
+)
+
+// Edition returns a book as a BookWyrm Edition, which BookWyrm servers fetch when something we send them is about it. We don't keep editions apart from works, so the Edition has the details of the one the book's come from, and belongs to the Work returned by Work.
+func Edition(book *model.Book) map[string]interface{} {
+	edition := bookObject(book, TypeEdition, book.EditionIRI().String())
+	edition["work"] = book.WorkIRI().String()
+	edition["openlibraryKey"] = strings.TrimPrefix(book.EditionID, "/books/")
+	edition["isbn10"] = ""
+	edition["isbn13"] = ""
+	if len(book.ISBN) == 13 {
+		edition["isbn13"] = book.ISBN
+	}
+	if book.ISBN10 != "" {
+		edition["isbn10"] = book.ISBN10
+	} else if len(book.ISBN) == 10 {
+		edition["isbn10"] = book.ISBN
+	}
+	edition["pages"] = nil
+	if book.Pages > 0 {
+		edition["pages"] = book.Pages
+	}
+	edition["physicalFormat"] = ""
+	edition["publishers"] = []string{}
+	return edition
+}
+
+// Work returns a book as a BookWyrm Work, with the Edition returned by Edition as its only edition.
+func Work(book *model.Book) map[string]interface{} {
+	work := bookObject(book, TypeWork, book.WorkIRI().String())
+	work["openlibraryKey"] = book.Key()
+	work["editions"] = []string{book.EditionIRI().String()}
+	work["lccn"] = ""
+	return work
+}
+
+// Author returns an author as a BookWyrm Author, which BookWyrm servers fetch for the books they're sent.
+func Author(author *model.Author) map[string]interface{} {
+	return map[string]interface{}{
+		"@context":       activityStreamsContext,
+		"id":             author.IRI().String(),
+		"type":           TypeAuthor,
+		"name":           author.Name,
+		"openlibraryKey": author.Key(),
+		"aliases":        []string{},
+	}
+}
+
+// bookObject returns the properties editions and works have in common.
+func bookObject(book *model.Book, bookType, iri string) map[string]interface{} {
+	authors := []string{}
+	for i := range book.Authors {
+		authors = append(authors, book.Authors[i].IRI().String())
+	}
+	subjects := []string{}
+	if len(book.Subjects) > 0 {
+		subjects = book.Subjects
+	}
+
+	object := map[string]interface{}{
+		"@context":      activityStreamsContext,
+		"id":            iri,
+		"type":          bookType,
+		"title":         book.Title,
+		"sortTitle":     book.Title,
+		"subtitle":      "",
+		"description":   book.Description,
+		"languages":     []string{},
+		"series":        "",
+		"seriesNumber":  "",
+		"subjects":      subjects,
+		"subjectPlaces": []string{},
+		"authors":       authors,
+		"wikidata":      book.WikidataID,
+	}
+	for _, cover := range book.Covers {
+		if cover.Type == string(openlibrary.SizeLarge) {
+			object["cover"] = map[string]interface{}{
+				"type": "Document",
+				"url":  cover.URL,
+				"name": book.Title,
+			}
+		}
+	}
+	return object
+}
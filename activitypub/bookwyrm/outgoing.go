@@ -0,0 +1,128 @@
+package bookwyrm
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+
+	"github.com/exlibris-fed/exlibris/model"
+)
+
+// Outgoing rewrites an activity we're sending into the form BookWyrm understands. Creates and Updates of reviews become Reviews of the book, and Reads become Comments marking the book as read. BookWyrm fetches the book they're in reply to from us, as an Edition. It returns false, and the activity as it is, if it isn't one of those.
+func Outgoing(activity map[string]interface{}) (map[string]interface{}, bool) {
+	switch activity["type"] {
+	case "Create", "Update":
+		note, ok := activity["object"].(map[string]interface{})
+		if !ok || note["type"] != "Note" {
+			return activity, false
+		}
+		book := taggedBook(note)
+		if book == nil {
+			return activity, false
+		}
+		review(note, book)
+		activity["to"] = list(activity["to"])
+		activity["cc"] = list(activity["cc"])
+		return activity, true
+	case "Read":
+		book := readBook(activity)
+		if book == nil {
+			return activity, false
+		}
+		return readComment(activity, book), true
+	}
+	return activity, false
+}
+
+// taggedBook returns the book tagged on a Note, as model.Review.ToType tags it, and takes it out of the tags. BookWyrm only expects links there.
+func taggedBook(note map[string]interface{}) *model.Book {
+	var book *model.Book
+	tags := []interface{}{}
+	for _, tag := range list(note["tag"]) {
+		if document, ok := tag.(map[string]interface{}); ok && document["type"] == "Document" && book == nil {
+			if book = documentBook(document); book != nil {
+				continue
+			}
+		}
+		tags = append(tags, tag)
+	}
+	if book != nil {
+		note["tag"] = tags
+	}
+	return book
+}
+
+// readBook returns the book that is the object of a Read.
+func readBook(read map[string]interface{}) *model.Book {
+	objects := list(read["object"])
+	if len(objects) == 0 {
+		return nil
+	}
+	document, ok := objects[0].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return documentBook(document)
+}
+
+// documentBook returns the book a Document describes, with just enough filled in to find where it's served and link to it.
+func documentBook(document map[string]interface{}) *model.Book {
+	var openLibraryID string
+	if u, err := url.Parse(id(document)); err == nil {
+		openLibraryID, _ = model.BookIDFromIRI(u)
+	}
+	if key, ok := document["openlibraryKey"].(string); ok && openLibraryID == "" && regexpWorkKey.MatchString(key) {
+		openLibraryID = "/works/" + key
+	}
+	if openLibraryID == "" {
+		return nil
+	}
+	title, _ := document["name"].(string)
+	return &model.Book{OpenLibraryID: openLibraryID, Title: title}
+}
+
+// review turns a Note about a book into a Review of it.
+func review(note map[string]interface{}, book *model.Book) {
+	note["type"] = TypeReview
+	note["inReplyToBook"] = book.EditionIRI().String()
+	note["to"] = list(note["to"])
+	note["cc"] = list(note["cc"])
+	if _, ok := note["sensitive"]; !ok {
+		note["sensitive"] = false
+	}
+	if _, ok := note["attachment"]; !ok {
+		note["attachment"] = []interface{}{}
+	}
+}
+
+// readComment turns a Read into the Create of a Comment on the book, with the reading status BookWyrm gives books when they're put on the read shelf. The Comment takes the Read's id.
+func readComment(read map[string]interface{}, book *model.Book) map[string]interface{} {
+	actor := id(read["actor"])
+	comment := map[string]interface{}{
+		"id":            read["id"],
+		"type":          TypeComment,
+		"attributedTo":  actor,
+		"content":       fmt.Sprintf(`<p>Finished reading <a href="%s">%s</a></p>`, html.EscapeString(book.URL()), html.EscapeString(book.Title)),
+		"to":            list(read["to"]),
+		"cc":            list(read["cc"]),
+		"inReplyToBook": book.EditionIRI().String(),
+		"readingStatus": ReadShelf,
+		"tag":           []interface{}{},
+		"attachment":    []interface{}{},
+		"sensitive":     false,
+	}
+	create := map[string]interface{}{
+		"@context": read["@context"],
+		"id":       fmt.Sprintf("%v#create", read["id"]),
+		"type":     "Create",
+		"actor":    actor,
+		"to":       comment["to"],
+		"cc":       comment["cc"],
+		"object":   comment,
+	}
+	if published, ok := read["published"]; ok {
+		comment["published"] = published
+		create["published"] = published
+	}
+	return create
+}
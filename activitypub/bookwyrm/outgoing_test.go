@@ -0,0 +1,144 @@
+package bookwyrm
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	author = model.Author{
+		OpenLibraryID: "/authors/OL31353A",
+		Name:          "Ursula K. Le Guin",
+	}
+	book = model.Book{
+		OpenLibraryID: "/works/OL59863W",
+		Title:         "The Left Hand of Darkness",
+		ISBN:          "9780441007318",
+		ISBN10:        "0441007317",
+		EditionID:     "/books/OL24382006M",
+		WikidataID:    "Q645136",
+		Pages:         304,
+		Authors:       []model.Author{author},
+		Covers: []model.Cover{
+			{Type: "L", URL: "http://covers.openlibrary.org/b/id/1-L.jpg"},
+		},
+	}
+	user = model.User{
+		Username: "alice",
+		Local:    true,
+	}
+)
+
+// serialize returns an ActivityStreams value as it's delivered.
+func serialize(t *testing.T, value vocab.Type) map[string]interface{} {
+	m, err := streams.Serialize(value)
+	require.NoError(t, err)
+	b, err := json.Marshal(model.WithVocabulary(m))
+	require.NoError(t, err)
+	var activity map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &activity))
+	return activity
+}
+
+func TestOutgoingReview(t *testing.T) {
+	review := &model.Review{
+		Base:   model.Base{ID: uuid.New(), BaseEvents: model.BaseEvents{CreatedAt: time.Now()}},
+		Book:   book,
+		User:   user,
+		Text:   "Cold and warm.",
+		Rating: 4,
+	}
+
+	activity, rewritten := Outgoing(serialize(t, review.CreateToType()))
+	require.True(t, rewritten)
+	assert.Equal(t, "Create", activity["type"])
+	assert.Equal(t, []interface{}{"https://www.w3.org/ns/activitystreams#Public"}, activity["to"])
+
+	status := activity["object"].(map[string]interface{})
+	assert.Equal(t, TypeReview, status["type"])
+	assert.Equal(t, review.IRI().String(), status["id"])
+	assert.Equal(t, book.EditionIRI().String(), status["inReplyToBook"])
+	assert.Equal(t, float64(4), status["rating"])
+	assert.Equal(t, []interface{}{}, status["tag"], "the book shouldn't be left in the tags")
+	assert.Equal(t, []interface{}{user.FollowersIRI().String()}, status["cc"])
+}
+
+func TestOutgoingRead(t *testing.T) {
+	read := &model.Read{
+		ID:         "https://exlibris.example/user/alice/read/1",
+		BaseEvents: model.BaseEvents{CreatedAt: time.Date(2021, time.March, 14, 18, 2, 11, 0, time.UTC)},
+		Book:       book,
+		User:       user,
+	}
+
+	activity, rewritten := Outgoing(serialize(t, read.ToType()))
+	require.True(t, rewritten)
+	assert.Equal(t, "Create", activity["type"])
+	assert.Equal(t, "https://exlibris.example/user/alice/read/1#create", activity["id"])
+	assert.Equal(t, user.IRI().String(), activity["actor"])
+	assert.NotNil(t, activity["@context"])
+
+	status := activity["object"].(map[string]interface{})
+	assert.Equal(t, TypeComment, status["type"])
+	assert.Equal(t, read.ID, status["id"])
+	assert.Equal(t, user.IRI().String(), status["attributedTo"])
+	assert.Equal(t, book.EditionIRI().String(), status["inReplyToBook"])
+	assert.Equal(t, ReadShelf, status["readingStatus"])
+	assert.Equal(t, "2021-03-14T18:02:11Z", status["published"])
+	assert.Len(t, status["to"], 2)
+}
+
+func TestOutgoingOther(t *testing.T) {
+	follow := map[string]interface{}{
+		"id":     "https://exlibris.example/user/alice/follow/1",
+		"type":   "Follow",
+		"actor":  "https://exlibris.example/user/alice",
+		"object": "https://bookwyrm.example/user/mouse",
+	}
+
+	activity, rewritten := Outgoing(follow)
+	assert.False(t, rewritten)
+	assert.Equal(t, follow, activity)
+}
+
+func TestEdition(t *testing.T) {
+	edition := Edition(&book)
+
+	assert.Equal(t, TypeEdition, edition["type"])
+	assert.Equal(t, book.EditionIRI().String(), edition["id"])
+	assert.Equal(t, book.WorkIRI().String(), edition["work"])
+	assert.Equal(t, "The Left Hand of Darkness", edition["title"])
+	assert.Equal(t, "OL24382006M", edition["openlibraryKey"])
+	assert.Equal(t, "9780441007318", edition["isbn13"])
+	assert.Equal(t, "0441007317", edition["isbn10"])
+	assert.Equal(t, 304, edition["pages"])
+	assert.Equal(t, []string{author.IRI().String()}, edition["authors"])
+	assert.Equal(t, "http://covers.openlibrary.org/b/id/1-L.jpg", edition["cover"].(map[string]interface{})["url"])
+}
+
+func TestWork(t *testing.T) {
+	work := Work(&book)
+
+	assert.Equal(t, TypeWork, work["type"])
+	assert.Equal(t, book.WorkIRI().String(), work["id"])
+	assert.Equal(t, "OL59863W", work["openlibraryKey"])
+	assert.Equal(t, "Q645136", work["wikidata"])
+	assert.Equal(t, []string{book.EditionIRI().String()}, work["editions"])
+}
+
+func TestAuthor(t *testing.T) {
+	a := Author(&author)
+
+	assert.Equal(t, TypeAuthor, a["type"])
+	assert.Equal(t, author.IRI().String(), a["id"])
+	assert.Equal(t, "Ursula K. Le Guin", a["name"])
+	assert.Equal(t, "OL31353A", a["openlibraryKey"])
+}
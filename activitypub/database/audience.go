@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+
+	"github.com/exlibris-fed/exlibris/infrastructure/following"
+	"github.com/exlibris-fed/exlibris/infrastructure/reactions"
+	"github.com/exlibris-fed/exlibris/infrastructure/reads"
+	"github.com/exlibris-fed/exlibris/infrastructure/replies"
+	"github.com/exlibris-fed/exlibris/infrastructure/reviews"
+	"github.com/exlibris-fed/exlibris/infrastructure/users"
+
+	"github.com/go-fed/activity/pub"
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+)
+
+type toer interface {
+	GetActivityStreamsTo() vocab.ActivityStreamsToProperty
+}
+
+type ccer interface {
+	GetActivityStreamsCc() vocab.ActivityStreamsCcProperty
+}
+
+// CanView determines whether the actor identified by requester may see the object at id. The requester is nil for anonymous requests. It returns ErrNotFound if there is no object at id.
+//
+// Actors, their collections and the tombstones of deleted objects can be seen by anyone, and the likes, shares and replies of an object by anyone who can see it. Other objects can be seen if they are addressed to the public, addressed directly to the requester, or addressed to the followers of a local user that the requester is or follows.
+func (d *Database) CanView(c context.Context, id *url.URL, requester *url.URL) (bool, error) {
+	if regexpID.MatchString(id.String()) || regexpFollowers.MatchString(id.String()) || regexpFollowing.MatchString(id.String()) || regexpLiked.MatchString(id.String()) {
+		return true, nil
+	}
+	for _, collection := range []*regexp.Regexp{regexpReactions, regexpReplies} {
+		if pieces := collection.FindStringSubmatch(id.String()); len(pieces) > 1 {
+			object, err := url.Parse(pieces[1])
+			if err != nil {
+				return false, err
+			}
+			return d.CanView(c, object, requester)
+		}
+	}
+
+	t, err := d.Get(c, id)
+	if notFound(err) {
+		return false, ErrNotFound
+	} else if err != nil {
+		return false, err
+	}
+	if streams.IsOrExtendsActivityStreamsTombstone(t) {
+		return true, nil
+	}
+	return d.canViewType(c, t, requester)
+}
+
+// notFound returns whether an error getting an object means that there's nothing at its id, rather than that it couldn't be read.
+func notFound(err error) bool {
+	for _, target := range []error{ErrNotFound, reads.ErrNotFound, reviews.ErrNotFound, replies.ErrNotFound, reactions.ErrNotFound, users.ErrNotFound, following.ErrNotFound} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// canViewType determines whether the actor identified by requester may see an object, based on who it is addressed to.
+func (d *Database) canViewType(c context.Context, t vocab.Type, requester *url.URL) (bool, error) {
+	for _, iri := range Audience(t) {
+		if pub.IsPublic(iri.String()) {
+			return true, nil
+		}
+		if requester == nil {
+			continue
+		}
+		if iri.String() == requester.String() {
+			return true, nil
+		}
+		if ok, err := d.followsCollection(c, iri, requester); err != nil {
+			return false, err
+		} else if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// followsCollection returns whether the requester is a member of, or owns, a local followers collection.
+func (d *Database) followsCollection(c context.Context, collection, requester *url.URL) (bool, error) {
+	owns, err := d.Owns(c, collection)
+	if err != nil || !owns {
+		return false, err
+	}
+	pieces := regexpFollowers.FindStringSubmatch(collection.Path)
+	if len(pieces) != 2 {
+		return false, nil
+	}
+	user, err := d.usersRepo.GetByUsername(pieces[1])
+	if err != nil {
+		return false, err
+	}
+	if user.IRI().String() == requester.String() {
+		return true, nil
+	}
+	return d.usersRepo.HasFollower(user, requester.String())
+}
+
+// Audience returns the IRIs an object is addressed to in its `to` and `cc` properties.
+func Audience(t vocab.Type) (audience []*url.URL) {
+	if v, ok := t.(toer); ok && v.GetActivityStreamsTo() != nil {
+		for iter := v.GetActivityStreamsTo().Begin(); iter != nil; iter = iter.Next() {
+			if id, err := pub.ToId(iter); err == nil {
+				audience = append(audience, id)
+			}
+		}
+	}
+	if v, ok := t.(ccer); ok && v.GetActivityStreamsCc() != nil {
+		for iter := v.GetActivityStreamsCc().Begin(); iter != nil; iter = iter.Next() {
+			if id, err := pub.ToId(iter); err == nil {
+				audience = append(audience, id)
+			}
+		}
+	}
+	return
+}
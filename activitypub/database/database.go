@@ -3,20 +3,30 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
 	"regexp"
 	"strings"
-	"sync"
 
+	"github.com/exlibris-fed/exlibris/activitypub/lock"
 	"github.com/exlibris-fed/exlibris/config"
+	"github.com/exlibris-fed/exlibris/infrastructure/authors"
+	"github.com/exlibris-fed/exlibris/infrastructure/books"
+	"github.com/exlibris-fed/exlibris/infrastructure/followers"
+	"github.com/exlibris-fed/exlibris/infrastructure/following"
 	"github.com/exlibris-fed/exlibris/infrastructure/inbox"
 	"github.com/exlibris-fed/exlibris/infrastructure/outbox"
+	"github.com/exlibris-fed/exlibris/infrastructure/reactions"
 	"github.com/exlibris-fed/exlibris/infrastructure/reads"
+	"github.com/exlibris-fed/exlibris/infrastructure/replies"
+	"github.com/exlibris-fed/exlibris/infrastructure/reviews"
+	"github.com/exlibris-fed/exlibris/infrastructure/tombstones"
 	"github.com/exlibris-fed/exlibris/infrastructure/users"
 	"github.com/exlibris-fed/exlibris/model"
 
+	"github.com/go-fed/activity/pub"
 	"github.com/go-fed/activity/streams"
 	"github.com/go-fed/activity/streams/vocab"
 	"github.com/google/uuid"
@@ -29,8 +39,19 @@ var (
 	regexpInbox     = regexp.MustCompile("/user/([^\\/]+)/inbox$")
 	regexpRead      = regexp.MustCompile("/user/([^\\/]+)/read/([a-z0-9-]+)$")
 	regexpFollowers = regexp.MustCompile("/user/([^\\/]+)/followers$")
+	regexpFollowing = regexp.MustCompile("/user/([^\\/]+)/following$")
+	regexpFollow    = regexp.MustCompile("/user/([^\\/]+)/follow/([a-z0-9-]+)$")
+	regexpReview    = regexp.MustCompile("/user/([^\\/]+)/review/([a-z0-9-]+)(/activity)?$")
+	regexpReaction  = regexp.MustCompile("/user/([^\\/]+)/(like|announce)/([a-z0-9-]+)$")
+	regexpReactions = regexp.MustCompile("^(.*/user/[^\\/]+/(?:read|review)/[a-z0-9-]+)/(likes|shares)$")
+	regexpLiked     = regexp.MustCompile("/user/([^\\/]+)/liked$")
+	regexpReply     = regexp.MustCompile("/user/([^\\/]+)/reply/([a-z0-9-]+)(/activity)?$")
+	regexpReplies   = regexp.MustCompile("^(.*/user/[^\\/]+/(?:review|reply)/[a-z0-9-]+)/replies$")
 )
 
+// ErrNotFound is returned when there is no object at an id.
+var ErrNotFound = errors.New("object could not be found")
+
 const (
 	// ResultsPerPage is how many results to return in a response
 	ResultsPerPage = 10
@@ -41,29 +62,51 @@ const (
 
 // A Database is a connection to a database. It uses the gorm connection, so that we can still use the models.
 type Database struct {
-	baseURL    string
-	cfg        *config.Config
-	outboxRepo *outbox.Repository
-	inboxRepo  *inbox.Repository
-	usersRepo  *users.Repository
-	readsRepo  *reads.Repository
-	locks      map[*url.URL]*sync.Mutex
+	baseURL        string
+	cfg            *config.Config
+	outboxRepo     *outbox.Repository
+	inboxRepo      *inbox.Repository
+	usersRepo      *users.Repository
+	readsRepo      *reads.Repository
+	reviewsRepo    *reviews.Repository
+	booksRepo      *books.Repository
+	authorsRepo    *authors.Repository
+	followersRepo  *followers.Repository
+	followingRepo  *following.Repository
+	tombstonesRepo *tombstones.Repository
+	reactionsRepo  *reactions.Repository
+	repliesRepo    *replies.Repository
+	locks          lock.Locker
+	rejectsMedia   func(host string) bool
 }
 
-// New returns a new database object.
-func New(db *gorm.DB, cfg *config.Config) *Database {
+// New returns a new database object. Objects are locked within this process, or across every replica with Postgres advisory locks if the config asks for them. Media is left out of objects from hosts that rejectsMedia returns true for.
+func New(db *gorm.DB, cfg *config.Config, rejectsMedia func(host string) bool) *Database {
 	uri := url.URL{
 		Scheme: cfg.Scheme,
 		Host:   cfg.Domain,
 	}
+	var locks lock.Locker = lock.NewLocal()
+	if cfg.AdvisoryLocks {
+		locks = lock.NewAdvisory(db.DB())
+	}
 	return &Database{
-		baseURL:    uri.String(),
-		cfg:        cfg,
-		outboxRepo: outbox.New(db),
-		inboxRepo:  inbox.New(db),
-		usersRepo:  users.New(db),
-		readsRepo:  reads.New(db),
-		locks:      make(map[*url.URL]*sync.Mutex),
+		baseURL:        uri.String(),
+		cfg:            cfg,
+		outboxRepo:     outbox.New(db),
+		inboxRepo:      inbox.New(db),
+		usersRepo:      users.New(db),
+		readsRepo:      reads.New(db),
+		reviewsRepo:    reviews.New(db),
+		booksRepo:      books.New(db),
+		authorsRepo:    authors.New(db),
+		followersRepo:  followers.New(db),
+		followingRepo:  following.New(db),
+		tombstonesRepo: tombstones.New(db),
+		reactionsRepo:  reactions.New(db),
+		repliesRepo:    replies.New(db),
+		locks:          locks,
+		rejectsMedia:   rejectsMedia,
 	}
 }
 
@@ -79,13 +122,7 @@ func New(db *gorm.DB, cfg *config.Config) *Database {
 //
 // Used to ensure race conditions in multiple requests do not occur.
 func (d *Database) Lock(c context.Context, id *url.URL) error {
-	lock, ok := d.locks[id]
-	if !ok {
-		lock = new(sync.Mutex)
-		d.locks[id] = lock
-	}
-	lock.Lock()
-	return nil
+	return d.locks.Lock(c, lock.Key(id))
 }
 
 // Unlock makes the lock for the object at the specified id available.
@@ -93,12 +130,7 @@ func (d *Database) Lock(c context.Context, id *url.URL) error {
 //
 // Used to ensure race conditions in multiple requests do not occur.
 func (d *Database) Unlock(c context.Context, id *url.URL) error {
-	lock, ok := d.locks[id]
-	if !ok {
-		return fmt.Errorf("lock does not exist for %s", id.String())
-	}
-	lock.Unlock()
-	return nil
+	return d.locks.Unlock(c, lock.Key(id))
 }
 
 // InboxContains returns true if the OrderedCollection at 'inbox'
@@ -117,27 +149,17 @@ func (d *Database) InboxContains(c context.Context, inbox, id *url.URL) (contain
 func (d *Database) GetInbox(c context.Context, inboxIRI *url.URL) (inbox vocab.ActivityStreamsOrderedCollectionPage, err error) {
 	log.Printf("getting inbox: %s", inboxIRI.String())
 
-	inbox = streams.NewActivityStreamsOrderedCollectionPage()
-	id := streams.NewJSONLDIdProperty()
-	id.SetIRI(inboxIRI)
-	inbox.SetJSONLDId(id)
-
-	// TODO pagination
-	entries, err := d.inboxRepo.GetByIRI(inboxIRI)
+	entries, err := d.inboxEntries(inboxIRI, Page{})
 	if err != nil {
 		return nil, err
 	}
+	entries, _, _ = Page{}.window(entries)
 
-	orderedItems := streams.NewActivityStreamsOrderedItemsProperty()
-	for _, e := range entries {
-		iri, err := url.Parse(e.URI)
-		if err != nil {
-			log.Printf("error parsing url %s: %s", e.URI, err.Error())
-			continue
-		}
-		orderedItems.AppendIRI(iri)
-	}
-	inbox.SetActivityStreamsOrderedItems(orderedItems)
+	inbox = streams.NewActivityStreamsOrderedCollectionPage()
+	id := streams.NewJSONLDIdProperty()
+	id.SetIRI(inboxIRI)
+	inbox.SetJSONLDId(id)
+	inbox.SetActivityStreamsOrderedItems(iriItems(entries))
 
 	return
 }
@@ -161,34 +183,56 @@ func (d *Database) SetInbox(c context.Context, inbox vocab.ActivityStreamsOrdere
 		return err
 	}
 
-	existing := make(map[string]bool)
-	existingItems, err := d.inboxRepo.GetByIRI(inboxIRI)
-	if err != nil {
-		return err
-	}
-	for _, item := range existingItems {
-		existing[item.URI] = true
-	}
 	for item := items.Begin(); item != nil; item = item.Next() {
-		iri := item.GetIRI().String()
-		if _, exists := existing[iri]; !exists {
-			if err := d.inboxRepo.Create(&model.InboxEntry{
-				Base: model.Base{
-					ID: uuid.New(),
-				},
-				User:     *user,
-				InboxIRI: inboxIRI.String(),
-				URI:      iri,
-			}); err != nil {
-				return err
-			}
+		iri, err := pub.ToId(item)
+		if err != nil {
+			return err
+		}
+		if exists, err := d.inboxRepo.Contains(inboxIRI, iri); err != nil {
+			return err
+		} else if exists {
+			continue
+		}
+		if err := d.inboxRepo.Create(&model.InboxEntry{
+			Base: model.Base{
+				ID: uuid.New(),
+			},
+			User:     *user,
+			InboxIRI: inboxIRI.String(),
+			URI:      iri.String(),
+		}); err != nil {
+			return err
 		}
 	}
-
-	// TODO remove what doesn't exist (requires setting in get)
 	return nil
 }
 
+// Seen returns whether an activity has already been delivered to any local inbox, and so had its side effects applied.
+func (d *Database) Seen(c context.Context, id *url.URL) (bool, error) {
+	return d.inboxRepo.Seen(id)
+}
+
+// AddToInbox adds an activity to a local user's inbox, unless it's already there. Unlike a delivery to the inbox, it has no side effects.
+func (d *Database) AddToInbox(c context.Context, user *model.User, id *url.URL) error {
+	inboxIRI := user.InboxIRI()
+	if err := d.Lock(c, inboxIRI); err != nil {
+		return err
+	}
+	defer d.Unlock(c, inboxIRI)
+
+	if exists, err := d.inboxRepo.Contains(inboxIRI, id); err != nil || exists {
+		return err
+	}
+	return d.inboxRepo.Create(&model.InboxEntry{
+		Base: model.Base{
+			ID: uuid.New(),
+		},
+		User:     *user,
+		InboxIRI: inboxIRI.String(),
+		URI:      id.String(),
+	})
+}
+
 // Owns determines if the ActivityPub id is owned by this server.
 //
 // The library makes this call only after acquiring a lock first.
@@ -232,13 +276,29 @@ func (d *Database) OutboxForInbox(c context.Context, inboxIRI *url.URL) (outboxI
 // The library makes this call only after acquiring a lock first.
 func (d *Database) Exists(c context.Context, id *url.URL) (exists bool, err error) {
 	log.Println("in exists, looking at", id.String())
-	// TODO things other than reads
+	// TODO things other than reads, reviews and replies
 	read, getErr := d.readsRepo.GetByID(id.String())
 	if getErr == nil && read.ID == id.String() {
 		log.Println("they do exist")
 		exists = true
+		return
+	}
+
+	_, getErr = d.getReview(c, id)
+	if getErr == nil {
+		exists = true
+		return
+	} else if !errors.Is(getErr, reviews.ErrNotFound) {
+		err = getErr
+		return
+	}
+
+	_, getErr = d.getReply(c, id)
+	if getErr == nil {
+		exists = true
+	} else if !errors.Is(getErr, replies.ErrNotFound) {
+		err = getErr
 	}
-	log.Println("doesnt exist")
 	return
 }
 
@@ -246,22 +306,106 @@ func (d *Database) Exists(c context.Context, id *url.URL) (exists bool, err erro
 //
 // The library makes this call only after acquiring a lock first.
 func (d *Database) Get(c context.Context, id *url.URL) (value vocab.Type, err error) {
-	pieces := regexpRead.FindStringSubmatch(id.String())
+	// the only remote objects we keep are reads, reviews and replies, which are stored under their original IRI
+	if owns, _ := d.Owns(c, id); !owns {
+		if value, err = d.getRead(id.String()); err == nil {
+			return
+		}
+		if review, err := d.getReview(c, id); err == nil {
+			return review.ToType(), nil
+		} else if !errors.Is(err, reviews.ErrNotFound) {
+			return nil, err
+		}
+		reply, err := d.getReply(c, id)
+		if err != nil {
+			return nil, err
+		}
+		return reply.ToType(), nil
+	}
+
+	if tombstone, err := d.tombstonesRepo.Get(id.String()); err == nil {
+		return tombstone.ToType(), nil
+	} else if !errors.Is(err, tombstones.ErrNotFound) {
+		return nil, err
+	}
+
+	pieces := regexpReactions.FindStringSubmatch(id.String())
+	if len(pieces) == 3 {
+		return d.getReactions(c, id, pieces[1], pieces[2])
+	}
+
+	pieces = regexpReplies.FindStringSubmatch(id.String())
+	if len(pieces) == 2 {
+		return d.getReplies(c, id, pieces[1])
+	}
+
+	pieces = regexpReply.FindStringSubmatch(id.String())
+	if len(pieces) == 4 {
+		reply, err := d.getReply(c, id)
+		if err != nil {
+			return nil, err
+		}
+		if pieces[3] != "" {
+			return reply.CreateToType(), nil
+		}
+		return reply.ToType(), nil
+	}
+
+	pieces = regexpRead.FindStringSubmatch(id.String())
 	if len(pieces) == 3 {
 		return d.getRead(id.String())
 	}
 
+	pieces = regexpReview.FindStringSubmatch(id.String())
+	if len(pieces) == 4 {
+		review, err := d.getReview(c, id)
+		if err != nil {
+			return nil, err
+		}
+		if pieces[3] != "" {
+			return review.CreateToType(), nil
+		}
+		return review.ToType(), nil
+	}
+
 	pieces = regexpFollowers.FindStringSubmatch(id.String())
 	if len(pieces) == 2 {
 		return d.getFollowers(pieces[1])
 	}
 
+	pieces = regexpFollowing.FindStringSubmatch(id.String())
+	if len(pieces) == 2 {
+		return d.getFollowing(pieces[1])
+	}
+
+	pieces = regexpFollow.FindStringSubmatch(id.String())
+	if len(pieces) == 3 {
+		return d.getFollow(id.String())
+	}
+
+	pieces = regexpReaction.FindStringSubmatch(id.String())
+	if len(pieces) == 4 {
+		reaction, err := d.reactionsRepo.Get(id.String())
+		if err != nil {
+			return nil, err
+		}
+		return reaction.ToType(), nil
+	}
+
+	if regexpLiked.MatchString(id.String()) {
+		actorIRI, err := url.Parse(strings.TrimSuffix(id.String(), "/liked"))
+		if err != nil {
+			return nil, err
+		}
+		return d.Liked(c, actorIRI)
+	}
+
 	pieces = regexpID.FindStringSubmatch(id.String())
 	if len(pieces) == 2 {
 		return d.getProfile(pieces[1])
 	}
 
-	err = fmt.Errorf("don't know how to process uri %v", id)
+	err = fmt.Errorf("don't know how to process uri %v: %w", id, ErrNotFound)
 	return
 }
 
@@ -274,6 +418,156 @@ func (d *Database) getRead(strID string) (value vocab.Type, err error) {
 	return
 }
 
+// getReview returns the review with the given id, or the review created by the activity with that id. Local reviews are looked up by their ID, and remote ones by their original IRI.
+func (d *Database) getReview(c context.Context, id *url.URL) (*model.Review, error) {
+	if owns, _ := d.Owns(c, id); !owns {
+		return d.reviewsRepo.GetByURI(id.String())
+	}
+	pieces := regexpReview.FindStringSubmatch(id.Path)
+	if len(pieces) != 4 {
+		return nil, reviews.ErrNotFound
+	}
+	reviewID, err := uuid.Parse(pieces[2])
+	if err != nil {
+		return nil, reviews.ErrNotFound
+	}
+	review, err := d.reviewsRepo.GetByID(reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(review.User.Username, pieces[1]) {
+		return nil, reviews.ErrNotFound
+	}
+	return review, nil
+}
+
+// getReactions returns the likes or shares collection of a read or review, whose IRI is objectIRI. The collection holds the ids of the Like or Announce activities.
+func (d *Database) getReactions(c context.Context, id *url.URL, objectIRI, name string) (vocab.Type, error) {
+	object, err := url.Parse(objectIRI)
+	if err != nil {
+		return nil, err
+	}
+	if author, err := d.AttributedTo(c, object); err != nil {
+		return nil, err
+	} else if author == nil {
+		return nil, fmt.Errorf("no read or review at %v: %w", object, ErrNotFound)
+	}
+
+	kind := model.ReactionLike
+	if name == "shares" {
+		kind = model.ReactionAnnounce
+	}
+	list, err := d.reactionsRepo.List(kind, objectIRI)
+	if err != nil {
+		return nil, err
+	}
+	var iris []string
+	for _, reaction := range list {
+		iris = append(iris, reaction.ID)
+	}
+	return collection(id, iris), nil
+}
+
+// getReply returns the reply with the given id, or the reply created by the activity with that id. Local replies are looked up by their ID, and remote ones by their original IRI.
+func (d *Database) getReply(c context.Context, id *url.URL) (*model.Reply, error) {
+	if owns, _ := d.Owns(c, id); !owns {
+		return d.repliesRepo.GetByURI(id.String())
+	}
+	pieces := regexpReply.FindStringSubmatch(id.Path)
+	if len(pieces) != 4 {
+		return nil, replies.ErrNotFound
+	}
+	replyID, err := uuid.Parse(pieces[2])
+	if err != nil {
+		return nil, replies.ErrNotFound
+	}
+	reply, err := d.repliesRepo.GetByID(replyID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(reply.User.Username, pieces[1]) {
+		return nil, replies.ErrNotFound
+	}
+	return reply, nil
+}
+
+// getReplies returns the collection of direct replies to a review or reply, whose IRI is objectIRI.
+func (d *Database) getReplies(c context.Context, id *url.URL, objectIRI string) (vocab.Type, error) {
+	object, err := url.Parse(objectIRI)
+	if err != nil {
+		return nil, err
+	}
+	if review, _, err := d.thread(c, object); err != nil {
+		return nil, err
+	} else if review == nil {
+		return nil, fmt.Errorf("no review or reply at %v: %w", object, ErrNotFound)
+	}
+
+	list, err := d.repliesRepo.ListByInReplyTo(objectIRI)
+	if err != nil {
+		return nil, err
+	}
+	var iris []string
+	for _, reply := range list {
+		if iri := reply.IRI(); iri != nil {
+			iris = append(iris, iri.String())
+		}
+	}
+	return collection(id, iris), nil
+}
+
+// Thread returns the review at the root of the discussion that the review or reply with the given IRI is part of, or nil if we have neither.
+func (d *Database) Thread(c context.Context, id *url.URL) (*model.Review, error) {
+	review, _, err := d.thread(c, id)
+	return review, err
+}
+
+// thread returns the review at the root of the discussion that the review or reply with the given IRI is part of, along with whoever wrote that review or reply. The review is nil if we have neither.
+func (d *Database) thread(c context.Context, id *url.URL) (*model.Review, *url.URL, error) {
+	review, err := d.getReview(c, id)
+	if err == nil && review.IRI().String() == id.String() {
+		return review, review.User.IRI(), nil
+	} else if err != nil && !errors.Is(err, reviews.ErrNotFound) {
+		return nil, nil, err
+	}
+
+	reply, err := d.getReply(c, id)
+	if errors.Is(err, replies.ErrNotFound) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if reply.IRI().String() != id.String() {
+		// the activity that created the reply, rather than the reply itself
+		return nil, nil, nil
+	}
+	review, err = d.reviewsRepo.GetByID(reply.ReviewID)
+	if errors.Is(err, reviews.ErrNotFound) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return review, reply.User.IRI(), nil
+}
+
+// AttributedTo returns the IRI of whoever wrote a read or review we have, whether it's local or from another server. It returns nil if there's no read or review with that id.
+func (d *Database) AttributedTo(c context.Context, id *url.URL) (*url.URL, error) {
+	if read, err := d.readsRepo.GetByID(id.String()); err == nil {
+		return read.User.IRI(), nil
+	}
+	review, err := d.getReview(c, id)
+	if errors.Is(err, reviews.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if review.IRI().String() != id.String() {
+		// the activity that created the review, rather than the review itself
+		return nil, nil
+	}
+	return review.User.IRI(), nil
+}
+
 func (d *Database) getFollowers(strID string) (value vocab.Type, err error) {
 	u, err := d.usersRepo.GetByUsernameWithFollowers(strID)
 	if err != nil {
@@ -283,6 +577,24 @@ func (d *Database) getFollowers(strID string) (value vocab.Type, err error) {
 	return
 }
 
+func (d *Database) getFollowing(strID string) (value vocab.Type, err error) {
+	u, err := d.usersRepo.GetByUsernameWithFollowing(strID)
+	if err != nil {
+		return
+	}
+	value = u.FollowingToType()
+	return
+}
+
+func (d *Database) getFollow(strID string) (value vocab.Type, err error) {
+	f, err := d.followingRepo.GetByFollowID(strID)
+	if err != nil {
+		return
+	}
+	value = f.ToType()
+	return
+}
+
 func (d *Database) getProfile(strID string) (value vocab.Type, err error) {
 	u, err := d.usersRepo.GetByUsername(strID)
 	if err != nil {
@@ -319,18 +631,235 @@ func (d *Database) Create(c context.Context, asType vocab.Type) error {
 	}
 
 	if asRead, ok := asType.(vocab.ActivityStreamsRead); ok {
-		log.Println(asRead)
-		//r := new(model.Read)
-		// TODO
-		// map[@context:https://www.w3.org/ns/activitystreams actor:map[id:localhost:8080/@dconley inbox:http://localhost:8080/user/dconley/inbox name:dconley outbox:http://localhost:8080/user/dconley/outbox preferredUsername:dconley type:Person] id:http://localhost:8080/@dconley/read/75fa9f8e-35a4-4cce-a56f-6b631857a425 object:map[attributedTo:map[id:https://openlibrary.org/authors//authors/OL19430A/ name:Neal Stephenson type:Person] id:https://openlibrary.org/works//works/OL14911626W/ name:Anathem type:Document] to:[http://localhost:8080/user/dconley/followers https://www.w3.org/ns/activitystreams#Public] type:Read]
+		return d.createRead(c, asRead)
+	}
+
+	if note, ok := asType.(vocab.ActivityStreamsNote); ok {
+		return d.createNote(c, note)
+	}
+
+	switch asType.(type) {
+	case vocab.ActivityStreamsCreate:
+		// the objects of a Create are created on their own
+		return nil
+	case vocab.ActivityStreamsFollow, vocab.ActivityStreamsAccept, vocab.ActivityStreamsReject, vocab.ActivityStreamsUndo, vocab.ActivityStreamsBlock:
+		// follows and blocks are kept in their own tables by the federating callbacks
+		return nil
+	case vocab.ActivityStreamsUpdate, vocab.ActivityStreamsDelete:
+		// the objects of updates and deletes are changed by Update and Delete
+		return nil
+	case vocab.ActivityStreamsLike, vocab.ActivityStreamsAnnounce:
+		// reactions are kept in their own table by the federating callbacks, or when a local user reacts
+		return nil
+	}
+
+	// anything else, such as a BookWyrm shelving or a Mastodon post, is only kept in the inbox. Failing here would fail the whole delivery, which the sender would only retry.
+	log.Printf("not storing %s %s", asType.GetTypeName(), id)
+	return nil
+}
+
+// createRead saves a read from another server under its original IRI, along with the remote user who read it and the book they read.
+func (d *Database) createRead(c context.Context, asRead vocab.ActivityStreamsRead) error {
+	actors := asRead.GetActivityStreamsActor()
+	if actors == nil || actors.Len() == 0 {
+		return pub.ErrObjectRequired
+	}
+	actor, err := pub.ToId(actors.At(0))
+	if err != nil {
+		return err
+	}
+	var person vocab.ActivityStreamsPerson
+	if actors.At(0).IsActivityStreamsPerson() {
+		person = actors.At(0).GetActivityStreamsPerson()
+	}
+	user, err := d.remoteUser(c, actor, person)
+	if err != nil {
+		return err
+	}
 
-		return fmt.Errorf("whoops not yet")
-		//return d.readsRepo.Create(r)
+	objects := asRead.GetActivityStreamsObject()
+	if objects == nil || objects.Len() == 0 {
+		return pub.ErrObjectRequired
+	}
+	if !objects.At(0).IsActivityStreamsDocument() {
+		return fmt.Errorf("read %v is not of a document", asRead.GetJSONLDId().Get())
+	}
+	book, err := d.resolveBook(objects.At(0).GetActivityStreamsDocument(), asRead.GetJSONLDId().Get())
+	if err != nil {
+		return err
 	}
 
-	// TODO other types
+	read := &model.Read{
+		ID:            asRead.GetJSONLDId().Get().String(),
+		User:          *user,
+		UserID:        user.ID,
+		Book:          *book,
+		BookID:        book.OpenLibraryID,
+		FollowersOnly: true,
+	}
+	for _, iri := range Audience(asRead) {
+		if pub.IsPublic(iri.String()) {
+			read.FollowersOnly = false
+		}
+	}
+	_, err = d.readsRepo.Create(read)
+	return err
+}
 
-	return fmt.Errorf("dont know how to handle")
+// createNote saves a Note from another server. Notes in reply to a review or reply we have join its thread, and any other Note about a book is a review.
+func (d *Database) createNote(c context.Context, note vocab.ActivityStreamsNote) error {
+	if inReplyTo := model.InReplyTo(note); inReplyTo != nil {
+		review, author, err := d.thread(c, inReplyTo)
+		if err != nil {
+			return err
+		}
+		if review != nil {
+			return d.createReply(c, note, review, inReplyTo, author)
+		}
+	}
+	return d.createReview(c, note)
+}
+
+// createReply saves a reply from another server to a review or reply in the thread under review, along with the remote user who wrote it. Like reviews, only public replies signed by their author are kept.
+func (d *Database) createReply(c context.Context, note vocab.ActivityStreamsNote, review *model.Review, inReplyTo, inReplyToAuthor *url.URL) error {
+	if !isPublic(note) {
+		log.Printf("ignoring reply %v, which isn't public", note.GetJSONLDId().Get())
+		return nil
+	}
+	actor, person, err := Attribution(note)
+	if err != nil {
+		return err
+	}
+	if !signedByAuthor(c, note.GetJSONLDId().Get(), actor) {
+		return nil
+	}
+	user, err := d.remoteUser(c, actor, person)
+	if err != nil {
+		return err
+	}
+
+	reply, err := model.ReplyFromType(note)
+	if err != nil {
+		return err
+	}
+	reply.ReviewID = review.ID
+	reply.InReplyTo = inReplyTo.String()
+	if inReplyToAuthor != nil {
+		reply.InReplyToAuthor = inReplyToAuthor.String()
+	}
+	reply.User = *user
+	reply.UserID = user.ID
+	return d.repliesRepo.Create(reply)
+}
+
+// createReview saves a review from another server under its original IRI, along with the remote user who wrote it and the book it's about. Notes that aren't public or aren't about a book, such as replies from Mastodon, aren't kept.
+func (d *Database) createReview(c context.Context, note vocab.ActivityStreamsNote) error {
+	document := model.BookDocument(note)
+	if document == nil {
+		log.Printf("ignoring note %v, which isn't about a book", note.GetJSONLDId().Get())
+		return nil
+	}
+	if !isPublic(note) {
+		// reviews are shown to everyone, so only public ones can be kept
+		log.Printf("ignoring review %v, which isn't public", note.GetJSONLDId().Get())
+		return nil
+	}
+	actor, person, err := Attribution(note)
+	if err != nil {
+		return err
+	}
+	if !signedByAuthor(c, note.GetJSONLDId().Get(), actor) {
+		return nil
+	}
+	user, err := d.remoteUser(c, actor, person)
+	if err != nil {
+		return err
+	}
+
+	book, err := d.resolveBook(document, note.GetJSONLDId().Get())
+	if err != nil {
+		return err
+	}
+
+	review, err := model.ReviewFromType(note)
+	if err != nil {
+		return err
+	}
+	review.User = *user
+	review.UserID = user.ID
+	review.Book = *book
+	review.BookID = book.OpenLibraryID
+	return d.reviewsRepo.Create(review)
+}
+
+// Attribution returns the actor a Note is attributed to, and their profile if it's embedded.
+func Attribution(note vocab.ActivityStreamsNote) (*url.URL, vocab.ActivityStreamsPerson, error) {
+	attributedTo := note.GetActivityStreamsAttributedTo()
+	if attributedTo == nil || attributedTo.Len() == 0 {
+		return nil, nil, fmt.Errorf("note %v has no author", note.GetJSONLDId().Get())
+	}
+	actor, err := pub.ToId(attributedTo.At(0))
+	if err != nil {
+		return nil, nil, err
+	}
+	var person vocab.ActivityStreamsPerson
+	if attributedTo.At(0).IsActivityStreamsPerson() {
+		person = attributedTo.At(0).GetActivityStreamsPerson()
+	}
+	return actor, person, nil
+}
+
+// isPublic returns whether an object is addressed to the public.
+func isPublic(t vocab.Type) bool {
+	for _, iri := range Audience(t) {
+		if pub.IsPublic(iri.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteUser returns the user for a remote actor, creating them if they haven't been seen before and updating their profile if it's known. A profile is only taken from the actor themselves, since anyone else could embed whatever they liked.
+func (d *Database) remoteUser(c context.Context, actor *url.URL, person vocab.ActivityStreamsPerson) (*model.User, error) {
+	if signer, ok := c.Value(model.ContextKeySignedBy).(*url.URL); !ok || signer == nil || signer.String() != actor.String() {
+		person = nil
+	}
+	user, err := d.usersRepo.GetByIRI(actor.String())
+	if errors.Is(err, users.ErrNotFound) {
+		user = model.NewRemoteUser(actor, person)
+	} else if err != nil {
+		return nil, err
+	} else if person != nil {
+		user.UpdateFromType(person)
+	} else {
+		return user, nil
+	}
+	return d.usersRepo.Save(user)
+}
+
+// resolveBook returns the book a document is about, creating it and its authors from the document if we don't have them yet. Covers are left out if the object mentioning the book is from a server whose media is rejected.
+func (d *Database) resolveBook(document vocab.ActivityStreamsDocument, id *url.URL) (*model.Book, error) {
+	book, err := model.BookFromType(document)
+	if err != nil {
+		return nil, err
+	}
+	if d.rejectsMedia(id.Hostname()) {
+		book.Covers = nil
+	}
+	if existing, err := d.booksRepo.GetByID(book.OpenLibraryID); err == nil {
+		return existing, nil
+	} else if !errors.Is(err, books.ErrNotFound) {
+		return nil, err
+	}
+
+	for i, author := range book.Authors {
+		if existing, err := d.authorsRepo.GetByID(author.OpenLibraryID); err == nil {
+			book.Authors[i] = *existing
+		} else if _, err := d.authorsRepo.Create(&book.Authors[i]); err != nil {
+			return nil, err
+		}
+	}
+	return d.booksRepo.Create(book)
 }
 
 // Update sets an existing entry to the database based on the value's
@@ -343,23 +872,184 @@ func (d *Database) Create(c context.Context, asType vocab.Type) error {
 //
 // The library makes this call only after acquiring a lock first.
 func (d *Database) Update(c context.Context, asType vocab.Type) error {
-	// TODO
-	log.Println("update")
+	switch t := asType.(type) {
+	case vocab.ActivityStreamsPerson:
+		return d.updateActor(c, t)
+	case vocab.ActivityStreamsNote:
+		return d.updateNote(c, t)
+	}
+	log.Printf("not updating %s %v", asType.GetTypeName(), asType.GetJSONLDId().Get())
 	return nil
 }
 
+// updateActor updates the profile of a remote actor, which only they may do. Actors we haven't seen before aren't stored until they do something.
+func (d *Database) updateActor(c context.Context, person vocab.ActivityStreamsPerson) error {
+	if person.GetJSONLDId() == nil {
+		return pub.ErrObjectRequired
+	}
+	user, err := d.usersRepo.GetByIRI(person.GetJSONLDId().Get().String())
+	if errors.Is(err, users.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !signedBy(c, "update", user.HumanID, user.HumanID) {
+		return nil
+	}
+	user.UpdateFromType(person)
+	_, err = d.usersRepo.Save(user)
+	return err
+}
+
+// updateNote replaces the text of a reply from another server with the edited one, or updates the review the Note is. Only the author may edit either.
+func (d *Database) updateNote(c context.Context, note vocab.ActivityStreamsNote) error {
+	edited, err := model.ReplyFromType(note)
+	if err != nil {
+		return err
+	}
+	reply, err := d.repliesRepo.GetByURI(edited.URI)
+	if errors.Is(err, replies.ErrNotFound) {
+		return d.updateReview(c, note)
+	} else if err != nil {
+		return err
+	}
+	if !signedBy(c, "update", reply.URI, reply.User.HumanID) {
+		return nil
+	}
+	reply.Text = edited.Text
+	return d.repliesRepo.Save(reply)
+}
+
+// updateReview replaces the text, spoiler and rating of a review from another server with the edited ones. Notes we haven't seen before are created.
+func (d *Database) updateReview(c context.Context, note vocab.ActivityStreamsNote) error {
+	edited, err := model.ReviewFromType(note)
+	if err != nil {
+		return err
+	}
+	review, err := d.reviewsRepo.GetByURI(edited.URI)
+	if errors.Is(err, reviews.ErrNotFound) {
+		return d.createNote(c, note)
+	} else if err != nil {
+		return err
+	}
+	if !signedBy(c, "update", review.URI, review.User.HumanID) {
+		return nil
+	}
+	review.Text = edited.Text
+	review.Spoiler = edited.Spoiler
+	review.Rating = edited.Rating
+	return d.reviewsRepo.Save(review)
+}
+
 // Delete removes the entry with the given id.
 //
 // Delete is only called for federated objects. Deletes from the Social
 // Protocol instead call Update to create a Tombstone.
 //
+// Only the author of an object, or an actor themselves, may delete it.
+//
 // The library makes this call only after acquiring a lock first.
 func (d *Database) Delete(c context.Context, id *url.URL) error {
-	// TODO
-	log.Println("delete")
+	if owns, err := d.Owns(c, id); err != nil {
+		return err
+	} else if owns {
+		log.Printf("refusing to delete %v, which is owned by this server", id)
+		return nil
+	}
+
+	if read, err := d.readsRepo.GetByID(id.String()); err == nil {
+		if !signedBy(c, "delete", read.ID, read.User.HumanID) {
+			return nil
+		}
+		if err := d.reactionsRepo.DeleteByObject(read.ID); err != nil {
+			return err
+		}
+		return d.readsRepo.Delete(read)
+	}
+
+	if review, err := d.reviewsRepo.GetByURI(id.String()); err == nil {
+		if !signedBy(c, "delete", review.URI, review.User.HumanID) {
+			return nil
+		}
+		if err := d.reactionsRepo.DeleteByObject(review.URI); err != nil {
+			return err
+		}
+		return d.reviewsRepo.Delete(review)
+	} else if !errors.Is(err, reviews.ErrNotFound) {
+		return err
+	}
+
+	if reply, err := d.repliesRepo.GetByURI(id.String()); err == nil {
+		if !signedBy(c, "delete", reply.URI, reply.User.HumanID) {
+			return nil
+		}
+		return d.repliesRepo.Delete(reply)
+	} else if !errors.Is(err, replies.ErrNotFound) {
+		return err
+	}
+
+	if user, err := d.usersRepo.GetByIRI(id.String()); err == nil {
+		if !signedBy(c, "delete", user.HumanID, user.HumanID) {
+			return nil
+		}
+		return d.deleteActor(user)
+	} else if !errors.Is(err, users.ErrNotFound) {
+		return err
+	}
+
+	log.Printf("nothing to delete at %v", id)
 	return nil
 }
 
+// deleteActor removes a remote actor who deleted their account, along with their follows of local users, their likes and announces, and everything they've read, reviewed or replied.
+func (d *Database) deleteActor(user *model.User) error {
+	if err := d.reactionsRepo.DeleteByActor(user.HumanID); err != nil {
+		return err
+	}
+	if err := d.followersRepo.DeleteByActor(user.HumanID); err != nil {
+		return err
+	}
+	if err := d.followingRepo.DeleteByActor(user.HumanID); err != nil {
+		return err
+	}
+	// reads, reviews and replies are removed along with the user by the database
+	return d.usersRepo.Delete(user)
+}
+
+// signedBy returns whether the request being handled was signed by the author of an object it changes, logging it if not. go-fed only checks that an activity's id is on the same server as its object, which whoever sends it chooses, so this is what stops one actor editing or deleting another's.
+func signedBy(c context.Context, action, object, author string) bool {
+	signer, ok := c.Value(model.ContextKeySignedBy).(*url.URL)
+	if !ok || signer == nil {
+		log.Printf("refusing to %s %s without a signature from its author %s", action, object, author)
+		return false
+	}
+	if signer.String() != author {
+		log.Printf("refusing to %s %s for %s, since its author is %s", action, object, signer, author)
+		return false
+	}
+	return true
+}
+
+// signedByAuthor returns whether the request being handled was signed by the author of a new object, and the object is on their server, logging it if not. Otherwise anyone could attribute an object to someone else, or give it an id on a server that didn't make it.
+func signedByAuthor(c context.Context, id, author *url.URL) bool {
+	if !signedBy(c, "create", id.String(), author.String()) {
+		return false
+	}
+	if !strings.EqualFold(id.Host, author.Host) {
+		log.Printf("refusing to create %s, which isn't on the server of its author %s", id, author)
+		return false
+	}
+	return true
+}
+
+// Bury replaces a local object with a Tombstone, so that anyone who fetches it afterwards is told that it's gone.
+func (d *Database) Bury(c context.Context, id *url.URL, formerType string) error {
+	return d.tombstonesRepo.Create(&model.Tombstone{
+		ID:         id.String(),
+		FormerType: formerType,
+	})
+}
+
 // GetOutbox returns the first ordered collection page of the outbox
 // at the specified IRI, for prepending new items.
 //
@@ -367,27 +1057,17 @@ func (d *Database) Delete(c context.Context, id *url.URL) error {
 func (d *Database) GetOutbox(c context.Context, outboxIRI *url.URL) (outbox vocab.ActivityStreamsOrderedCollectionPage, err error) {
 	log.Printf("getting outbox: %s", outboxIRI.String())
 
-	outbox = streams.NewActivityStreamsOrderedCollectionPage()
-	id := streams.NewJSONLDIdProperty()
-	id.SetIRI(outboxIRI)
-	outbox.SetJSONLDId(id)
-
-	// TODO pagination
-	entries, err := d.outboxRepo.GetByIRI(outboxIRI)
+	entries, err := d.outboxEntries(outboxIRI, Page{})
 	if err != nil {
 		return nil, err
 	}
+	entries, _, _ = Page{}.window(entries)
 
-	orderedItems := streams.NewActivityStreamsOrderedItemsProperty()
-	for _, e := range entries {
-		iri, err := url.Parse(e.URI)
-		if err != nil {
-			log.Printf("error parsing url %s: %s", e.URI, err.Error())
-			continue
-		}
-		orderedItems.AppendIRI(iri)
-	}
-	outbox.SetActivityStreamsOrderedItems(orderedItems)
+	outbox = streams.NewActivityStreamsOrderedCollectionPage()
+	id := streams.NewJSONLDIdProperty()
+	id.SetIRI(outboxIRI)
+	outbox.SetJSONLDId(id)
+	outbox.SetActivityStreamsOrderedItems(iriItems(entries))
 
 	return
 }
@@ -411,31 +1091,27 @@ func (d *Database) SetOutbox(c context.Context, outbox vocab.ActivityStreamsOrde
 		return err
 	}
 
-	existing := make(map[string]bool)
-	existingItems, err := d.outboxRepo.GetByIRI(outboxIRI)
-	if err != nil {
-		return err
-	}
-	for _, item := range existingItems {
-		existing[item.URI] = true
-	}
 	for item := items.Begin(); item != nil; item = item.Next() {
-		iri := item.GetIRI().String()
-		if _, exists := existing[iri]; !exists {
-			if err := d.outboxRepo.Create(&model.OutboxEntry{
-				Base: model.Base{
-					ID: uuid.New(),
-				},
-				User:      *user,
-				OutboxIRI: outboxIRI.String(),
-				URI:       iri,
-			}); err != nil {
-				return err
-			}
+		iri, err := pub.ToId(item)
+		if err != nil {
+			return err
+		}
+		if exists, err := d.outboxRepo.Contains(outboxIRI, iri); err != nil {
+			return err
+		} else if exists {
+			continue
+		}
+		if err := d.outboxRepo.Create(&model.OutboxEntry{
+			Base: model.Base{
+				ID: uuid.New(),
+			},
+			User:      *user,
+			OutboxIRI: outboxIRI.String(),
+			URI:       iri.String(),
+		}); err != nil {
+			return err
 		}
 	}
-
-	// TODO remove what doesn;t exist (requires setting in get)
 	return nil
 }
 
@@ -445,15 +1121,22 @@ func (d *Database) SetOutbox(c context.Context, outbox vocab.ActivityStreamsOrde
 //
 // The go-fed library will handle setting the 'id' property on the
 // activity or object provided with the value returned.
+//
+// Objects that were saved before being sent, such as reads, keep the id they were saved with.
 func (d *Database) NewID(c context.Context, t vocab.Type) (id *url.URL, err error) {
+	if existing := t.GetJSONLDId(); existing != nil && existing.GetIRI() != nil {
+		if owns, _ := d.Owns(c, existing.GetIRI()); owns {
+			return existing.GetIRI(), nil
+		}
+	}
+
 	userI := c.Value(model.ContextKeyAuthenticatedUser)
 	if userI == nil {
 		return nil, fmt.Errorf("no authenticated user in context")
 	}
 	user := userI.(*model.User)
 
-	id, err = url.Parse(fmt.Sprintf("%s/@%s/read/%v", d.baseURL, strings.ToLower(user.Username), uuid.New().String()))
-	log.Printf("*** URL *** %s", id)
+	id, err = url.Parse(fmt.Sprintf("%s/user/%s/%s/%v", d.baseURL, strings.ToLower(user.Username), strings.ToLower(t.GetTypeName()), uuid.New().String()))
 
 	return
 }
@@ -465,10 +1148,20 @@ func (d *Database) NewID(c context.Context, t vocab.Type) (id *url.URL, err erro
 //
 // The library makes this call only after acquiring a lock first.
 func (d *Database) Followers(c context.Context, actorIRI *url.URL) (followers vocab.ActivityStreamsCollection, err error) {
-	// TODO
+	pieces := regexpID.FindStringSubmatch(actorIRI.String())
+	if len(pieces) != 2 {
+		return nil, fmt.Errorf("not a local actor: %v", actorIRI)
+	}
+	user, err := d.usersRepo.GetByUsernameWithFollowers(pieces[1])
+	if err != nil {
+		return
+	}
 
-	log.Println("followers")
-	return
+	var iris []string
+	for _, f := range user.Followers {
+		iris = append(iris, f.ID)
+	}
+	return collection(user.FollowersIRI(), iris), nil
 }
 
 // Following obtains the Following Collection for an actor with the
@@ -477,10 +1170,47 @@ func (d *Database) Followers(c context.Context, actorIRI *url.URL) (followers vo
 // If modified, the library will then call Update.
 //
 // The library makes this call only after acquiring a lock first.
-func (d *Database) Following(c context.Context, actorIRI *url.URL) (followers vocab.ActivityStreamsCollection, err error) {
-	// TODO
-	log.Println("following")
-	return
+func (d *Database) Following(c context.Context, actorIRI *url.URL) (following vocab.ActivityStreamsCollection, err error) {
+	pieces := regexpID.FindStringSubmatch(actorIRI.String())
+	if len(pieces) != 2 {
+		return nil, fmt.Errorf("not a local actor: %v", actorIRI)
+	}
+	user, err := d.usersRepo.GetByUsernameWithFollowing(pieces[1])
+	if err != nil {
+		return
+	}
+
+	var iris []string
+	for _, f := range user.Following {
+		iris = append(iris, f.ID)
+	}
+	return collection(user.FollowingIRI(), iris), nil
+}
+
+// collection returns a Collection of IRIs.
+func collection(id *url.URL, iris []string) vocab.ActivityStreamsCollection {
+	c := streams.NewActivityStreamsCollection()
+
+	idProperty := streams.NewJSONLDIdProperty()
+	idProperty.SetIRI(id)
+	c.SetJSONLDId(idProperty)
+
+	items := streams.NewActivityStreamsItemsProperty()
+	for _, iri := range iris {
+		u, err := url.Parse(iri)
+		if err != nil {
+			log.Printf("error parsing url %s: %s", iri, err.Error())
+			continue
+		}
+		items.AppendIRI(u)
+	}
+	c.SetActivityStreamsItems(items)
+
+	totalItems := streams.NewActivityStreamsTotalItemsProperty()
+	totalItems.Set(items.Len())
+	c.SetActivityStreamsTotalItems(totalItems)
+
+	return c
 }
 
 // Liked obtains the Liked Collection for an actor with the
@@ -489,8 +1219,52 @@ func (d *Database) Following(c context.Context, actorIRI *url.URL) (followers vo
 // If modified, the library will then call Update.
 //
 // The library makes this call only after acquiring a lock first.
-func (d *Database) Liked(c context.Context, actorIRI *url.URL) (followers vocab.ActivityStreamsCollection, err error) {
-	// TODO
-	log.Println("liked")
-	return
+func (d *Database) Liked(c context.Context, actorIRI *url.URL) (liked vocab.ActivityStreamsCollection, err error) {
+	pieces := regexpID.FindStringSubmatch(actorIRI.String())
+	if len(pieces) != 2 {
+		return nil, fmt.Errorf("not a local actor: %v", actorIRI)
+	}
+	user, err := d.usersRepo.GetByUsername(pieces[1])
+	if err != nil {
+		return
+	}
+	list, err := d.reactionsRepo.ListByActor(model.ReactionLike, user.IRI().String())
+	if err != nil {
+		return
+	}
+
+	var iris []string
+	for _, reaction := range list {
+		iris = append(iris, reaction.ObjectIRI)
+	}
+	return collection(user.LikedIRI(), iris), nil
+}
+
+// InboxOwner returns the local user who owns the inbox with the given IRI. It returns nil if it isn't a local user's inbox.
+func (d *Database) InboxOwner(c context.Context, inboxIRI *url.URL) (*model.User, error) {
+	pieces := regexpInbox.FindStringSubmatch(inboxIRI.Path)
+	if len(pieces) != 2 {
+		return nil, nil
+	}
+	user, err := d.usersRepo.GetByUsername(pieces[1])
+	if errors.Is(err, users.ErrNotFound) {
+		return nil, nil
+	}
+	return user, err
+}
+
+// LocalUser returns the local user with the given actor IRI. It returns nil if the IRI isn't a local user's.
+func (d *Database) LocalUser(c context.Context, actorIRI *url.URL) (*model.User, error) {
+	if owns, err := d.Owns(c, actorIRI); err != nil || !owns {
+		return nil, err
+	}
+	pieces := regexpID.FindStringSubmatch(actorIRI.Path)
+	if len(pieces) != 2 {
+		return nil, nil
+	}
+	user, err := d.usersRepo.GetByUsername(pieces[1])
+	if errors.Is(err, users.ErrNotFound) {
+		return nil, nil
+	}
+	return user, err
 }
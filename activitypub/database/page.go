@@ -0,0 +1,239 @@
+package database
+
+import (
+	"context"
+	"log"
+	"net/url"
+
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+	"github.com/google/uuid"
+)
+
+// A Page identifies a page of an inbox or outbox. Pages are newest first, and the zero value is the first page.
+type Page struct {
+	// MaxID requests the entries immediately older than the entry with this id.
+	MaxID uuid.UUID
+
+	// MinID requests the entries immediately newer than the entry with this id.
+	MinID uuid.UUID
+
+	// Last requests the page with the oldest entries.
+	Last bool
+}
+
+// PageFromQuery reads which page of a collection a request is for from its query string. It returns false if the request is for the collection itself.
+func PageFromQuery(query url.Values) (p Page, ok bool) {
+	switch query.Get("page") {
+	case "":
+		return
+	case "last":
+		p.Last = true
+		return p, true
+	}
+
+	if id, err := uuid.Parse(query.Get("max_id")); err == nil {
+		p.MaxID = id
+	} else if id, err := uuid.Parse(query.Get("min_id")); err == nil {
+		p.MinID = id
+	}
+	return p, true
+}
+
+// IRI returns the IRI of the page of a collection.
+func (p Page) IRI(collection *url.URL) *url.URL {
+	query := url.Values{}
+	if p.Last {
+		query.Set("page", "last")
+	} else {
+		query.Set("page", "true")
+	}
+	if p.MaxID != uuid.Nil {
+		query.Set("max_id", p.MaxID.String())
+	} else if p.MinID != uuid.Nil {
+		query.Set("min_id", p.MinID.String())
+	}
+
+	u := *collection
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// entry is an item in an inbox or outbox.
+type entry struct {
+	id  uuid.UUID
+	uri string
+}
+
+// window trims the entries fetched for a page, which include one more than fits on it, and determines whether there are newer and older entries beyond it.
+func (p Page) window(entries []entry) (page []entry, newer bool, older bool) {
+	more := len(entries) > ResultsPerPage
+	if p.MinID != uuid.Nil || p.Last {
+		// the extra entry was fetched from the newer side
+		if more {
+			entries = entries[1:]
+		}
+		return entries, more, p.MinID != uuid.Nil
+	}
+
+	if more {
+		entries = entries[:ResultsPerPage]
+	}
+	return entries, p.MaxID != uuid.Nil, more
+}
+
+// InboxCollection returns the OrderedCollection of a user's inbox, which links to its first and last pages.
+func (d *Database) InboxCollection(c context.Context, inboxIRI *url.URL) (vocab.ActivityStreamsOrderedCollection, error) {
+	total, err := d.inboxRepo.Count(inboxIRI)
+	if err != nil {
+		return nil, err
+	}
+	return orderedCollection(inboxIRI, total), nil
+}
+
+// InboxPage returns a page of a user's inbox. Only the IRIs of activities are included, since most of them are stored elsewhere.
+func (d *Database) InboxPage(c context.Context, inboxIRI *url.URL, p Page) (vocab.ActivityStreamsOrderedCollectionPage, error) {
+	entries, err := d.inboxEntries(inboxIRI, p)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, newer, older := p.window(entries)
+	return orderedCollectionPage(inboxIRI, p, entries, newer, older, iriItems(entries)), nil
+}
+
+func (d *Database) inboxEntries(inboxIRI *url.URL, p Page) (entries []entry, err error) {
+	var fetched []*model.InboxEntry
+	if p.Last {
+		fetched, err = d.inboxRepo.Last(inboxIRI, ResultsPerPage+1)
+	} else {
+		fetched, err = d.inboxRepo.Page(inboxIRI, p.MaxID, p.MinID, ResultsPerPage+1)
+	}
+	for _, e := range fetched {
+		entries = append(entries, entry{id: e.ID, uri: e.URI})
+	}
+	return
+}
+
+// OutboxCollection returns the OrderedCollection of a user's outbox, which links to its first and last pages.
+func (d *Database) OutboxCollection(c context.Context, outboxIRI *url.URL) (vocab.ActivityStreamsOrderedCollection, error) {
+	total, err := d.outboxRepo.Count(outboxIRI)
+	if err != nil {
+		return nil, err
+	}
+	return orderedCollection(outboxIRI, total), nil
+}
+
+// OutboxPage returns a page of a user's outbox as seen by the actor identified by requester, who is nil for anonymous requests. Activities we have are embedded, and those the requester isn't allowed to see are left out.
+func (d *Database) OutboxPage(c context.Context, outboxIRI *url.URL, p Page, requester *url.URL) (vocab.ActivityStreamsOrderedCollectionPage, error) {
+	entries, err := d.outboxEntries(outboxIRI, p)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, newer, older := p.window(entries)
+	items := streams.NewActivityStreamsOrderedItemsProperty()
+	for _, e := range entries {
+		iri, err := url.Parse(e.uri)
+		if err != nil {
+			log.Printf("error parsing url %s: %s", e.uri, err.Error())
+			continue
+		}
+		t, err := d.Get(c, iri)
+		if err != nil {
+			items.AppendIRI(iri)
+			continue
+		}
+		if ok, err := d.canViewType(c, t, requester); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		if err := items.AppendType(t); err != nil {
+			log.Printf("error adding %s to outbox page: %s", iri, err.Error())
+		}
+	}
+	return orderedCollectionPage(outboxIRI, p, entries, newer, older, items), nil
+}
+
+func (d *Database) outboxEntries(outboxIRI *url.URL, p Page) (entries []entry, err error) {
+	var fetched []*model.OutboxEntry
+	if p.Last {
+		fetched, err = d.outboxRepo.Last(outboxIRI, ResultsPerPage+1)
+	} else {
+		fetched, err = d.outboxRepo.Page(outboxIRI, p.MaxID, p.MinID, ResultsPerPage+1)
+	}
+	for _, e := range fetched {
+		entries = append(entries, entry{id: e.ID, uri: e.URI})
+	}
+	return
+}
+
+// orderedCollection returns an OrderedCollection that is split into pages.
+func orderedCollection(id *url.URL, total int) vocab.ActivityStreamsOrderedCollection {
+	c := streams.NewActivityStreamsOrderedCollection()
+
+	idProperty := streams.NewJSONLDIdProperty()
+	idProperty.SetIRI(id)
+	c.SetJSONLDId(idProperty)
+
+	totalItems := streams.NewActivityStreamsTotalItemsProperty()
+	totalItems.Set(total)
+	c.SetActivityStreamsTotalItems(totalItems)
+
+	first := streams.NewActivityStreamsFirstProperty()
+	first.SetIRI(Page{}.IRI(id))
+	c.SetActivityStreamsFirst(first)
+
+	last := streams.NewActivityStreamsLastProperty()
+	last.SetIRI(Page{Last: true}.IRI(id))
+	c.SetActivityStreamsLast(last)
+
+	return c
+}
+
+// orderedCollectionPage returns a page of a collection, linking to the pages of older and newer entries if there are any.
+func orderedCollectionPage(collection *url.URL, p Page, entries []entry, newer, older bool, items vocab.ActivityStreamsOrderedItemsProperty) vocab.ActivityStreamsOrderedCollectionPage {
+	page := streams.NewActivityStreamsOrderedCollectionPage()
+
+	id := streams.NewJSONLDIdProperty()
+	id.SetIRI(p.IRI(collection))
+	page.SetJSONLDId(id)
+
+	partOf := streams.NewActivityStreamsPartOfProperty()
+	partOf.SetIRI(collection)
+	page.SetActivityStreamsPartOf(partOf)
+
+	page.SetActivityStreamsOrderedItems(items)
+
+	if len(entries) == 0 {
+		return page
+	}
+	if older {
+		next := streams.NewActivityStreamsNextProperty()
+		next.SetIRI(Page{MaxID: entries[len(entries)-1].id}.IRI(collection))
+		page.SetActivityStreamsNext(next)
+	}
+	if newer {
+		prev := streams.NewActivityStreamsPrevProperty()
+		prev.SetIRI(Page{MinID: entries[0].id}.IRI(collection))
+		page.SetActivityStreamsPrev(prev)
+	}
+	return page
+}
+
+// iriItems returns the IRIs of entries as the items of a page.
+func iriItems(entries []entry) vocab.ActivityStreamsOrderedItemsProperty {
+	items := streams.NewActivityStreamsOrderedItemsProperty()
+	for _, e := range entries {
+		iri, err := url.Parse(e.uri)
+		if err != nil {
+			log.Printf("error parsing url %s: %s", e.uri, err.Error())
+			continue
+		}
+		items.AppendIRI(iri)
+	}
+	return items
+}
@@ -0,0 +1,163 @@
+package activitypub
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+)
+
+// broadcastable is an activity that can be sent to the public and a user's followers.
+type broadcastable interface {
+	addressable
+	SetActivityStreamsCc(vocab.ActivityStreamsCcProperty)
+}
+
+// UpdateReview saves a review its author has edited, and sends the new version to everyone who could see the old one.
+func (ap *ActivityPub) UpdateReview(review *model.Review) error {
+	if err := ap.reviewsRepo.Save(review); err != nil {
+		return err
+	}
+	ap.Send(&review.User, ap.broadcast(streams.NewActivityStreamsUpdate(), &review.User, review.ToType(), true))
+	return nil
+}
+
+// DeleteReview removes a review and tells everyone who could see it that it's gone.
+func (ap *ActivityPub) DeleteReview(review *model.Review) error {
+	if err := ap.deleteReview(review); err != nil {
+		return err
+	}
+	tombstone := &model.Tombstone{ID: review.IRI().String(), FormerType: "Note"}
+	ap.Send(&review.User, ap.broadcast(streams.NewActivityStreamsDelete(), &review.User, tombstone.ToType(), true))
+	return nil
+}
+
+// deleteReview replaces a review, and the activity that created it, with tombstones. Likes and announces of it go with it.
+func (ap *ActivityPub) deleteReview(review *model.Review) error {
+	c := context.Background()
+	iri := review.IRI()
+	if err := ap.db.Bury(c, iri, "Note"); err != nil {
+		return err
+	}
+	create, err := url.Parse(iri.String() + "/activity")
+	if err != nil {
+		return err
+	}
+	if err := ap.db.Bury(c, create, "Create"); err != nil {
+		return err
+	}
+	if err := ap.reactionsRepo.DeleteByObject(iri.String()); err != nil {
+		return err
+	}
+	return ap.reviewsRepo.Delete(review)
+}
+
+// DeleteRead removes a read and tells everyone who could see it that it's gone.
+func (ap *ActivityPub) DeleteRead(read *model.Read) error {
+	if err := ap.deleteRead(read); err != nil {
+		return err
+	}
+	tombstone := &model.Tombstone{ID: read.ID, FormerType: "Read"}
+	ap.Send(&read.User, ap.broadcast(streams.NewActivityStreamsDelete(), &read.User, tombstone.ToType(), !read.FollowersOnly))
+	return nil
+}
+
+// deleteRead replaces a read with a tombstone. Likes and announces of it go with it.
+func (ap *ActivityPub) deleteRead(read *model.Read) error {
+	iri, err := url.Parse(read.ID)
+	if err != nil {
+		return err
+	}
+	if err := ap.db.Bury(context.Background(), iri, "Read"); err != nil {
+		return err
+	}
+	if err := ap.reactionsRepo.DeleteByObject(read.ID); err != nil {
+		return err
+	}
+	return ap.readsRepo.Delete(read)
+}
+
+// DeleteAccount deletes a local user and everything they've read, reviewed and replied. Other servers are sent a single Delete of the actor, which they take to mean all of their content too. It is only queued, and the user is removed straight away, so they're only marked as deleted: the delivery workers still load them to sign the Delete with their key.
+func (ap *ActivityPub) DeleteAccount(user *model.User) error {
+	reads, err := ap.readsRepo.Get(user)
+	if err != nil {
+		return err
+	}
+	for _, read := range reads {
+		if err := ap.deleteRead(read); err != nil {
+			return err
+		}
+	}
+
+	reviews, err := ap.reviewsRepo.ListByUser(user)
+	if err != nil {
+		return err
+	}
+	for i := range reviews {
+		if err := ap.deleteReview(&reviews[i]); err != nil {
+			return err
+		}
+	}
+
+	replies, err := ap.repliesRepo.ListByUser(user)
+	if err != nil {
+		return err
+	}
+	for i := range replies {
+		if err := ap.deleteReply(&replies[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := ap.send(user, ap.broadcast(streams.NewActivityStreamsDelete(), user, user.ToType(), true)); err != nil {
+		return err
+	}
+	if err := ap.db.Bury(context.Background(), user.IRI(), "Person"); err != nil {
+		return err
+	}
+	if err := ap.followingRepo.DeleteByActor(user.IRI().String()); err != nil {
+		return err
+	}
+	if err := ap.followersRepo.DeleteByActor(user.IRI().String()); err != nil {
+		return err
+	}
+	if err := ap.reactionsRepo.DeleteByActor(user.IRI().String()); err != nil {
+		return err
+	}
+	return ap.usersRepo.Delete(user)
+}
+
+// broadcast fills in an activity sent by a local user about an object, addressed to their followers and, unless it's followers only, the public.
+func (ap *ActivityPub) broadcast(a broadcastable, user *model.User, object vocab.Type, public bool) vocab.Type {
+	id := streams.NewJSONLDIdProperty()
+	id.SetIRI(ap.newActivityIRI(user, strings.ToLower(a.GetTypeName())))
+	a.SetJSONLDId(id)
+
+	actor := streams.NewActivityStreamsActorProperty()
+	actor.AppendIRI(user.IRI())
+	a.SetActivityStreamsActor(actor)
+
+	objectProperty := streams.NewActivityStreamsObjectProperty()
+	if err := objectProperty.AppendType(object); err != nil {
+		log.Printf("error adding %s to %s: %s", object.GetTypeName(), a.GetTypeName(), err.Error())
+	}
+	a.SetActivityStreamsObject(objectProperty)
+
+	to := streams.NewActivityStreamsToProperty()
+	cc := streams.NewActivityStreamsCcProperty()
+	if public && model.PublicActivityPubIRI != nil {
+		to.AppendIRI(model.PublicActivityPubIRI)
+		cc.AppendIRI(user.FollowersIRI())
+	} else {
+		to.AppendIRI(user.FollowersIRI())
+	}
+	a.SetActivityStreamsTo(to)
+	a.SetActivityStreamsCc(cc)
+
+	return a
+}
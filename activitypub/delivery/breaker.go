@@ -0,0 +1,73 @@
+package delivery
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBreakerThreshold is how many deliveries to a host must fail in a row before it is considered down.
+	DefaultBreakerThreshold = 5
+
+	// DefaultBreakerCooldown is how long to stop delivering to a host that is down before trying it again.
+	DefaultBreakerCooldown = 10 * time.Minute
+)
+
+// A breaker is a circuit breaker that stops deliveries to hosts that keep failing, so that one dead server doesn't use up every attempt of every delivery queued for it. Once the cooldown is over a single delivery is let through to test the host; if it succeeds the host is considered back up.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	hosts     map[string]*hostState
+}
+
+type hostState struct {
+	failures  int
+	openUntil time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		hosts:     make(map[string]*hostState),
+	}
+}
+
+// allow determines whether a delivery to the host may be attempted. If not, it also returns when the host should be tried again.
+func (b *breaker) allow(host string, now time.Time) (bool, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.hosts[host]
+	if !ok || s.failures < b.threshold {
+		return true, time.Time{}
+	}
+	if now.Before(s.openUntil) {
+		return false, s.openUntil
+	}
+	// let one delivery through to test the host, and hold the others back until it's done
+	s.openUntil = now.Add(b.cooldown)
+	return true, time.Time{}
+}
+
+// success records a successful delivery to the host, closing its circuit.
+func (b *breaker) success(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.hosts, host)
+}
+
+// failure records a failed delivery to the host, opening its circuit if it has failed too many times in a row.
+func (b *breaker) failure(host string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.hosts[host]
+	if !ok {
+		s = new(hostState)
+		b.hosts[host] = s
+	}
+	s.failures++
+	if s.failures >= b.threshold {
+		s.openUntil = now.Add(b.cooldown)
+	}
+}
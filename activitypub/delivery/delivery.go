@@ -0,0 +1,308 @@
+// Package delivery sends activities to remote inboxes in the background. Deliveries are stored in Postgres so that they survive restarts, and failed ones are retried with exponential backoff until they succeed or are given up on.
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/exlibris-fed/exlibris/infrastructure/deliveries"
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/pub"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultWorkers is how many deliveries are attempted at once.
+	DefaultWorkers = 4
+
+	// DefaultMaxAttempts is how many times a delivery is attempted before it is dead-lettered.
+	DefaultMaxAttempts = 10
+
+	// PollInterval is how often the queue is checked for deliveries that are due.
+	PollInterval = 5 * time.Second
+
+	// Lease is how long a claimed delivery is hidden from other workers. It must be longer than a delivery can take.
+	Lease = 5 * time.Minute
+
+	// MinBackoff is how long to wait before retrying a delivery after its first failure. The wait doubles after every further failure.
+	MinBackoff = 30 * time.Second
+
+	// MaxBackoff is the longest to wait between attempts at a delivery.
+	MaxBackoff = 12 * time.Hour
+)
+
+// A TransportFactory creates the transport used to sign and send deliveries on behalf of a user. Its requests should be made with a Client, so that deliveries an inbox refuses are given up on instead of retried.
+type TransportFactory func(user *model.User) (pub.Transport, error)
+
+// A StatusError is returned when an inbox responds to a delivery with a status other than success.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s responded %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// permanent returns whether the inbox refused the delivery, rather than failing to handle it, in which case retrying it won't help. Servers that are asking us to slow down are retried.
+func (e *StatusError) permanent() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500 && e.StatusCode != http.StatusTooManyRequests
+}
+
+// A Client makes the requests of a transport, returning a StatusError for unsuccessful responses so that the queue can tell why a delivery failed.
+type Client struct {
+	pub.HttpClient
+}
+
+// Do sends a request, returning a StatusError if the response isn't successful.
+func (c Client) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, &StatusError{URL: req.URL.String(), StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// A Blocklist determines whether a host must never be delivered to.
+type Blocklist func(host string) bool
+
+// errBlocked is recorded against deliveries to hosts that were blocked after the delivery was queued.
+var errBlocked = errors.New("host is blocked")
+
+// A Queue stores deliveries and has a pool of workers send them.
+type Queue struct {
+	repo         *deliveries.Repository
+	newTransport TransportFactory
+	blocked      Blocklist
+	clock        pub.Clock
+	breaker      *breaker
+	workers      int
+	maxAttempts  int
+	wake         chan struct{}
+}
+
+// New returns a new Queue. Deliveries will not be sent until Start is called.
+func New(repo *deliveries.Repository, newTransport TransportFactory, blocked Blocklist, clock pub.Clock) *Queue {
+	return &Queue{
+		repo:         repo,
+		newTransport: newTransport,
+		blocked:      blocked,
+		clock:        clock,
+		breaker:      newBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown),
+		workers:      DefaultWorkers,
+		maxAttempts:  DefaultMaxAttempts,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Start runs the worker pool until the context is cancelled.
+func (q *Queue) Start(c context.Context) {
+	jobs := make(chan *model.Delivery)
+	for i := 0; i < q.workers; i++ {
+		go func() {
+			for d := range jobs {
+				q.attempt(c, d)
+			}
+		}()
+	}
+	go q.poll(c, jobs)
+}
+
+// Enqueue stores an activity to be delivered to each of the inboxes on behalf of a user, and wakes the workers. Inboxes on blocked hosts are skipped.
+func (q *Queue) Enqueue(user *model.User, b []byte, inboxes []*url.URL) error {
+	var activity struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(b, &activity); err != nil {
+		return err
+	}
+
+	now := q.clock.Now()
+	ds := make([]*model.Delivery, 0, len(inboxes))
+	for _, inbox := range inboxes {
+		if q.blocked(inbox.Hostname()) {
+			log.Printf("not delivering %s to %s, which is blocked", activity.ID, inbox)
+			continue
+		}
+		ds = append(ds, &model.Delivery{
+			Base: model.Base{
+				ID: uuid.New(),
+			},
+			ActivityID:    activity.ID,
+			Inbox:         inbox.String(),
+			Host:          inbox.Host,
+			Payload:       string(b),
+			UserID:        user.ID,
+			Status:        model.DeliveryStatusPending,
+			NextAttemptAt: now,
+		})
+	}
+	if len(ds) == 0 {
+		return nil
+	}
+	if err := q.repo.Create(ds); err != nil {
+		return err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// poll claims deliveries that are due and hands them to the workers.
+func (q *Queue) poll(c context.Context, jobs chan<- *model.Delivery) {
+	defer close(jobs)
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for {
+		for {
+			ds, err := q.repo.Claim(q.clock.Now(), Lease, q.workers)
+			if err != nil {
+				log.Printf("error claiming deliveries: %s", err.Error())
+				break
+			}
+			for _, d := range ds {
+				select {
+				case jobs <- d:
+				case <-c.Done():
+					return
+				}
+			}
+			if len(ds) < q.workers {
+				break
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-q.wake:
+		case <-c.Done():
+			return
+		}
+	}
+}
+
+// attempt sends a delivery and records the outcome. Deliveries the inbox refuses with a 4xx are dead-lettered straight away, and only errors that mean the host is struggling, such as a 5xx, a 429 or not being able to connect, count against it.
+func (q *Queue) attempt(c context.Context, d *model.Delivery) {
+	if u, err := url.Parse(d.Inbox); err == nil && q.blocked(u.Hostname()) {
+		if err := q.repo.Dead(d, errBlocked); err != nil {
+			log.Printf("error dead-lettering delivery %s: %s", d.ID, err.Error())
+		}
+		return
+	}
+
+	now := q.clock.Now()
+	if ok, until := q.breaker.allow(d.Host, now); !ok {
+		if err := q.repo.Postpone(d, until); err != nil {
+			log.Printf("error postponing delivery %s: %s", d.ID, err.Error())
+		}
+		return
+	}
+
+	err := q.send(c, d)
+	if err == nil {
+		q.breaker.success(d.Host)
+		if err := q.repo.Delivered(d); err != nil {
+			log.Printf("error removing delivery %s: %s", d.ID, err.Error())
+		}
+		return
+	}
+
+	d.Attempts++
+	var status *StatusError
+	if errors.As(err, &status) && status.permanent() {
+		// the host is up, it just won't take this delivery
+		q.breaker.success(d.Host)
+		log.Printf("giving up on delivering %s to %s, which refused it: %s", d.ActivityID, d.Inbox, err.Error())
+		if err := q.repo.Dead(d, err); err != nil {
+			log.Printf("error dead-lettering delivery %s: %s", d.ID, err.Error())
+		}
+		return
+	}
+
+	q.breaker.failure(d.Host, now)
+	if d.Attempts >= q.maxAttempts {
+		log.Printf("giving up on delivering %s to %s after %d attempts: %s", d.ActivityID, d.Inbox, d.Attempts, err.Error())
+		if err := q.repo.Dead(d, err); err != nil {
+			log.Printf("error dead-lettering delivery %s: %s", d.ID, err.Error())
+		}
+		return
+	}
+
+	log.Printf("error delivering %s to %s (attempt %d): %s", d.ActivityID, d.Inbox, d.Attempts, err.Error())
+	if err := q.repo.Retry(d, now.Add(backoff(d.Attempts)), err); err != nil {
+		log.Printf("error rescheduling delivery %s: %s", d.ID, err.Error())
+	}
+}
+
+// send makes a single attempt at a delivery. If the inbox responds with an unsuccessful status, the error is a StatusError as long as the transport uses a Client.
+func (q *Queue) send(c context.Context, d *model.Delivery) error {
+	inbox, err := url.Parse(d.Inbox)
+	if err != nil {
+		return err
+	}
+	t, err := q.newTransport(&d.User)
+	if err != nil {
+		return err
+	}
+	c, cancel := context.WithTimeout(c, Lease/2)
+	defer cancel()
+	return t.Deliver(c, []byte(d.Payload), inbox)
+}
+
+// backoff returns how long to wait before the next attempt at a delivery that has failed the given number of times.
+func backoff(attempts int) time.Duration {
+	wait := MinBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= MaxBackoff {
+			return MaxBackoff
+		}
+	}
+	return wait
+}
+
+// Transport is a pub.Transport that queues deliveries instead of sending them immediately. Dereferencing is still done immediately, with the wrapped transport.
+type Transport struct {
+	pub.Transport
+	queue *Queue
+	user  *model.User
+}
+
+// Transport returns a pub.Transport that queues deliveries on behalf of a user. The wrapped transport is used to dereference IRIs.
+func (q *Queue) Transport(user *model.User, t pub.Transport) *Transport {
+	return &Transport{
+		Transport: t,
+		queue:     q,
+		user:      user,
+	}
+}
+
+// Deliver queues a delivery to a single inbox.
+func (t *Transport) Deliver(c context.Context, b []byte, to *url.URL) error {
+	return t.BatchDeliver(c, b, []*url.URL{to})
+}
+
+// BatchDeliver queues deliveries to each of the inboxes.
+func (t *Transport) BatchDeliver(c context.Context, b []byte, recipients []*url.URL) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+	if t.user == nil {
+		return errors.New("no user to deliver on behalf of")
+	}
+	return t.queue.Enqueue(t.user, b, recipients)
+}
@@ -0,0 +1,372 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/exlibris-fed/exlibris/infrastructure/deliveries"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/go-fed/activity/pub"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	now        = time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	deliveryID = uuid.MustParse("8d3bb7a2-3f0c-4d1e-9f5e-2f6f1a0b9c11")
+	userID     = uuid.MustParse("46ef0f8e-3f3c-4a33-a1c5-0e0b7b6f4a10")
+)
+
+type clock struct{}
+
+func (clock) Now() time.Time {
+	return now
+}
+
+// transport records what it was asked to deliver, and on whose behalf.
+type transport struct {
+	mu        sync.Mutex
+	err       error
+	users     []*model.User
+	delivered []*url.URL
+}
+
+func (t *transport) factory(user *model.User) (pub.Transport, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.users = append(t.users, user)
+	return t, nil
+}
+
+func (t *transport) Dereference(c context.Context, iri *url.URL) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (t *transport) Deliver(c context.Context, b []byte, to *url.URL) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.delivered = append(t.delivered, to)
+	return t.err
+}
+
+func (t *transport) BatchDeliver(c context.Context, b []byte, recipients []*url.URL) error {
+	for _, to := range recipients {
+		if err := t.Deliver(c, b, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newQueue(t *testing.T) (*Queue, sqlmock.Sqlmock, *transport) {
+	conn, mock, _ := sqlmock.New()
+	db, _ := gorm.Open("postgres", conn)
+	tr := new(transport)
+	blocked := func(host string) bool {
+		return host == "blocked.example"
+	}
+	return New(deliveries.New(db), tr.factory, blocked, clock{}), mock, tr
+}
+
+func delivery() *model.Delivery {
+	return &model.Delivery{
+		Base: model.Base{
+			ID: deliveryID,
+		},
+		ActivityID:    "https://exlibris.example/user/bob/read/1",
+		Inbox:         "https://mastodon.example/users/alice/inbox",
+		Host:          "mastodon.example",
+		Payload:       `{"id":"https://exlibris.example/user/bob/read/1","type":"Read"}`,
+		User:          model.User{Base: model.Base{ID: userID}, Username: "bob"},
+		UserID:        userID,
+		Status:        model.DeliveryStatusPending,
+		NextAttemptAt: now,
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	q, mock, _ := newQueue(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("INSERT INTO \"deliveries\"")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(deliveryID))
+	mock.ExpectCommit()
+
+	mastodon, _ := url.Parse("https://mastodon.example/users/alice/inbox")
+	blocked, _ := url.Parse("https://blocked.example/users/mallory/inbox")
+	err := q.Enqueue(&model.User{Base: model.Base{ID: userID}}, []byte(`{"id":"https://exlibris.example/user/bob/read/1"}`), []*url.URL{mastodon, blocked})
+
+	assert.NoError(t, err)
+	assert.Len(t, q.wake, 1, "the workers should be woken")
+	assert.NoError(t, mock.ExpectationsWereMet(), "only the inbox that isn't blocked should be queued")
+}
+
+func TestEnqueue_Blocked(t *testing.T) {
+	q, mock, _ := newQueue(t)
+
+	blocked, _ := url.Parse("https://blocked.example/users/mallory/inbox")
+	err := q.Enqueue(&model.User{Base: model.Base{ID: userID}}, []byte(`{"id":"https://exlibris.example/user/bob/read/1"}`), []*url.URL{blocked})
+
+	assert.NoError(t, err)
+	assert.Len(t, q.wake, 0)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttempt_Delivered(t *testing.T) {
+	q, mock, tr := newQueue(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"deliveries\" WHERE \"deliveries\".\"id\" = $1") + "$").
+		WithArgs(deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	q.attempt(context.Background(), delivery())
+
+	if assert.Len(t, tr.delivered, 1) {
+		assert.Equal(t, "https://mastodon.example/users/alice/inbox", tr.delivered[0].String())
+	}
+	if assert.Len(t, tr.users, 1) {
+		assert.Equal(t, "bob", tr.users[0].Username, "deliveries should be signed as the user who queued them")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttempt_Retry(t *testing.T) {
+	q, mock, tr := newQueue(t)
+	tr.err = errors.New("connection refused")
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"deliveries\" SET \"attempts\" = $1, \"last_error\" = $2, \"next_attempt_at\" = $3")).
+		WithArgs(1, "connection refused", now.Add(MinBackoff), sqlmock.AnyArg(), userID, deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	q.attempt(context.Background(), delivery())
+
+	assert.Len(t, tr.delivered, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttempt_Dead(t *testing.T) {
+	q, mock, tr := newQueue(t)
+	tr.err = errors.New("connection refused")
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"deliveries\" SET \"attempts\" = $1, \"last_error\" = $2, \"status\" = $3")).
+		WithArgs(DefaultMaxAttempts, "connection refused", model.DeliveryStatusDead, sqlmock.AnyArg(), userID, deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	d := delivery()
+	d.Attempts = DefaultMaxAttempts - 1
+	q.attempt(context.Background(), d)
+
+	assert.Len(t, tr.delivered, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttempt_Refused(t *testing.T) {
+	q, mock, tr := newQueue(t)
+	tr.err = &StatusError{URL: "https://mastodon.example/users/alice/inbox", StatusCode: http.StatusGone}
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"deliveries\" SET \"attempts\" = $1, \"last_error\" = $2, \"status\" = $3")).
+		WithArgs(1, tr.err.Error(), model.DeliveryStatusDead, sqlmock.AnyArg(), userID, deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	q.breaker = newBreaker(1, time.Minute)
+	q.attempt(context.Background(), delivery())
+
+	assert.Len(t, tr.delivered, 1)
+	ok, _ := q.breaker.allow("mastodon.example", now)
+	assert.True(t, ok, "a host refusing a delivery isn't down")
+	assert.NoError(t, mock.ExpectationsWereMet(), "refused deliveries shouldn't be retried")
+}
+
+func TestAttempt_TooManyRequests(t *testing.T) {
+	q, mock, tr := newQueue(t)
+	tr.err = &StatusError{URL: "https://mastodon.example/users/alice/inbox", StatusCode: http.StatusTooManyRequests}
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"deliveries\" SET \"attempts\" = $1, \"last_error\" = $2, \"next_attempt_at\" = $3")).
+		WithArgs(1, tr.err.Error(), now.Add(MinBackoff), sqlmock.AnyArg(), userID, deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	q.breaker = newBreaker(1, time.Minute)
+	q.attempt(context.Background(), delivery())
+
+	ok, _ := q.breaker.allow("mastodon.example", now)
+	assert.False(t, ok, "a host asking us to slow down should be backed off from")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttempt_Blocked(t *testing.T) {
+	q, mock, tr := newQueue(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"deliveries\" SET \"attempts\" = $1, \"last_error\" = $2, \"status\" = $3")).
+		WithArgs(0, errBlocked.Error(), model.DeliveryStatusDead, sqlmock.AnyArg(), userID, deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	d := delivery()
+	d.Inbox = "https://blocked.example/users/mallory/inbox"
+	d.Host = "blocked.example"
+	q.attempt(context.Background(), d)
+
+	assert.Empty(t, tr.delivered, "hosts blocked since the delivery was queued must not be sent it")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttempt_Postpone(t *testing.T) {
+	q, mock, tr := newQueue(t)
+	q.breaker = newBreaker(1, time.Minute)
+	q.breaker.failure("mastodon.example", now)
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"deliveries\" SET \"next_attempt_at\" = $1")).
+		WithArgs(now.Add(time.Minute), sqlmock.AnyArg(), userID, deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	q.attempt(context.Background(), delivery())
+
+	assert.Empty(t, tr.delivered, "hosts that are down shouldn't be tried")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPoll(t *testing.T) {
+	q, mock, _ := newQueue(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"deliveries\" WHERE \"deliveries\".\"deleted_at\" IS NULL AND ((status = $1 AND next_attempt_at <= $2)) ORDER BY next_attempt_at asc LIMIT 4 FOR UPDATE SKIP LOCKED")+"$").
+		WithArgs(model.DeliveryStatusPending, now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "activity_id", "inbox", "host", "payload", "user_id", "status", "attempts", "next_attempt_at"}).
+			AddRow(deliveryID, "https://exlibris.example/user/bob/read/1", "https://mastodon.example/users/alice/inbox", "mastodon.example", "{}", userID, model.DeliveryStatusPending, 0, now))
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"deliveries\" SET \"next_attempt_at\" = $1")).
+		WithArgs(now.Add(Lease), sqlmock.AnyArg(), deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"users\"  WHERE (\"id\" = $1)")).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow(userID, "bob"))
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jobs := make(chan *model.Delivery)
+	go q.poll(c, jobs)
+
+	select {
+	case d := <-jobs:
+		assert.Equal(t, deliveryID, d.ID)
+		assert.Equal(t, "bob", d.User.Username)
+	case <-time.After(time.Second):
+		t.Fatal("claimed delivery wasn't handed to a worker")
+	}
+	cancel()
+	for range jobs {
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttempt_DeletedUser(t *testing.T) {
+	q, mock, tr := newQueue(t)
+	deletedAt := now.Add(-time.Minute)
+	mock.ExpectBegin()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"deliveries\"")).
+		WithArgs(model.DeliveryStatusPending, now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "activity_id", "inbox", "host", "payload", "user_id", "status", "attempts", "next_attempt_at"}).
+			AddRow(deliveryID, "https://exlibris.example/user/bob/delete/1", "https://mastodon.example/users/alice/inbox", "mastodon.example", `{"type":"Delete"}`, userID, model.DeliveryStatusPending, 0, now))
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"deliveries\" SET \"next_attempt_at\" = $1")).
+		WithArgs(now.Add(Lease), sqlmock.AnyArg(), deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"users\"  WHERE (\"id\" = $1)") + "$").
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "local", "deleted_at"}).AddRow(userID, "bob", true, deletedAt))
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"deliveries\" WHERE \"deliveries\".\"id\" = $1") + "$").
+		WithArgs(deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ds, err := q.repo.Claim(now, Lease, q.workers)
+	if !assert.NoError(t, err) || !assert.Len(t, ds, 1) {
+		return
+	}
+	q.attempt(context.Background(), ds[0])
+
+	if assert.Len(t, tr.users, 1) {
+		assert.Equal(t, "bob", tr.users[0].Username, "the Delete of an account should be signed by the deleted user")
+		assert.NotNil(t, tr.users[0].DeletedAt)
+	}
+	assert.Len(t, tr.delivered, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient(t *testing.T) {
+	tests := map[int]bool{
+		http.StatusOK:                  true,
+		http.StatusAccepted:            true,
+		http.StatusBadRequest:          false,
+		http.StatusTooManyRequests:     false,
+		http.StatusInternalServerError: false,
+	}
+	for code, success := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		}))
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/inbox", nil)
+		resp, err := Client{HttpClient: server.Client()}.Do(req)
+		server.Close()
+
+		if success {
+			if assert.NoError(t, err, code) {
+				assert.Equal(t, code, resp.StatusCode)
+			}
+			continue
+		}
+		var status *StatusError
+		if assert.True(t, errors.As(err, &status), code) {
+			assert.Equal(t, code, status.StatusCode)
+			assert.Equal(t, server.URL+"/inbox", status.URL)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, MinBackoff, backoff(1))
+	assert.Equal(t, 2*MinBackoff, backoff(2))
+	assert.Equal(t, 8*MinBackoff, backoff(4))
+	assert.Equal(t, MaxBackoff, backoff(DefaultMaxAttempts*10))
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	b := newBreaker(2, time.Minute)
+
+	b.failure("down.example", now)
+	ok, _ := b.allow("down.example", now)
+	assert.True(t, ok, "should stay closed below the threshold")
+
+	b.failure("down.example", now)
+	ok, until := b.allow("down.example", now)
+	assert.False(t, ok, "should open at the threshold")
+	assert.Equal(t, now.Add(time.Minute), until)
+
+	ok, _ = b.allow("up.example", now)
+	assert.True(t, ok, "other hosts should be unaffected")
+
+	later := now.Add(time.Minute)
+	ok, _ = b.allow("down.example", later)
+	assert.True(t, ok, "should let a delivery through after the cooldown")
+	ok, _ = b.allow("down.example", later)
+	assert.False(t, ok, "should only let one delivery through while testing the host")
+
+	b.success("down.example")
+	ok, _ = b.allow("down.example", later)
+	assert.True(t, ok, "should close once a delivery succeeds")
+}
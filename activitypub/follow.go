@@ -0,0 +1,315 @@
+package activitypub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/exlibris-fed/exlibris/infrastructure/followers"
+	"github.com/exlibris-fed/exlibris/infrastructure/following"
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/pub"
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+	"github.com/google/uuid"
+)
+
+type actorer interface {
+	GetActivityStreamsActor() vocab.ActivityStreamsActorProperty
+}
+
+type objecter interface {
+	GetActivityStreamsObject() vocab.ActivityStreamsObjectProperty
+}
+
+// addressable is an activity that can be sent from one actor to another.
+type addressable interface {
+	vocab.Type
+	SetActivityStreamsActor(vocab.ActivityStreamsActorProperty)
+	SetActivityStreamsObject(vocab.ActivityStreamsObjectProperty)
+	SetActivityStreamsTo(vocab.ActivityStreamsToProperty)
+}
+
+// Follow sends a follow request from a local user to another actor. If the user already follows or has asked to follow them, the request is sent again.
+func (ap *ActivityPub) Follow(user *model.User, actorIRI *url.URL) (*model.Following, error) {
+	f, err := ap.followingRepo.Get(user, actorIRI.String())
+	if errors.Is(err, following.ErrNotFound) {
+		f = &model.Following{
+			ID:       actorIRI.String(),
+			User:     *user,
+			UserID:   user.ID,
+			FollowID: ap.newActivityIRI(user, "follow").String(),
+		}
+		err = ap.followingRepo.Save(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ap.Send(user, f.ToType())
+	return f, nil
+}
+
+// Unfollow stops a local user following another actor, or withdraws their request to.
+func (ap *ActivityPub) Unfollow(user *model.User, actorIRI *url.URL) error {
+	f, err := ap.followingRepo.Get(user, actorIRI.String())
+	if err != nil {
+		return err
+	}
+	if err := ap.followingRepo.Delete(f); err != nil {
+		return err
+	}
+
+	ap.Send(user, ap.address(streams.NewActivityStreamsUndo(), user, actorIRI, f.ToType()))
+	return nil
+}
+
+// ApproveFollower accepts a pending request to follow a local user.
+func (ap *ActivityPub) ApproveFollower(user *model.User, actorIRI *url.URL) error {
+	f, err := ap.followersRepo.Get(user, actorIRI.String())
+	if err != nil {
+		return err
+	}
+	if !f.Pending {
+		return nil
+	}
+	f.Pending = false
+	if err := ap.followersRepo.Save(f); err != nil {
+		return err
+	}
+
+	ap.Send(user, ap.address(streams.NewActivityStreamsAccept(), user, actorIRI, f.FollowToType()))
+	return nil
+}
+
+// RejectFollower declines a request to follow a local user, or removes an existing follower.
+func (ap *ActivityPub) RejectFollower(user *model.User, actorIRI *url.URL) error {
+	f, err := ap.followersRepo.Get(user, actorIRI.String())
+	if err != nil {
+		return err
+	}
+	if err := ap.followersRepo.Delete(f); err != nil {
+		return err
+	}
+
+	ap.Send(user, ap.address(streams.NewActivityStreamsReject(), user, actorIRI, f.FollowToType()))
+	return nil
+}
+
+// onFollow handles a request from a remote actor to follow local users. It is accepted straight away unless the user manually approves followers.
+func (ap *ActivityPub) onFollow(c context.Context, follow vocab.ActivityStreamsFollow) error {
+	actor, err := firstActor(follow)
+	if err != nil {
+		return err
+	}
+	followID := follow.GetJSONLDId().Get()
+
+	for _, object := range objectIRIs(follow) {
+		user, err := ap.db.LocalUser(c, object)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			log.Printf("ignoring follow of %s, who isn't a local user", object)
+			continue
+		}
+		if blocked, err := ap.blocksRepo.Blocks(user, actor.String()); err != nil {
+			return err
+		} else if blocked {
+			log.Printf("ignoring follow of %s by %s, who they've blocked", user.Username, actor)
+			continue
+		}
+
+		f, err := ap.followersRepo.Get(user, actor.String())
+		if errors.Is(err, followers.ErrNotFound) {
+			f = &model.Follower{
+				ID:      actor.String(),
+				User:    *user,
+				UserID:  user.ID,
+				Pending: user.ManuallyApprovesFollowers,
+			}
+		} else if err != nil {
+			return err
+		}
+		f.FollowID = followID.String()
+		if err := ap.followersRepo.Save(f); err != nil {
+			return err
+		}
+
+		// a follower who follows again, e.g. because they lost our Accept, gets another one
+		if !f.Pending {
+			ap.Send(user, ap.address(streams.NewActivityStreamsAccept(), user, actor, f.FollowToType()))
+		}
+	}
+	return nil
+}
+
+// onAccept handles an actor accepting a follow request from a local user, or a relay accepting the instance's.
+func (ap *ActivityPub) onAccept(c context.Context, accept vocab.ActivityStreamsAccept) error {
+	actor, err := firstActor(accept)
+	if err != nil {
+		return err
+	}
+	if err := ap.answerRelay(actor, accept, model.RelayAccepted); err != nil {
+		return err
+	}
+	for _, f := range ap.respondedFollows(actor, accept) {
+		f.Accepted = true
+		if err := ap.followingRepo.Save(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onReject handles an actor declining a follow request from a local user, or removing them as a follower. Relays that decline the instance's follow are kept, so that admins can see they did.
+func (ap *ActivityPub) onReject(c context.Context, reject vocab.ActivityStreamsReject) error {
+	actor, err := firstActor(reject)
+	if err != nil {
+		return err
+	}
+	if err := ap.answerRelay(actor, reject, model.RelayRejected); err != nil {
+		return err
+	}
+	for _, f := range ap.respondedFollows(actor, reject) {
+		if err := ap.followingRepo.Delete(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// respondedFollows returns the follows by local users that an Accept or Reject from actor is about. Follows of anyone other than the actor are ignored, since only they may respond.
+func (ap *ActivityPub) respondedFollows(actor *url.URL, response objecter) (follows []*model.Following) {
+	for _, object := range objectIRIs(response) {
+		f, err := ap.followingRepo.GetByFollowID(object.String())
+		if err != nil {
+			if !errors.Is(err, following.ErrNotFound) {
+				log.Printf("error looking up follow %s: %s", object, err.Error())
+			}
+			continue
+		}
+		if f.ID != actor.String() {
+			log.Printf("ignoring response from %s to follow of %s", actor, f.ID)
+			continue
+		}
+		follows = append(follows, f)
+	}
+	return
+}
+
+// onUndo handles an actor undoing one of their activities.
+func (ap *ActivityPub) onUndo(c context.Context, undo vocab.ActivityStreamsUndo) error {
+	actor, err := firstActor(undo)
+	if err != nil {
+		return err
+	}
+
+	objects := undo.GetActivityStreamsObject()
+	if objects == nil {
+		return pub.ErrObjectRequired
+	}
+	for iter := objects.Begin(); iter != objects.End(); iter = iter.Next() {
+		if iter.IsActivityStreamsFollow() {
+			if err := ap.undoFollow(c, actor, iter.GetActivityStreamsFollow()); err != nil {
+				return err
+			}
+		} else if iter.IsActivityStreamsLike() || iter.IsActivityStreamsAnnounce() || iter.IsIRI() {
+			id, err := pub.ToId(iter)
+			if err != nil {
+				return err
+			}
+			if err := ap.undoReaction(actor, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// undoFollow removes actor as a follower of the local users their follow was of.
+func (ap *ActivityPub) undoFollow(c context.Context, actor *url.URL, follow vocab.ActivityStreamsFollow) error {
+	if followActor, err := firstActor(follow); err != nil || followActor.String() != actor.String() {
+		log.Printf("ignoring undo by %s of someone else's follow", actor)
+		return nil
+	}
+
+	for _, object := range objectIRIs(follow) {
+		user, err := ap.db.LocalUser(c, object)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			continue
+		}
+		f, err := ap.followersRepo.Get(user, actor.String())
+		if errors.Is(err, followers.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := ap.followersRepo.Delete(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// address fills in an activity sent by a local user to a single actor, about an object.
+func (ap *ActivityPub) address(a addressable, user *model.User, to *url.URL, object vocab.Type) vocab.Type {
+	id := streams.NewJSONLDIdProperty()
+	id.SetIRI(ap.newActivityIRI(user, strings.ToLower(a.GetTypeName())))
+	a.SetJSONLDId(id)
+
+	actor := streams.NewActivityStreamsActorProperty()
+	actor.AppendIRI(user.IRI())
+	a.SetActivityStreamsActor(actor)
+
+	objectProperty := streams.NewActivityStreamsObjectProperty()
+	if err := objectProperty.AppendType(object); err != nil {
+		log.Printf("error adding %s to %s: %s", object.GetTypeName(), a.GetTypeName(), err.Error())
+	}
+	a.SetActivityStreamsObject(objectProperty)
+
+	toProperty := streams.NewActivityStreamsToProperty()
+	toProperty.AppendIRI(to)
+	a.SetActivityStreamsTo(toProperty)
+
+	return a
+}
+
+// newActivityIRI returns a new id for an activity of the given kind by a local user.
+func (ap *ActivityPub) newActivityIRI(user *model.User, kind string) *url.URL {
+	return &url.URL{
+		Scheme: ap.cfg.Scheme,
+		Host:   ap.cfg.Domain,
+		Path:   fmt.Sprintf("/user/%s/%s/%s", strings.ToLower(user.Username), kind, uuid.New()),
+	}
+}
+
+// firstActor returns the IRI of the actor of an activity.
+func firstActor(a actorer) (*url.URL, error) {
+	actors := a.GetActivityStreamsActor()
+	if actors == nil || actors.Len() == 0 {
+		return nil, fmt.Errorf("activity has no actor")
+	}
+	return pub.ToId(actors.At(0))
+}
+
+// objectIRIs returns the ids of the objects of an activity, whether they're embedded or not.
+func objectIRIs(a objecter) (iris []*url.URL) {
+	objects := a.GetActivityStreamsObject()
+	if objects == nil {
+		return
+	}
+	for iter := objects.Begin(); iter != objects.End(); iter = iter.Next() {
+		if id, err := pub.ToId(iter); err == nil {
+			iris = append(iris, id)
+		}
+	}
+	return
+}
@@ -0,0 +1,131 @@
+package activitypub
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/pub"
+	"github.com/go-fed/activity/streams/vocab"
+)
+
+// maxForwardingDepth is returned as MaxInboxForwardingRecursionDepth.
+const maxForwardingDepth = 3
+
+// forward sends an activity from another server, exactly as it was delivered, on to the followers of the local user whose review it's about, such as a reply in the review's thread, when it's addressed to their followers collection. This lets the author's followers see the whole discussion, even though the replier doesn't know who they are.
+//
+// Collections that aren't the author's followers aren't forwarded to, nor are activities the author wouldn't see because they've blocked the actor. Each activity is only forwarded once, and never to the actor's own server, so that servers forwarding to each other can't loop.
+func (ap *ActivityPub) forward(c context.Context, collections []*url.URL, activity pub.Activity) error {
+	actor, err := firstActor(activity)
+	if err != nil {
+		return err
+	}
+	if owns, err := ap.db.Owns(c, actor); err != nil || owns {
+		// our own activities, coming back from a server we delivered them to
+		return err
+	}
+
+	author, err := ap.threadAuthor(c, activity)
+	if err != nil || author == nil {
+		return err
+	}
+	followersIRI := author.FollowersIRI().String()
+	addressed := false
+	for _, iri := range collections {
+		if iri.String() == followersIRI {
+			addressed = true
+			break
+		}
+	}
+	if !addressed {
+		return nil
+	}
+	if hidden, err := ap.Hides(author, actor); err != nil || hidden {
+		return err
+	}
+
+	inboxes, err := ap.forwardingInboxes(c, author, actor)
+	if err != nil || len(inboxes) == 0 {
+		return err
+	}
+
+	id := activity.GetJSONLDId().Get()
+	b, ok := c.Value(model.ContextKeyDelivery).([]byte)
+	if !ok {
+		return fmt.Errorf("no delivered body of %s to forward", id)
+	}
+	if first, err := ap.forwardsRepo.Claim(id.String()); err != nil || !first {
+		return err
+	}
+	log.Printf("forwarding %s from %s to %d followers' inboxes of %s", id, actor, len(inboxes), author.Username)
+	return ap.queue.Enqueue(author, b, inboxes)
+}
+
+// threadAuthor returns the local user who wrote the review at the root of the thread that the objects of an activity reply to, or nil if they don't reply to anything of ours.
+func (ap *ActivityPub) threadAuthor(c context.Context, activity pub.Activity) (*model.User, error) {
+	objects := activity.GetActivityStreamsObject()
+	if objects == nil {
+		return nil, nil
+	}
+	for iter := objects.Begin(); iter != objects.End(); iter = iter.Next() {
+		note, ok := iter.GetType().(vocab.ActivityStreamsNote)
+		if !ok {
+			continue
+		}
+		inReplyTo := model.InReplyTo(note)
+		if inReplyTo == nil {
+			continue
+		}
+		review, err := ap.db.Thread(c, inReplyTo)
+		if err != nil {
+			return nil, err
+		}
+		if review != nil && review.User.Local {
+			return &review.User, nil
+		}
+	}
+	return nil, nil
+}
+
+// forwardingInboxes returns the inboxes of a user's followers to forward an activity by actor to, using shared inboxes where followers have them. Followers on the actor's server are left out, since it has already delivered to them itself.
+func (ap *ActivityPub) forwardingInboxes(c context.Context, user *model.User, actor *url.URL) ([]*url.URL, error) {
+	followers, err := ap.followersRepo.List(user)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var inboxes []*url.URL
+	for _, f := range followers {
+		iri, err := url.Parse(f.ID)
+		if err != nil || iri.Host == actor.Host {
+			continue
+		}
+		if owns, _ := ap.db.Owns(c, iri); owns {
+			// local followers saw it when it was delivered to us
+			continue
+		}
+		remote, err := ap.resolver.Resolve(c, iri)
+		if err != nil {
+			log.Printf("not forwarding to %s, whose inbox couldn't be found: %s", iri, err.Error())
+			continue
+		}
+		inbox := remote.Inbox
+		if remote.SharedInbox != "" {
+			inbox = remote.SharedInbox
+		}
+		if seen[inbox] {
+			continue
+		}
+		seen[inbox] = true
+		u, err := url.Parse(inbox)
+		if err != nil {
+			continue
+		}
+		inboxes = append(inboxes, u)
+	}
+	return inboxes, nil
+}
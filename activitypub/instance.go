@@ -0,0 +1,55 @@
+package activitypub
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/exlibris-fed/exlibris/infrastructure/users"
+	"github.com/exlibris-fed/exlibris/model"
+)
+
+// instanceActor holds the actor that represents the server, so that it's only looked up once.
+type instanceActor struct {
+	mu   sync.Mutex
+	user *model.User
+}
+
+// InstanceActor returns the actor that represents the server itself, for things it does rather than any of its users. It is created the first time it's needed.
+func (ap *ActivityPub) InstanceActor() (*model.User, error) {
+	ap.instance.mu.Lock()
+	defer ap.instance.mu.Unlock()
+	if ap.instance.user != nil {
+		return ap.instance.user, nil
+	}
+
+	user, err := ap.usersRepo.GetByUsername(strings.ToLower(ap.cfg.Domain))
+	if errors.Is(err, users.ErrNotFound) {
+		user, err = ap.createInstanceActor()
+	}
+	if err != nil {
+		return nil, err
+	}
+	ap.instance.user = user
+	return user, nil
+}
+
+// createInstanceActor creates the actor that represents the server. Another replica may have just created it, in which case theirs is used.
+func (ap *ActivityPub) createInstanceActor() (*model.User, error) {
+	user, err := model.NewInstanceActor(ap.cfg.Domain)
+	if err != nil {
+		return nil, err
+	}
+	if user, err = ap.usersRepo.Save(user); err != nil {
+		return ap.usersRepo.GetByUsername(strings.ToLower(ap.cfg.Domain))
+	}
+
+	// gorm leaves columns with a default out when inserting their zero value, so it has to be saved again for relays that follow it back to be accepted straight away
+	user.ManuallyApprovesFollowers = false
+	return ap.usersRepo.Save(user)
+}
+
+// IsInstanceUsername returns whether a username is the instance actor's, which nobody may register.
+func (ap *ActivityPub) IsInstanceUsername(username string) bool {
+	return strings.EqualFold(username, ap.cfg.Domain)
+}
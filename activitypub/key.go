@@ -0,0 +1,18 @@
+package activitypub
+
+import (
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/streams"
+)
+
+// RotateKey gives a local user a new keypair and sends an Update of their actor to their followers and the public, so that other servers fetch the new key. It waits until the update has been queued for delivery, so that it can be run from a command.
+func (ap *ActivityPub) RotateKey(user *model.User) error {
+	if err := user.RotateKeys(); err != nil {
+		return err
+	}
+	if _, err := ap.usersRepo.Save(user); err != nil {
+		return err
+	}
+	return ap.send(user, ap.broadcast(streams.NewActivityStreamsUpdate(), user, user.ToType(), true))
+}
@@ -0,0 +1,177 @@
+// Package lock provides the per-IRI locks go-fed takes around reading and writing objects. Locks are keyed by the normalized IRI, so that every spelling of the same IRI shares one, and are only kept while they're held or waited on. They can also be backed by Postgres advisory locks, for when several replicas share a database.
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ErrNotLocked is returned when unlocking a key that isn't locked.
+var ErrNotLocked = errors.New("not locked")
+
+// A Locker takes exclusive locks on keys.
+type Locker interface {
+	// Lock blocks until the key is locked, or the context is done. If an error is returned the lock was not taken.
+	Lock(c context.Context, key string) error
+	// Unlock releases the lock on a key. If an error is returned the lock has still been released.
+	Unlock(c context.Context, key string) error
+}
+
+// Key returns the key to lock an IRI under. Schemes and hosts are compared case-insensitively and default ports are dropped, and paths are unescaped, so that the same IRI always gets the same key. The few distinct IRIs that unescape to the same path only end up sharing a lock.
+func Key(iri *url.URL) string {
+	u := *iri
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		port = ""
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawPath = ""
+	return u.String()
+}
+
+// Local locks keys within this process.
+type Local struct {
+	mu    sync.Mutex
+	locks map[string]*entry
+}
+
+// entry is a lock on a key, along with how many are holding or waiting on it so that it can be dropped once nobody is.
+type entry struct {
+	held chan struct{}
+	refs int
+}
+
+// NewLocal returns a Locker that locks keys within this process.
+func NewLocal() *Local {
+	return &Local{locks: make(map[string]*entry)}
+}
+
+// Lock blocks until the key is locked, or the context is done.
+func (l *Local) Lock(c context.Context, key string) error {
+	l.mu.Lock()
+	e, ok := l.locks[key]
+	if !ok {
+		e = &entry{held: make(chan struct{}, 1)}
+		l.locks[key] = e
+	}
+	e.refs++
+	l.mu.Unlock()
+
+	select {
+	case e.held <- struct{}{}:
+		return nil
+	case <-c.Done():
+		l.release(key, e)
+		return c.Err()
+	}
+}
+
+// Unlock releases the lock on a key.
+func (l *Local) Unlock(c context.Context, key string) error {
+	l.mu.Lock()
+	e, ok := l.locks[key]
+	l.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unlocking %s: %w", key, ErrNotLocked)
+	}
+	select {
+	case <-e.held:
+	default:
+		return fmt.Errorf("unlocking %s: %w", key, ErrNotLocked)
+	}
+	l.release(key, e)
+	return nil
+}
+
+// release gives up a reference to a key's lock, dropping the lock once nobody holds or waits on it.
+func (l *Local) release(key string, e *entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.refs--
+	if e.refs == 0 {
+		delete(l.locks, key)
+	}
+}
+
+// Advisory locks keys across every process using the same Postgres database, with transaction-level advisory locks. Keys are also locked locally first, so that a process only ever has one connection waiting on each key. Keys are hashed to the 64 bits advisory locks take; the rare keys that collide just wait on each other.
+type Advisory struct {
+	local *Local
+	db    *sql.DB
+
+	mu  sync.Mutex
+	txs map[string]*sql.Tx
+}
+
+// NewAdvisory returns a Locker that locks keys with Postgres advisory locks.
+func NewAdvisory(db *sql.DB) *Advisory {
+	return &Advisory{
+		local: NewLocal(),
+		db:    db,
+		txs:   make(map[string]*sql.Tx),
+	}
+}
+
+// Lock blocks until the key is locked, or the context is done. The lock is held by a transaction, so that it's released along with its connection if the process goes away without unlocking it.
+func (a *Advisory) Lock(c context.Context, key string) error {
+	if err := a.local.Lock(c, key); err != nil {
+		return err
+	}
+
+	// the transaction outlives the context of whoever took the lock, which only bounds the wait
+	tx, err := a.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		a.local.Unlock(c, key)
+		return err
+	}
+	if _, err := tx.ExecContext(c, "SELECT pg_advisory_xact_lock($1)", hash(key)); err != nil {
+		tx.Rollback()
+		a.local.Unlock(c, key)
+		return err
+	}
+
+	a.mu.Lock()
+	a.txs[key] = tx
+	a.mu.Unlock()
+	return nil
+}
+
+// Unlock releases the lock on a key by ending the transaction holding it.
+func (a *Advisory) Unlock(c context.Context, key string) error {
+	a.mu.Lock()
+	tx, ok := a.txs[key]
+	delete(a.txs, key)
+	a.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unlocking %s: %w", key, ErrNotLocked)
+	}
+
+	// a transaction that can't be rolled back has lost its connection, and Postgres released the lock with it
+	err := tx.Rollback()
+	if unlockErr := a.local.Unlock(c, key); err == nil {
+		err = unlockErr
+	}
+	return err
+}
+
+// hash returns the advisory lock id of a key.
+func hash(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,113 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	tests := map[string]string{
+		"https://exlibris.example/user/alice":      "https://exlibris.example/user/alice",
+		"HTTPS://Exlibris.Example/user/alice":      "https://exlibris.example/user/alice",
+		"https://exlibris.example:443/user/alice":  "https://exlibris.example/user/alice",
+		"http://exlibris.example:80/user/alice":    "http://exlibris.example/user/alice",
+		"https://exlibris.example:8443/user/alice": "https://exlibris.example:8443/user/alice",
+		"https://exlibris.example":                 "https://exlibris.example/",
+		"https://[::1]:443/user/alice":             "https://[::1]/user/alice",
+		"https://exlibris.example/user/Alice":      "https://exlibris.example/user/Alice",
+		"https://exlibris.example/user/%61lice":    "https://exlibris.example/user/alice",
+	}
+	for in, want := range tests {
+		iri, err := url.Parse(in)
+		if assert.NoError(t, err) {
+			assert.Equal(t, want, Key(iri), in)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	l := NewLocal()
+	c := context.Background()
+	assert.NoError(t, l.Lock(c, "https://exlibris.example/user/alice"))
+
+	locked := make(chan struct{})
+	go func() {
+		l.Lock(c, "https://exlibris.example/user/alice")
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("locked a key that was already locked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.NoError(t, l.Lock(c, "https://exlibris.example/user/bob"), "other keys should be unaffected")
+	assert.NoError(t, l.Unlock(c, "https://exlibris.example/user/bob"))
+
+	assert.NoError(t, l.Unlock(c, "https://exlibris.example/user/alice"))
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("waiting for a key wasn't woken when it was unlocked")
+	}
+	assert.NoError(t, l.Unlock(c, "https://exlibris.example/user/alice"))
+	assert.Empty(t, l.locks, "keys should be dropped once nobody holds them")
+}
+
+func TestLocal_Cancel(t *testing.T) {
+	l := NewLocal()
+	assert.NoError(t, l.Lock(context.Background(), "https://exlibris.example/user/alice"))
+
+	c, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := l.Lock(c, "https://exlibris.example/user/alice")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	assert.NoError(t, l.Unlock(context.Background(), "https://exlibris.example/user/alice"))
+	assert.Empty(t, l.locks)
+}
+
+func TestLocal_ErrNotLocked(t *testing.T) {
+	l := NewLocal()
+	err := l.Unlock(context.Background(), "https://exlibris.example/user/alice")
+	assert.True(t, errors.Is(err, ErrNotLocked))
+}
+
+func TestAdvisory(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)") + "$").
+		WithArgs(hash("https://exlibris.example/user/alice")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	a := NewAdvisory(conn)
+	c := context.Background()
+	assert.NoError(t, a.Lock(c, "https://exlibris.example/user/alice"))
+	assert.NoError(t, a.Unlock(c, "https://exlibris.example/user/alice"))
+	assert.Empty(t, a.txs)
+	assert.Empty(t, a.local.locks)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdvisory_Error(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)") + "$").
+		WillReturnError(errors.New("oops"))
+	mock.ExpectRollback()
+
+	a := NewAdvisory(conn)
+	c := context.Background()
+	assert.Error(t, a.Lock(c, "https://exlibris.example/user/alice"))
+	assert.Empty(t, a.local.locks, "the local lock should be released if the advisory lock can't be taken")
+	assert.True(t, errors.Is(a.Unlock(c, "https://exlibris.example/user/alice"), ErrNotLocked))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
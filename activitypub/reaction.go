@@ -0,0 +1,183 @@
+package activitypub
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/exlibris-fed/exlibris/infrastructure/reactions"
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/pub"
+	"github.com/go-fed/activity/streams/vocab"
+)
+
+// ErrNotReactable is returned when a user tries to like or announce something that isn't a read or review they can see. Only public reads and reviews can be announced.
+var ErrNotReactable = errors.New("object can't be reacted to")
+
+// reaction is a Like or Announce activity.
+type reaction interface {
+	vocab.Type
+	actorer
+	objecter
+}
+
+// Like likes a read or review as a local user. If they already like it, the like is sent again.
+func (ap *ActivityPub) Like(user *model.User, objectIRI *url.URL) (*model.Reaction, error) {
+	return ap.react(user, model.ReactionLike, objectIRI)
+}
+
+// Unlike withdraws a local user's like of a read or review.
+func (ap *ActivityPub) Unlike(user *model.User, objectIRI *url.URL) error {
+	return ap.unreact(user, model.ReactionLike, objectIRI)
+}
+
+// Announce shares a public read or review with a local user's followers. If they already shared it, the announce is sent again.
+func (ap *ActivityPub) Announce(user *model.User, objectIRI *url.URL) (*model.Reaction, error) {
+	return ap.react(user, model.ReactionAnnounce, objectIRI)
+}
+
+// Unannounce withdraws a local user's announce of a read or review.
+func (ap *ActivityPub) Unannounce(user *model.User, objectIRI *url.URL) error {
+	return ap.unreact(user, model.ReactionAnnounce, objectIRI)
+}
+
+// react saves a local user's reaction to a read or review and sends it to the object's author, and for announces to the user's followers.
+func (ap *ActivityPub) react(user *model.User, kind string, objectIRI *url.URL) (*model.Reaction, error) {
+	c := context.Background()
+	author, err := ap.db.AttributedTo(c, objectIRI)
+	if err != nil {
+		return nil, err
+	}
+	if author == nil {
+		return nil, ErrNotReactable
+	}
+
+	// only public objects can be announced, which are the ones anyone at all can see
+	viewer := user.IRI()
+	if kind == model.ReactionAnnounce {
+		viewer = nil
+	}
+	if ok, err := ap.db.CanView(c, objectIRI, viewer); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrNotReactable
+	}
+
+	r, err := ap.reactionsRepo.GetByActor(kind, user.IRI().String(), objectIRI.String())
+	if errors.Is(err, reactions.ErrNotFound) {
+		r = &model.Reaction{
+			ID:        ap.newActivityIRI(user, strings.ToLower(kind)).String(),
+			Kind:      kind,
+			ActorIRI:  user.IRI().String(),
+			ObjectIRI: objectIRI.String(),
+			AuthorIRI: author.String(),
+		}
+		err = ap.reactionsRepo.Create(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ap.Send(user, r.ToType())
+	return r, nil
+}
+
+// unreact removes a local user's reaction to a read or review and sends an Undo of it to everyone who was sent the reaction.
+func (ap *ActivityPub) unreact(user *model.User, kind string, objectIRI *url.URL) error {
+	r, err := ap.reactionsRepo.GetByActor(kind, user.IRI().String(), objectIRI.String())
+	if err != nil {
+		return err
+	}
+	if err := ap.reactionsRepo.Delete(r); err != nil {
+		return err
+	}
+
+	ap.Send(user, r.UndoToType(ap.newActivityIRI(user, "undo")))
+	return nil
+}
+
+// onLike handles an actor liking a read or review.
+func (ap *ActivityPub) onLike(c context.Context, like vocab.ActivityStreamsLike) error {
+	return ap.onReaction(c, model.ReactionLike, like)
+}
+
+// onAnnounce handles an actor sharing a read or review, or a relay passing on public activities from its other subscribers.
+func (ap *ActivityPub) onAnnounce(c context.Context, announce vocab.ActivityStreamsAnnounce) error {
+	if relay, err := ap.announcingRelay(announce); err != nil {
+		return err
+	} else if relay != nil {
+		return ap.onRelayAnnounce(c, relay, announce)
+	}
+	return ap.onReaction(c, model.ReactionAnnounce, announce)
+}
+
+// onReaction saves a Like or Announce of a read or review we have. Reactions to anything else, such as Mastodon posts announced by someone a local user follows, are only kept in the inbox.
+func (ap *ActivityPub) onReaction(c context.Context, kind string, a reaction) error {
+	actor, err := firstActor(a)
+	if err != nil {
+		return err
+	}
+	id, err := pub.GetId(a)
+	if err != nil {
+		return err
+	}
+	objects := objectIRIs(a)
+	if len(objects) == 0 {
+		return pub.ErrObjectRequired
+	}
+	object := objects[0]
+
+	// local reactions are delivered back to us when the object is also local, and have already been saved
+	if _, err := ap.reactionsRepo.Get(id.String()); err == nil {
+		return nil
+	} else if !errors.Is(err, reactions.ErrNotFound) {
+		return err
+	}
+
+	author, err := ap.db.AttributedTo(c, object)
+	if err != nil {
+		return err
+	}
+	if author == nil {
+		log.Printf("ignoring %s of %s, which isn't a read or review", kind, object)
+		return nil
+	}
+	if ok, err := ap.db.CanView(c, object, actor); err != nil {
+		return err
+	} else if !ok {
+		log.Printf("ignoring %s by %s of %s, which they can't see", kind, actor, object)
+		return nil
+	}
+	if _, err := ap.reactionsRepo.GetByActor(kind, actor.String(), object.String()); err == nil {
+		log.Printf("ignoring %s by %s of %s, which they've already reacted to", kind, actor, object)
+		return nil
+	} else if !errors.Is(err, reactions.ErrNotFound) {
+		return err
+	}
+
+	return ap.reactionsRepo.Create(&model.Reaction{
+		ID:        id.String(),
+		Kind:      kind,
+		ActorIRI:  actor.String(),
+		ObjectIRI: object.String(),
+		AuthorIRI: author.String(),
+	})
+}
+
+// undoReaction removes the like or announce with the given id, as long as actor is who made it.
+func (ap *ActivityPub) undoReaction(actor, id *url.URL) error {
+	r, err := ap.reactionsRepo.Get(id.String())
+	if errors.Is(err, reactions.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if r.ActorIRI != actor.String() {
+		log.Printf("ignoring undo by %s of someone else's %s", actor, r.Kind)
+		return nil
+	}
+	return ap.reactionsRepo.Delete(r)
+}
@@ -0,0 +1,257 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/exlibris-fed/exlibris/activitypub/bookwyrm"
+	"github.com/exlibris-fed/exlibris/activitypub/database"
+	"github.com/exlibris-fed/exlibris/infrastructure/relays"
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/pub"
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+	"github.com/google/uuid"
+)
+
+// Relays returns the relays the server is subscribed to.
+func (ap *ActivityPub) Relays() ([]model.Relay, error) {
+	return ap.relaysRepo.List()
+}
+
+// Subscribe subscribes the server to a relay by following it as the instance actor. The relay then announces public activities from its other subscribers to us, and if publish is set our public reads are sent to it to announce to them. If the server is already subscribed the follow is sent again, and whether reads are published is updated.
+func (ap *ActivityPub) Subscribe(inbox *url.URL, publish bool) (*model.Relay, error) {
+	instance, err := ap.InstanceActor()
+	if err != nil {
+		return nil, err
+	}
+
+	relay, err := ap.relaysRepo.GetByInbox(inbox.String())
+	if errors.Is(err, relays.ErrNotFound) {
+		relay = &model.Relay{
+			ID:       uuid.New(),
+			Inbox:    inbox.String(),
+			FollowID: ap.newActivityIRI(instance, "follow").String(),
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if !relay.Accepted() {
+		relay.Status = model.RelayPending
+	}
+	relay.Publish = publish
+	if err := ap.relaysRepo.Save(relay); err != nil {
+		return nil, err
+	}
+
+	if err := ap.deliver(instance, relay.FollowToType(instance.IRI()), inbox); err != nil {
+		return nil, err
+	}
+	return relay, nil
+}
+
+// Unsubscribe stops following a relay, so that it stops announcing activities to us and is no longer sent our reads.
+func (ap *ActivityPub) Unsubscribe(id uuid.UUID) error {
+	relay, err := ap.relaysRepo.Get(id)
+	if err != nil {
+		return err
+	}
+	if err := ap.relaysRepo.Delete(relay); err != nil {
+		return err
+	}
+
+	instance, err := ap.InstanceActor()
+	if err != nil {
+		return err
+	}
+	inbox, err := relay.InboxIRI()
+	if err != nil {
+		return err
+	}
+	undo := ap.address(streams.NewActivityStreamsUndo(), instance, model.PublicActivityPubIRI, relay.FollowToType(instance.IRI()))
+	return ap.deliver(instance, undo, inbox)
+}
+
+// answerRelay records a relay's answer to the instance's follow, if that's what an Accept or Reject from actor is about. Relays are followed at their inbox, so the actor answering is only known now, and has to be on the same server as the inbox.
+func (ap *ActivityPub) answerRelay(actor *url.URL, response objecter, status string) error {
+	for _, object := range objectIRIs(response) {
+		relay, err := ap.relaysRepo.GetByFollowID(object.String())
+		if errors.Is(err, relays.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if inbox, err := relay.InboxIRI(); err != nil || inbox.Host != actor.Host {
+			log.Printf("ignoring response from %s to follow of relay %s", actor, relay.Inbox)
+			continue
+		}
+		relay.ActorIRI = actor.String()
+		relay.Status = status
+		if err := ap.relaysRepo.Save(relay); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// announcingRelay returns the relay that made an Announce, or nil if it wasn't made by a relay that accepted our follow.
+func (ap *ActivityPub) announcingRelay(announce vocab.ActivityStreamsAnnounce) (*model.Relay, error) {
+	actor, err := firstActor(announce)
+	if err != nil {
+		return nil, err
+	}
+	relay, err := ap.relaysRepo.GetByActor(actor.String())
+	if errors.Is(err, relays.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if !relay.Accepted() {
+		return nil, nil
+	}
+	return relay, nil
+}
+
+// onRelayAnnounce saves the public reads and reviews a relay announced. Failing to save one doesn't fail the delivery, since the relay would only send it again.
+func (ap *ActivityPub) onRelayAnnounce(c context.Context, relay *model.Relay, announce vocab.ActivityStreamsAnnounce) error {
+	for _, object := range objectIRIs(announce) {
+		if err := ap.saveRelayed(c, object); err != nil {
+			log.Printf("error saving %s announced by relay %s: %s", object, relay.ActorIRI, err.Error())
+		}
+	}
+	return nil
+}
+
+// saveRelayed saves a read or review a relay announced. It is fetched from its own server rather than taken from the relay, which can't vouch for it. Anything else relays pass on, such as Mastodon posts, and anything that isn't public is ignored.
+func (ap *ActivityPub) saveRelayed(c context.Context, iri *url.URL) error {
+	if ap.rejects(iri.Hostname()) {
+		log.Printf("ignoring %s, whose server is rejected", iri)
+		return nil
+	}
+	if exists, err := ap.db.Exists(c, iri); err != nil || exists {
+		return err
+	}
+
+	t, err := ap.dereference(c, iri)
+	if err != nil {
+		return err
+	}
+	if create, ok := t.(vocab.ActivityStreamsCreate); ok {
+		if t, err = ap.createdObject(c, iri, create); err != nil {
+			return err
+		}
+	}
+
+	var author *url.URL
+	switch t := t.(type) {
+	case vocab.ActivityStreamsRead:
+		author, err = firstActor(t)
+	case vocab.ActivityStreamsNote:
+		author, _, err = database.Attribution(t)
+	default:
+		log.Printf("ignoring relayed %s %s", t.GetTypeName(), iri)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !isPublic(t) {
+		log.Printf("ignoring relayed %s %s, which isn't public", t.GetTypeName(), iri)
+		return nil
+	}
+
+	// its server vouches for it, and for its author if they're on that server too, so it's saved as if they had signed it
+	id := t.GetJSONLDId().Get()
+	if !strings.EqualFold(author.Host, id.Host) {
+		log.Printf("ignoring relayed %s %s, whose author %s is on another server", t.GetTypeName(), iri, author)
+		return nil
+	}
+	c = context.WithValue(c, model.ContextKeySignedBy, author)
+	if err := ap.db.Lock(c, id); err != nil {
+		return err
+	}
+	defer ap.db.Unlock(c, id)
+	return ap.db.Create(c, t)
+}
+
+// createdObject returns the object of a Create fetched from iri. Objects that aren't embedded, or that are but come from another server, are fetched from their own.
+func (ap *ActivityPub) createdObject(c context.Context, iri *url.URL, create vocab.ActivityStreamsCreate) (vocab.Type, error) {
+	objects := create.GetActivityStreamsObject()
+	if objects == nil || objects.Len() == 0 {
+		return nil, pub.ErrObjectRequired
+	}
+	object, err := pub.ToId(objects.At(0))
+	if err != nil {
+		return nil, err
+	}
+	if t := objects.At(0).GetType(); t != nil && object.Host == iri.Host {
+		return t, nil
+	}
+	return ap.dereference(c, object)
+}
+
+// dereference fetches an ActivityStreams value from another server, translating it first if it's from BookWyrm. The value must have the IRI it was fetched from as its id.
+func (ap *ActivityPub) dereference(c context.Context, iri *url.URL) (vocab.Type, error) {
+	b, err := ap.fetch(c, iri)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	if id, _ := m["id"].(string); id != iri.String() {
+		return nil, fmt.Errorf("fetching %s returned %v", iri, m["id"])
+	}
+	if _, err := bookwyrm.Translate(c, m, ap.fetch); err != nil {
+		return nil, err
+	}
+	return streams.ToType(c, m)
+}
+
+// publishToRelays sends a public read to the relays that publish our reads, so that they announce it to their other subscribers.
+func (ap *ActivityPub) publishToRelays(user *model.User, read vocab.ActivityStreamsRead) error {
+	list, err := ap.relaysRepo.Publishing()
+	if err != nil || len(list) == 0 {
+		return err
+	}
+	var inboxes []*url.URL
+	for _, relay := range list {
+		inbox, err := relay.InboxIRI()
+		if err != nil {
+			log.Printf("error parsing inbox of relay %s: %s", relay.Inbox, err.Error())
+			continue
+		}
+		inboxes = append(inboxes, inbox)
+	}
+	return ap.deliver(user, read, inboxes...)
+}
+
+// deliver queues an activity by a local user for inboxes that aren't an actor's, such as relays', which go-fed can't address. Like everything else we send, it includes the exlibris vocabulary.
+func (ap *ActivityPub) deliver(user *model.User, t vocab.Type, inboxes ...*url.URL) error {
+	m, err := streams.Serialize(t)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(model.WithVocabulary(m))
+	if err != nil {
+		return err
+	}
+	return ap.queue.Enqueue(user, b, inboxes)
+}
+
+// isPublic returns whether an activity or object is addressed to the public.
+func isPublic(t vocab.Type) bool {
+	for _, iri := range database.Audience(t) {
+		if pub.IsPublic(iri.String()) {
+			return true
+		}
+	}
+	return false
+}
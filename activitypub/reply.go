@@ -0,0 +1,63 @@
+package activitypub
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/streams"
+	"github.com/google/uuid"
+)
+
+// Reply saves a local user's reply to a review, or to a reply in the thread under it when parent isn't nil, and sends it to the public, the user's followers and whoever wrote what it answers.
+func (ap *ActivityPub) Reply(user *model.User, review *model.Review, parent *model.Reply, text string) (*model.Reply, error) {
+	reply := &model.Reply{
+		Base: model.Base{
+			ID: uuid.New(),
+		},
+		Review:          *review,
+		ReviewID:        review.ID,
+		InReplyTo:       review.IRI().String(),
+		InReplyToAuthor: review.User.IRI().String(),
+		User:            *user,
+		UserID:          user.ID,
+		Text:            text,
+	}
+	if parent != nil {
+		reply.InReplyTo = parent.IRI().String()
+		reply.InReplyToAuthor = parent.User.IRI().String()
+	}
+	if err := ap.repliesRepo.Create(reply); err != nil {
+		return nil, err
+	}
+	ap.Send(user, reply.CreateToType())
+	return reply, nil
+}
+
+// DeleteReply removes a reply and tells everyone who could see it that it's gone.
+func (ap *ActivityPub) DeleteReply(reply *model.Reply) error {
+	if err := ap.deleteReply(reply); err != nil {
+		return err
+	}
+	tombstone := &model.Tombstone{ID: reply.IRI().String(), FormerType: "Note"}
+	ap.Send(&reply.User, ap.broadcast(streams.NewActivityStreamsDelete(), &reply.User, tombstone.ToType(), true))
+	return nil
+}
+
+// deleteReply replaces a reply, and the activity that created it, with tombstones.
+func (ap *ActivityPub) deleteReply(reply *model.Reply) error {
+	c := context.Background()
+	iri := reply.IRI()
+	if err := ap.db.Bury(c, iri, "Note"); err != nil {
+		return err
+	}
+	create, err := url.Parse(iri.String() + "/activity")
+	if err != nil {
+		return err
+	}
+	if err := ap.db.Bury(c, create, "Create"); err != nil {
+		return err
+	}
+	return ap.repliesRepo.Delete(reply)
+}
@@ -0,0 +1,96 @@
+package activitypub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/exlibris-fed/exlibris/activitypub/resolver"
+	"github.com/exlibris-fed/exlibris/key"
+	"github.com/exlibris-fed/exlibris/model"
+)
+
+// maxDocumentSize is the most we will read when dereferencing an IRI.
+const maxDocumentSize = 1 << 20
+
+// Actor returns the cached actor with the given IRI, fetching them if they haven't been seen before or are stale.
+func (ap *ActivityPub) Actor(c context.Context, iri *url.URL) (*model.RemoteActor, error) {
+	return ap.resolver.Resolve(c, iri)
+}
+
+// Lookup returns the actor with a handle such as @alice@books.example, finding them through Webfinger on their server.
+func (ap *ActivityPub) Lookup(c context.Context, handle string) (*model.RemoteActor, error) {
+	return ap.resolver.Lookup(c, handle)
+}
+
+// fetch dereferences an IRI on another server. The request is signed as the local user in the context, or the instance actor if there isn't one, for servers that only serve signed requests.
+func (ap *ActivityPub) fetch(c context.Context, iri *url.URL) ([]byte, error) {
+	return ap.get(c, iri, `application/activity+json, application/ld+json; profile="https://www.w3.org/ns/activitystreams"`, true)
+}
+
+// finger queries Webfinger on another server. Webfinger is public, so the request isn't signed.
+func (ap *ActivityPub) finger(c context.Context, iri *url.URL) ([]byte, error) {
+	return ap.get(c, iri, "application/jrd+json, application/json", false)
+}
+
+// get makes a GET request to another server for a document of the accepted type, signing it if asked to.
+func (ap *ActivityPub) get(c context.Context, iri *url.URL, accept string, sign bool) ([]byte, error) {
+	req, err := http.NewRequestWithContext(c, http.MethodGet, iri.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", UserAgentString)
+	req.Header.Set("Date", ap.clock.Now().UTC().Format(http.TimeFormat))
+
+	if sign {
+		user, err := ap.signingUser(c)
+		if err != nil {
+			return nil, err
+		}
+		pk, err := key.DeserializeRSAPrivateKey(user.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		signer := ap.signer(getHeaders)
+		if err := signer.SignRequest(pk, user.PublicKeyID(), req, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := ap.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		return nil, resolver.ErrGone
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned %d", iri, resp.StatusCode)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+}
+
+// signingUser returns the local user a request made while handling the context should be signed as: the authenticated user, or the owner of the inbox being delivered to. Requests made outside of a user's, such as fetching the key of a signature, are signed as the instance actor.
+func (ap *ActivityPub) signingUser(c context.Context) (*model.User, error) {
+	if user, ok := c.Value(model.ContextKeyAuthenticatedUser).(*model.User); ok && user.Local {
+		return user, nil
+	}
+	if user, ok := c.Value(model.ContextKeyInboxOwner).(*model.User); ok {
+		return user, nil
+	}
+	return ap.InstanceActor()
+}
+
+// purge removes a remote actor or object whose server says it's gone, as if they had sent a Delete of it. Their server is the authority on that, so it's done as them whoever's request found out.
+func (ap *ActivityPub) purge(c context.Context, iri *url.URL) error {
+	c = context.WithValue(c, model.ContextKeySignedBy, iri)
+	if err := ap.db.Lock(c, iri); err != nil {
+		return err
+	}
+	defer ap.db.Unlock(c, iri)
+	return ap.db.Delete(c, iri)
+}
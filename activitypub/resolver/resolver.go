@@ -0,0 +1,293 @@
+// Package resolver dereferences the actors of other servers and caches them, so that their inboxes and keys are at hand for every interaction. Cached actors are fetched again once they're stale or their key stops verifying signatures, and actors whose servers say they're gone are purged.
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/pub"
+)
+
+const (
+	// DefaultTTL is how long a cached actor is used before they're fetched again.
+	DefaultTTL = 24 * time.Hour
+
+	// MinRefreshInterval is how soon after fetching an actor they may be fetched again because their key didn't verify a signature, so that bad signatures can't be used to make us fetch over and over.
+	MinRefreshInterval = time.Minute
+)
+
+var (
+	// ErrGone is returned by a Fetcher when the server says what was asked for has been deleted.
+	ErrGone = errors.New("object is gone")
+	// ErrNotActor is returned when a document doesn't describe an actor.
+	ErrNotActor = errors.New("document is not an actor")
+)
+
+// An ActorStore caches remote actors.
+type ActorStore interface {
+	Get(id string) (*model.RemoteActor, error)
+	Save(actor *model.RemoteActor) error
+	Delete(id string) error
+}
+
+// A KeyStore caches the keys of remote actors.
+type KeyStore interface {
+	Get(id string) (*model.RemoteKey, error)
+	Save(key *model.RemoteKey) error
+	DeleteByOwner(owner string) error
+}
+
+// A Fetcher dereferences an IRI on another server. It returns ErrGone if the server says the object was deleted.
+type Fetcher func(c context.Context, iri *url.URL) ([]byte, error)
+
+// A Purger removes whatever is stored about a remote actor or object whose server says it's gone.
+type Purger func(c context.Context, iri *url.URL) error
+
+// A Resolver looks up remote actors, from the cache when it can.
+type Resolver struct {
+	actors ActorStore
+	keys   KeyStore
+	fetch  Fetcher
+	finger Fetcher
+	purge  Purger
+	clock  pub.Clock
+	ttl    time.Duration
+
+	// webfingerScheme is the scheme Webfinger is queried over, which RFC 7033 says must be https.
+	webfingerScheme string
+}
+
+// New returns a Resolver which caches actors and their keys in the provided stores. Actors are dereferenced with fetch, and Webfinger with finger.
+func New(actors ActorStore, keys KeyStore, fetch, finger Fetcher, purge Purger, clock pub.Clock) *Resolver {
+	return &Resolver{
+		actors: actors,
+		keys:   keys,
+		fetch:  fetch,
+		finger: finger,
+		purge:  purge,
+		clock:  clock,
+		ttl:    DefaultTTL,
+
+		webfingerScheme: "https",
+	}
+}
+
+// Resolve returns the actor with the given IRI, fetching them if they aren't cached or are stale. If a stale actor can't be fetched again the cached copy is used, unless they're gone.
+func (r *Resolver) Resolve(c context.Context, iri *url.URL) (*model.RemoteActor, error) {
+	cached, err := r.actors.Get(iri.String())
+	if err == nil && !cached.Stale(r.clock.Now(), r.ttl) {
+		return cached, nil
+	}
+
+	actor, err := r.Refresh(c, iri)
+	if err != nil && !errors.Is(err, ErrGone) && cached != nil {
+		log.Printf("using stale copy of %s, who couldn't be fetched: %s", iri, err.Error())
+		return cached, nil
+	}
+	return actor, err
+}
+
+// Refresh fetches an actor and caches them along with their key.
+func (r *Resolver) Refresh(c context.Context, iri *url.URL) (*model.RemoteActor, error) {
+	actor, _, err := r.refresh(c, iri)
+	return actor, err
+}
+
+// refresh fetches an actor and caches them, returning their key too if they have one.
+func (r *Resolver) refresh(c context.Context, iri *url.URL) (*model.RemoteActor, *model.RemoteKey, error) {
+	b, err := r.fetchDocument(c, iri)
+	if err != nil {
+		return nil, nil, err
+	}
+	actor, k, err := r.parse(iri, b)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.save(actor, k)
+	return actor, k, nil
+}
+
+// Dereference returns the document at an IRI. Actors are served from the cache while it's fresh, and cached whenever they're fetched.
+func (r *Resolver) Dereference(c context.Context, iri *url.URL) ([]byte, error) {
+	if cached, err := r.actors.Get(iri.String()); err == nil && !cached.Stale(r.clock.Now(), r.ttl) {
+		return []byte(cached.Document), nil
+	}
+
+	b, err := r.fetchDocument(c, iri)
+	if err != nil {
+		return nil, err
+	}
+	if actor, k, err := r.parse(iri, b); err == nil {
+		r.save(actor, k)
+	}
+	return b, nil
+}
+
+// FetchKey dereferences a key so that a signature can be verified with it. The actor who owns the key is cached along with it. If they were fetched less than MinRefreshInterval ago the cached key is returned instead.
+func (r *Resolver) FetchKey(id string) (*model.RemoteKey, error) {
+	keyIRI, err := url.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	document := *keyIRI
+	document.Fragment = ""
+
+	if k, err := r.keys.Get(id); err == nil {
+		if actor, err := r.actors.Get(k.Owner); err == nil && r.clock.Now().Sub(actor.FetchedAt) < MinRefreshInterval {
+			return k, nil
+		}
+	}
+
+	c := context.Background()
+	b, err := r.fetchDocument(c, &document)
+	if err != nil {
+		return nil, err
+	}
+	actor, k, err := r.parse(&document, b)
+	if errors.Is(err, ErrNotActor) {
+		// the key is served on its own, and names the actor it belongs to
+		var standalone publicKey
+		if err := json.Unmarshal(b, &standalone); err != nil {
+			return nil, err
+		}
+		owner, err := url.Parse(standalone.Owner)
+		if err != nil {
+			return nil, err
+		}
+		if standalone.ID != id || owner.Host != keyIRI.Host {
+			return nil, fmt.Errorf("document at %s is not key %s", document.String(), id)
+		}
+		_, k, err = r.refresh(c, owner)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else {
+		r.save(actor, k)
+	}
+
+	if k == nil || k.ID != id {
+		return nil, fmt.Errorf("key %s could not be found", id)
+	}
+	return k, nil
+}
+
+// Purge removes a cached actor and their keys, along with everything else stored about them.
+func (r *Resolver) Purge(c context.Context, iri *url.URL) error {
+	if err := r.actors.Delete(iri.String()); err != nil {
+		return err
+	}
+	if err := r.keys.DeleteByOwner(iri.String()); err != nil {
+		return err
+	}
+	return r.purge(c, iri)
+}
+
+// fetchDocument dereferences an IRI, purging what we have of it if the server says it's gone.
+func (r *Resolver) fetchDocument(c context.Context, iri *url.URL) ([]byte, error) {
+	b, err := r.fetch(c, iri)
+	if errors.Is(err, ErrGone) {
+		log.Printf("%s is gone, purging it", iri)
+		if purgeErr := r.Purge(c, iri); purgeErr != nil {
+			log.Printf("error purging %s: %s", iri, purgeErr.Error())
+		}
+	}
+	return b, err
+}
+
+// save caches an actor and their key. Failures are only logged, since the actor can be fetched again.
+func (r *Resolver) save(actor *model.RemoteActor, k *model.RemoteKey) {
+	if err := r.actors.Save(actor); err != nil {
+		log.Printf("error caching actor %s: %s", actor.ID, err.Error())
+	}
+	if k == nil {
+		return
+	}
+	if err := r.keys.Save(k); err != nil {
+		log.Printf("error caching key %s: %s", k.ID, err.Error())
+	}
+}
+
+// publicKey is the JSON representation of a key. It may be embedded in an actor as `publicKey` or served on its own.
+type publicKey struct {
+	ID    string `json:"id"`
+	Owner string `json:"owner"`
+	PEM   string `json:"publicKeyPem"`
+}
+
+// actorDocument is the JSON representation of an actor, with only the properties we keep.
+type actorDocument struct {
+	ID        string          `json:"id"`
+	Inbox     string          `json:"inbox"`
+	Endpoints json.RawMessage `json:"endpoints"`
+	PublicKey *publicKey      `json:"publicKey"`
+}
+
+// parse reads the actor with the given IRI out of a document, along with their key if they have one. A server may only vouch for keys on its own host.
+func (r *Resolver) parse(iri *url.URL, b []byte) (*model.RemoteActor, *model.RemoteKey, error) {
+	var document actorDocument
+	if err := json.Unmarshal(b, &document); err != nil {
+		return nil, nil, err
+	}
+	if document.Inbox == "" {
+		return nil, nil, ErrNotActor
+	}
+	if document.ID != iri.String() {
+		return nil, nil, fmt.Errorf("document at %s is %s", iri, document.ID)
+	}
+
+	actor := &model.RemoteActor{
+		ID:        document.ID,
+		Inbox:     document.Inbox,
+		Document:  string(b),
+		FetchedAt: r.clock.Now(),
+	}
+	var endpoints struct {
+		SharedInbox string `json:"sharedInbox"`
+	}
+	if err := json.Unmarshal(document.Endpoints, &endpoints); err == nil {
+		actor.SharedInbox = endpoints.SharedInbox
+	}
+
+	if document.PublicKey == nil || document.PublicKey.PEM == "" {
+		return actor, nil, nil
+	}
+	keyIRI, err := url.Parse(document.PublicKey.ID)
+	if err != nil || keyIRI.Host != iri.Host || document.PublicKey.Owner != document.ID {
+		log.Printf("ignoring key %s of %s, which doesn't belong to them", document.PublicKey.ID, document.ID)
+		return actor, nil, nil
+	}
+	actor.PublicKeyID = document.PublicKey.ID
+	return actor, &model.RemoteKey{
+		ID:    document.PublicKey.ID,
+		Owner: document.PublicKey.Owner,
+		PEM:   document.PublicKey.PEM,
+	}, nil
+}
+
+// Transport wraps a transport so that it dereferences through the resolver, and so from the cache.
+func (r *Resolver) Transport(t pub.Transport) pub.Transport {
+	return &transport{
+		Transport: t,
+		resolver:  r,
+	}
+}
+
+// transport is a pub.Transport that dereferences through a Resolver.
+type transport struct {
+	pub.Transport
+	resolver *Resolver
+}
+
+// Dereference returns the document at an IRI, from the cache if it's a fresh actor.
+func (t *transport) Dereference(c context.Context, iri *url.URL) ([]byte, error) {
+	return t.resolver.Dereference(c, iri)
+}
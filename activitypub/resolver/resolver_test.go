@@ -0,0 +1,301 @@
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+type memoryActors map[string]*model.RemoteActor
+
+func (m memoryActors) Get(id string) (*model.RemoteActor, error) {
+	if a, ok := m[id]; ok {
+		return a, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (m memoryActors) Save(a *model.RemoteActor) error {
+	m[a.ID] = a
+	return nil
+}
+
+func (m memoryActors) Delete(id string) error {
+	delete(m, id)
+	return nil
+}
+
+type memoryKeys map[string]*model.RemoteKey
+
+func (m memoryKeys) Get(id string) (*model.RemoteKey, error) {
+	if k, ok := m[id]; ok {
+		return k, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (m memoryKeys) Save(k *model.RemoteKey) error {
+	m[k.ID] = k
+	return nil
+}
+
+func (m memoryKeys) DeleteByOwner(owner string) error {
+	for id, k := range m {
+		if k.Owner == owner {
+			delete(m, id)
+		}
+	}
+	return nil
+}
+
+// remoteServer serves an actor like Mastodon does, until they're deleted.
+type remoteServer struct {
+	server  *httptest.Server
+	pem     string
+	gone    bool
+	fetches int
+}
+
+func newRemoteServer() *remoteServer {
+	s := &remoteServer{pem: "-----BEGIN PUBLIC KEY-----"}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches++
+		if r.URL.Path == "/.well-known/webfinger" {
+			s.webfinger(w, r)
+			return
+		}
+		if r.URL.Path != "/users/alice" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if s.gone {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		b, _ := json.Marshal(map[string]interface{}{
+			"id":    s.actorID(),
+			"type":  "Person",
+			"inbox": s.actorID() + "/inbox",
+			"endpoints": map[string]string{
+				"sharedInbox": s.server.URL + "/inbox",
+			},
+			"publicKey": map[string]string{
+				"id":           s.keyID(),
+				"owner":        s.actorID(),
+				"publicKeyPem": s.pem,
+			},
+		})
+		w.Write(b)
+	}))
+	return s
+}
+
+// webfinger serves the Webfinger of alice.
+func (s *remoteServer) webfinger(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("resource") != "acct:"+s.handle() {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	b, _ := json.Marshal(map[string]interface{}{
+		"subject": "acct:" + s.handle(),
+		"links": []map[string]string{
+			{"rel": "http://webfinger.net/rel/profile-page", "type": "text/html", "href": s.server.URL + "/@alice"},
+			{"rel": "self", "type": "application/activity+json", "href": s.actorID()},
+		},
+	})
+	w.Write(b)
+}
+
+func (s *remoteServer) handle() string {
+	return "alice@" + s.server.Listener.Addr().String()
+}
+
+func (s *remoteServer) actorID() string {
+	return s.server.URL + "/users/alice"
+}
+
+func (s *remoteServer) keyID() string {
+	return s.actorID() + "#main-key"
+}
+
+func (s *remoteServer) actorIRI() *url.URL {
+	u, _ := url.Parse(s.actorID())
+	return u
+}
+
+func fetch(c context.Context, iri *url.URL) ([]byte, error) {
+	resp, err := http.Get(iri.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		return nil, ErrGone
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned %d", iri, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+type fixture struct {
+	resolver *Resolver
+	actors   memoryActors
+	keys     memoryKeys
+	clock    *fakeClock
+	purged   []string
+}
+
+func newFixture() *fixture {
+	f := &fixture{
+		actors: memoryActors{},
+		keys:   memoryKeys{},
+		clock:  &fakeClock{now: time.Now()},
+	}
+	purge := func(c context.Context, iri *url.URL) error {
+		f.purged = append(f.purged, iri.String())
+		return nil
+	}
+	f.resolver = New(f.actors, f.keys, fetch, fetch, purge, f.clock)
+	f.resolver.webfingerScheme = "http"
+	return f
+}
+
+func TestResolve(t *testing.T) {
+	remote := newRemoteServer()
+	defer remote.server.Close()
+	f := newFixture()
+
+	actor, err := f.resolver.Resolve(context.Background(), remote.actorIRI())
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, actor) {
+		assert.Equal(t, remote.actorID()+"/inbox", actor.Inbox)
+		assert.Equal(t, remote.server.URL+"/inbox", actor.SharedInbox)
+		assert.Equal(t, remote.keyID(), actor.PublicKeyID)
+	}
+	assert.Contains(t, f.keys, remote.keyID())
+}
+
+func TestResolve_Cached(t *testing.T) {
+	remote := newRemoteServer()
+	defer remote.server.Close()
+	f := newFixture()
+
+	_, err := f.resolver.Resolve(context.Background(), remote.actorIRI())
+	assert.NoError(t, err)
+	_, err = f.resolver.Resolve(context.Background(), remote.actorIRI())
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, remote.fetches)
+}
+
+func TestResolve_Stale(t *testing.T) {
+	remote := newRemoteServer()
+	defer remote.server.Close()
+	f := newFixture()
+
+	_, err := f.resolver.Resolve(context.Background(), remote.actorIRI())
+	assert.NoError(t, err)
+	remote.pem = "-----BEGIN ROTATED PUBLIC KEY-----"
+	f.clock.now = f.clock.now.Add(DefaultTTL + time.Minute)
+	_, err = f.resolver.Resolve(context.Background(), remote.actorIRI())
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, remote.fetches)
+	assert.Equal(t, remote.pem, f.keys[remote.keyID()].PEM)
+}
+
+func TestResolve_StaleUnreachable(t *testing.T) {
+	remote := newRemoteServer()
+	f := newFixture()
+
+	_, err := f.resolver.Resolve(context.Background(), remote.actorIRI())
+	assert.NoError(t, err)
+	remote.server.Close()
+	f.clock.now = f.clock.now.Add(DefaultTTL + time.Minute)
+	actor, err := f.resolver.Resolve(context.Background(), remote.actorIRI())
+
+	assert.NoError(t, err)
+	assert.NotNil(t, actor)
+}
+
+func TestResolve_Gone(t *testing.T) {
+	remote := newRemoteServer()
+	defer remote.server.Close()
+	f := newFixture()
+
+	_, err := f.resolver.Resolve(context.Background(), remote.actorIRI())
+	assert.NoError(t, err)
+	remote.gone = true
+	f.clock.now = f.clock.now.Add(DefaultTTL + time.Minute)
+	actor, err := f.resolver.Resolve(context.Background(), remote.actorIRI())
+
+	assert.Nil(t, actor)
+	assert.True(t, errors.Is(err, ErrGone))
+	assert.Empty(t, f.actors)
+	assert.Empty(t, f.keys)
+	assert.Equal(t, []string{remote.actorID()}, f.purged)
+}
+
+func TestDereference(t *testing.T) {
+	remote := newRemoteServer()
+	defer remote.server.Close()
+	f := newFixture()
+
+	first, err := f.resolver.Dereference(context.Background(), remote.actorIRI())
+	assert.NoError(t, err)
+	second, err := f.resolver.Dereference(context.Background(), remote.actorIRI())
+	assert.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, remote.fetches)
+}
+
+func TestFetchKey(t *testing.T) {
+	remote := newRemoteServer()
+	defer remote.server.Close()
+	f := newFixture()
+
+	k, err := f.resolver.FetchKey(remote.keyID())
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, k) {
+		assert.Equal(t, remote.actorID(), k.Owner)
+	}
+	assert.Contains(t, f.actors, remote.actorID())
+}
+
+func TestFetchKey_RecentlyFetched(t *testing.T) {
+	remote := newRemoteServer()
+	defer remote.server.Close()
+	f := newFixture()
+
+	_, err := f.resolver.FetchKey(remote.keyID())
+	assert.NoError(t, err)
+	_, err = f.resolver.FetchKey(remote.keyID())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, remote.fetches)
+
+	f.clock.now = f.clock.now.Add(MinRefreshInterval + time.Second)
+	_, err = f.resolver.FetchKey(remote.keyID())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, remote.fetches)
+}
@@ -0,0 +1,112 @@
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+
+	"github.com/exlibris-fed/exlibris/model"
+)
+
+const (
+	// relSelf is the rel of the Webfinger link to a user's actor.
+	relSelf = "self"
+
+	// profileActivityStreams is the profile of JSON-LD actor links, which are as good as application/activity+json ones.
+	profileActivityStreams = "https://www.w3.org/ns/activitystreams"
+)
+
+var (
+	// ErrInvalidHandle is returned when a handle isn't of the form user@host.
+	ErrInvalidHandle = errors.New("invalid handle")
+	// ErrNoActor is returned when a Webfinger doesn't link to an ActivityPub actor.
+	ErrNoActor = errors.New("webfinger has no actor")
+)
+
+// ParseHandle splits a handle such as @alice@books.example into the username and host. The leading @ and an acct: scheme are optional.
+func ParseHandle(handle string) (username, host string, err error) {
+	handle = strings.TrimSpace(handle)
+	if len(handle) > len("acct:") && strings.EqualFold(handle[:len("acct:")], "acct:") {
+		handle = handle[len("acct:"):]
+	}
+	handle = strings.TrimPrefix(handle, "@")
+
+	i := strings.LastIndex(handle, "@")
+	if i <= 0 || i == len(handle)-1 {
+		return "", "", ErrInvalidHandle
+	}
+	username, host = handle[:i], strings.ToLower(handle[i+1:])
+	if strings.ContainsAny(username, "@/?#") || strings.ContainsAny(host, "@/?#") {
+		return "", "", ErrInvalidHandle
+	}
+	return username, host, nil
+}
+
+// webfinger is the JSON representation of a Webfinger response, with only the properties we use.
+type webfinger struct {
+	Subject string          `json:"subject"`
+	Links   []webfingerLink `json:"links"`
+}
+
+// webfingerLink is a link in a Webfinger response.
+type webfingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type"`
+	Href string `json:"href"`
+}
+
+// Lookup finds the actor with a handle, such as @alice@books.example, through Webfinger on their server. The actor is resolved like any other, and so cached.
+func (r *Resolver) Lookup(c context.Context, handle string) (*model.RemoteActor, error) {
+	username, host, err := ParseHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := fmt.Sprintf("acct:%s@%s", username, host)
+	iri := &url.URL{
+		Scheme:   r.webfingerScheme,
+		Host:     host,
+		Path:     "/.well-known/webfinger",
+		RawQuery: url.Values{"resource": []string{resource}}.Encode(),
+	}
+	b, err := r.finger(c, iri)
+	if err != nil {
+		return nil, err
+	}
+
+	var response webfinger
+	if err := json.Unmarshal(b, &response); err != nil {
+		return nil, err
+	}
+	actorIRI, err := actorLink(&response)
+	if err != nil {
+		return nil, fmt.Errorf("looking up %s: %w", resource, err)
+	}
+	return r.Resolve(c, actorIRI)
+}
+
+// actorLink returns the IRI of the actor a Webfinger links to.
+func actorLink(response *webfinger) (*url.URL, error) {
+	for _, link := range response.Links {
+		if link.Rel != relSelf || link.Href == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(link.Type)
+		if err != nil {
+			continue
+		}
+		if mediaType != "application/activity+json" && !(mediaType == "application/ld+json" && params["profile"] == profileActivityStreams) {
+			continue
+		}
+		iri, err := url.Parse(link.Href)
+		if err != nil || (iri.Scheme != "https" && iri.Scheme != "http") {
+			continue
+		}
+		return iri, nil
+	}
+	return nil, ErrNoActor
+}
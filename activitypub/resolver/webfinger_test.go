@@ -0,0 +1,82 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHandle(t *testing.T) {
+	for _, handle := range []string{"alice@books.example", "@alice@books.example", "acct:alice@Books.Example", " @alice@books.example "} {
+		username, host, err := ParseHandle(handle)
+		assert.NoError(t, err, handle)
+		assert.Equal(t, "alice", username, handle)
+		assert.Equal(t, "books.example", host, handle)
+	}
+}
+
+func TestParseHandle_ErrInvalidHandle(t *testing.T) {
+	for _, handle := range []string{"", "alice", "@alice", "alice@", "@books.example", "alice@books.example/users", "https://books.example/users/alice"} {
+		_, _, err := ParseHandle(handle)
+		assert.True(t, errors.Is(err, ErrInvalidHandle), handle)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	remote := newRemoteServer()
+	defer remote.server.Close()
+	f := newFixture()
+
+	actor, err := f.resolver.Lookup(context.Background(), "@"+remote.handle())
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, actor) {
+		assert.Equal(t, remote.actorID(), actor.ID)
+		assert.Equal(t, remote.actorID()+"/inbox", actor.Inbox)
+	}
+	assert.Contains(t, f.actors, remote.actorID())
+}
+
+func TestLookup_Cached(t *testing.T) {
+	remote := newRemoteServer()
+	defer remote.server.Close()
+	f := newFixture()
+
+	_, err := f.resolver.Lookup(context.Background(), remote.handle())
+	assert.NoError(t, err)
+	_, err = f.resolver.Lookup(context.Background(), remote.handle())
+	assert.NoError(t, err)
+
+	// Webfinger is asked both times, but the actor is only fetched once.
+	assert.Equal(t, 3, remote.fetches)
+}
+
+func TestLookup_NotFound(t *testing.T) {
+	remote := newRemoteServer()
+	defer remote.server.Close()
+	f := newFixture()
+
+	actor, err := f.resolver.Lookup(context.Background(), "bob@"+remote.server.Listener.Addr().String())
+
+	assert.Error(t, err)
+	assert.Nil(t, actor)
+	assert.Empty(t, f.actors)
+}
+
+func TestActorLink(t *testing.T) {
+	response := &webfinger{
+		Links: []webfingerLink{
+			{Rel: "self", Type: "text/html", Href: "https://books.example/@alice"},
+			{Rel: "self", Type: `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`, Href: "https://books.example/users/alice"},
+		},
+	}
+
+	iri, err := actorLink(response)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://books.example/users/alice", iri.String())
+	_, err = actorLink(&webfinger{})
+	assert.True(t, errors.Is(err, ErrNoActor))
+}
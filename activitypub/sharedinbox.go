@@ -0,0 +1,182 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/exlibris-fed/exlibris/activitypub/database"
+	"github.com/exlibris-fed/exlibris/infrastructure/relays"
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/pub"
+	"github.com/go-fed/activity/streams"
+)
+
+// PostSharedInbox handles a delivery to the shared inbox, which remote servers use to send an activity once for every local user it's addressed to. Activities from BookWyrm are translated into ours first. The activity's side effects are applied once, through the inbox of the first recipient, and then it is added to the inboxes of the others. Activities that have already been delivered are only added to inboxes that don't have them yet. It returns false if the request isn't an ActivityPub delivery.
+func (ap *ActivityPub) PostSharedInbox(c context.Context, w http.ResponseWriter, r *http.Request) (handled bool, err error) {
+	if !IsActivityPubPost(r) {
+		return
+	}
+
+	handled = true
+	c, authenticated, err := ap.AuthenticatePostInbox(c, w, r)
+	if err != nil || !authenticated {
+		return
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	c = context.WithValue(c, model.ContextKeyDelivery, b)
+	b, ok, err := ap.translateBookWyrm(c, w, b)
+	if err != nil || !ok {
+		return
+	}
+	var m map[string]interface{}
+	if jsonErr := json.Unmarshal(b, &m); jsonErr != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	t, typeErr := streams.ToType(c, m)
+	if typeErr != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	activity, ok := t.(pub.Activity)
+	if !ok || activity.GetJSONLDId() == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	id := activity.GetJSONLDId().Get()
+
+	actor, actorErr := firstActor(activity)
+	if actorErr != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if ap.rejects(actor.Hostname()) {
+		log.Printf("refusing activity from %s, whose server is rejected", actor)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	recipients, err := ap.localRecipients(c, actor, activity)
+	if err != nil {
+		return
+	}
+	if len(recipients) == 0 {
+		log.Printf("no local recipients for %s delivered to the shared inbox", id)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	seen, err := ap.db.Seen(c, id)
+	if err != nil {
+		return
+	}
+	if !seen {
+		// let go-fed handle the delivery as if it were to the first recipient's own inbox
+		delivery := r.Clone(c)
+		delivery.URL.Path = recipients[0].InboxIRI().Path
+		delivery.Body = ioutil.NopCloser(bytes.NewReader(b))
+		if _, err = ap.NewFederatingActor().PostInbox(c, w, delivery); err != nil {
+			return
+		}
+		recipients = recipients[1:]
+	}
+
+	for _, user := range recipients {
+		if err = ap.db.AddToInbox(c, user, id); err != nil {
+			return
+		}
+	}
+	if seen {
+		w.WriteHeader(http.StatusOK)
+	}
+	return
+}
+
+// localRecipients returns the local users an activity by actor is addressed to, either directly or as their followers. Activities from a relay we subscribe to are for the instance actor, which follows it. Users who have blocked the actor are left out.
+func (ap *ActivityPub) localRecipients(c context.Context, actor *url.URL, activity pub.Activity) ([]*model.User, error) {
+	var recipients []*model.User
+	added := make(map[string]bool)
+	add := func(user *model.User) error {
+		if added[user.ID.String()] {
+			return nil
+		}
+		added[user.ID.String()] = true
+		if blocked, err := ap.blocksRepo.Blocks(user, actor.String()); err != nil || blocked {
+			return err
+		}
+		recipients = append(recipients, user)
+		return nil
+	}
+
+	for _, iri := range database.Audience(activity) {
+		if pub.IsPublic(iri.String()) {
+			continue
+		}
+		if isFollowersOf(iri, actor) {
+			follows, err := ap.followingRepo.Followers(actor.String())
+			if err != nil {
+				return nil, err
+			}
+			for i := range follows {
+				if err := add(&follows[i].User); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		user, err := ap.db.LocalUser(c, iri)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			if err := add(user); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// relays address what they pass on to their own followers, which we're only one of through the instance actor
+	relay, err := ap.relaysRepo.GetByActor(actor.String())
+	if errors.Is(err, relays.ErrNotFound) {
+		return recipients, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if relay.Accepted() {
+		instance, err := ap.InstanceActor()
+		if err != nil {
+			return nil, err
+		}
+		if err := add(instance); err != nil {
+			return nil, err
+		}
+	}
+	return recipients, nil
+}
+
+// isFollowersOf guesses whether a collection is the followers of an actor, without having to fetch the actor. Mastodon, Pleroma and BookWyrm all name the collection this way.
+func isFollowersOf(collection, actor *url.URL) bool {
+	return collection.String() == strings.TrimSuffix(actor.String(), "/")+"/followers"
+}
+
+// IsActivityPubPost determines whether a request is an ActivityPub delivery.
+func IsActivityPubPost(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	contentType := r.Header.Get("Content-Type")
+	return strings.Contains(contentType, "application/activity+json") || strings.Contains(contentType, "application/ld+json")
+}
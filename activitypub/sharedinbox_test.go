@@ -0,0 +1,136 @@
+package activitypub
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/exlibris-fed/exlibris/config"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	relayActor    = "https://relay.example/actor"
+	relayAnnounce = "https://relay.example/activities/1"
+	relayedRead   = "https://bookwyrm.example/user/alice/read/1"
+	instanceInbox = "https://exlibris.example/user/exlibris.example/inbox"
+)
+
+func TestPostSharedInbox_RelayAnnounce(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	db, _ := gorm.Open("postgres", conn)
+	ap := New(db, &config.Config{Scheme: "https", Domain: "exlibris.example"})
+	instance, err := model.NewInstanceActor("exlibris.example")
+	if !assert.NoError(t, err) {
+		return
+	}
+	ap.instance.user = instance
+
+	// the announce is addressed to the relay's followers, which we're only one of through the instance actor
+	expectNoDomainBlock(mock, "relay.example", "example")
+	expectAcceptedRelay(mock)
+	expectNotBlocked(mock, instance.ID)
+	mock.ExpectQuery("^" + regexp.QuoteMeta(`SELECT count(*) FROM "inbox_entries"  WHERE "inbox_entries"."deleted_at" IS NULL AND ((uri = $1))`)).
+		WithArgs(relayAnnounce).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	// so it is delivered to the instance actor's inbox
+	expectInstanceActor(mock, instance)
+	expectNoDomainBlock(mock, "relay.example", "example")
+	expectNotBlocked(mock, instance.ID)
+	expectNotInInbox(mock)
+	mock.ExpectQuery("^" + regexp.QuoteMeta(`SELECT * FROM "inbox_entries"  WHERE "inbox_entries"."deleted_at" IS NULL AND ((inbox_iri = $1))`)).
+		WithArgs(instanceInbox).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "inbox_iri", "uri"}))
+	expectInstanceActor(mock, instance)
+	expectNotInInbox(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery("^"+regexp.QuoteMeta(`INSERT INTO "inbox_entries"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg(), instance.ID, instanceInbox, relayAnnounce, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	// and the relay's announce is handled, which saves the read unless we already have it
+	expectAcceptedRelay(mock)
+	expectNoDomainBlock(mock, "bookwyrm.example", "example")
+	mock.ExpectQuery("^" + regexp.QuoteMeta(`SELECT * FROM "reads"  WHERE "reads"."deleted_at" IS NULL AND ((id = $1))`)).
+		WithArgs(relayedRead).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(relayedRead))
+	mock.ExpectQuery("^" + regexp.QuoteMeta(`SELECT * FROM "authors"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"open_library_id"}))
+	expectNotStored(mock, relayAnnounce)
+	expectNotStored(mock, relayAnnounce)
+
+	relay, _ := url.Parse(relayActor)
+	c := context.WithValue(context.Background(), model.ContextKeySignedBy, relay)
+	r := httptest.NewRequest(http.MethodPost, "https://exlibris.example/inbox", strings.NewReader(`{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id": "`+relayAnnounce+`",
+		"type": "Announce",
+		"actor": "`+relayActor+`",
+		"to": ["https://relay.example/followers"],
+		"object": "`+relayedRead+`"
+	}`))
+	r.Header.Set("Content-Type", "application/activity+json")
+	w := httptest.NewRecorder()
+
+	handled, err := ap.PostSharedInbox(c, w, r)
+	assert.True(t, handled)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func expectNoDomainBlock(mock sqlmock.Sqlmock, domains ...string) {
+	for _, domain := range domains {
+		mock.ExpectQuery("^" + regexp.QuoteMeta(`SELECT * FROM "domain_blocks"  WHERE (domain = $1)`)).
+			WithArgs(domain).
+			WillReturnError(gorm.ErrRecordNotFound)
+	}
+}
+
+func expectAcceptedRelay(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("^" + regexp.QuoteMeta(`SELECT * FROM "relays"  WHERE (actor_iri = $1)`)).
+		WithArgs(relayActor).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "inbox", "actor_iri", "follow_id", "status"}).
+			AddRow(uuid.New(), "https://relay.example/inbox", relayActor, "https://exlibris.example/user/exlibris.example/follow/1", model.RelayAccepted))
+}
+
+func expectNotBlocked(mock sqlmock.Sqlmock, userID uuid.UUID) {
+	mock.ExpectQuery("^"+regexp.QuoteMeta(`SELECT count(*) FROM "blocks"  WHERE (user_id = $1 AND id = $2)`)).
+		WithArgs(userID, relayActor).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+}
+
+func expectInstanceActor(mock sqlmock.Sqlmock, instance *model.User) {
+	mock.ExpectQuery("^"+regexp.QuoteMeta(`SELECT * FROM "users"  WHERE "users"."deleted_at" IS NULL AND ((LOWER(username) = LOWER($1) AND local = $2))`)).
+		WithArgs(instance.Username, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "human_id", "username", "local", "instance"}).
+			AddRow(instance.ID, instance.HumanID, instance.Username, true, true))
+}
+
+func expectNotInInbox(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("^"+regexp.QuoteMeta(`SELECT count(*) FROM "inbox_entries"  WHERE "inbox_entries"."deleted_at" IS NULL AND ((inbox_iri = $1 AND uri = $2))`)).
+		WithArgs(instanceInbox, relayAnnounce).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+}
+
+func expectNotStored(mock sqlmock.Sqlmock, id string) {
+	mock.ExpectQuery("^" + regexp.QuoteMeta(`SELECT * FROM "reads"  WHERE "reads"."deleted_at" IS NULL AND ((id = $1))`)).
+		WithArgs(id).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectQuery("^" + regexp.QuoteMeta(`SELECT * FROM "reviews"  WHERE "reviews"."deleted_at" IS NULL AND ((uri = $1))`)).
+		WithArgs(id).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectQuery("^" + regexp.QuoteMeta(`SELECT * FROM "replies"  WHERE "replies"."deleted_at" IS NULL AND ((uri = $1))`)).
+		WithArgs(id).
+		WillReturnError(gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,247 @@
+// Package signature verifies HTTP Signatures (https://tools.ietf.org/html/draft-cavage-http-signatures-12) on requests made by remote ActivityPub servers.
+package signature
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/exlibris-fed/exlibris/key"
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/pub"
+	"github.com/go-fed/httpsig"
+)
+
+const (
+	// DefaultMaxClockSkew is how far the Date header of a signed request may differ from our clock before it is rejected.
+	DefaultMaxClockSkew = 5 * time.Minute
+
+	headerRequestTarget = "(request-target)"
+	headerHost          = "host"
+	headerDate          = "date"
+	headerDigest        = "digest"
+)
+
+var (
+	// ErrUnsigned is returned when a request has no HTTP Signature.
+	ErrUnsigned = errors.New("request is not signed")
+	// ErrMissingHeaders is returned when a signature does not cover the headers we require.
+	ErrMissingHeaders = errors.New("signature does not cover required headers")
+	// ErrClockSkew is returned when the Date of a request is too far from the current time.
+	ErrClockSkew = errors.New("request date is outside of the allowed window")
+	// ErrDigestMismatch is returned when the Digest header is missing or does not match the body.
+	ErrDigestMismatch = errors.New("digest does not match request body")
+	// ErrKeyNotFound is returned when the key used to sign a request cannot be retrieved.
+	ErrKeyNotFound = errors.New("signing key could not be retrieved")
+	// ErrInvalidSignature is returned when the signature does not verify against the key.
+	ErrInvalidSignature = errors.New("signature is invalid")
+)
+
+// A KeyStore caches the keys of remote actors.
+type KeyStore interface {
+	Get(id string) (*model.RemoteKey, error)
+	Save(key *model.RemoteKey) error
+}
+
+// A KeyFetcher dereferences the key with the given id.
+type KeyFetcher func(id string) (*model.RemoteKey, error)
+
+// A Verifier checks the HTTP Signatures of incoming requests.
+type Verifier struct {
+	keys         KeyStore
+	fetchKey     KeyFetcher
+	clock        pub.Clock
+	maxClockSkew time.Duration
+}
+
+// New returns a Verifier which caches keys in the provided store, dereferencing those it doesn't have (or that have stopped verifying) with fetchKey.
+func New(keys KeyStore, fetchKey KeyFetcher, clock pub.Clock) *Verifier {
+	return &Verifier{
+		keys:         keys,
+		fetchKey:     fetchKey,
+		clock:        clock,
+		maxClockSkew: DefaultMaxClockSkew,
+	}
+}
+
+// Verify checks the signature on a request and returns the key it was signed with. POST requests must also sign the Digest header, which is checked against the body. The body is restored so that it can be read again afterwards. If a cached key doesn't verify the signature it is fetched again, in case its owner has rotated it.
+func (v *Verifier) Verify(r *http.Request) (*model.RemoteKey, error) {
+	headers, err := signedHeaders(r)
+	if err != nil {
+		return nil, err
+	}
+
+	required := []string{headerRequestTarget, headerHost, headerDate}
+	if r.Method == http.MethodPost {
+		required = append(required, headerDigest)
+	}
+	for _, h := range required {
+		if !headers[h] {
+			return nil, fmt.Errorf("%w: %s", ErrMissingHeaders, h)
+		}
+	}
+
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrClockSkew, err.Error())
+	}
+	if skew := v.clock.Now().Sub(date); skew > v.maxClockSkew || skew < -v.maxClockSkew {
+		return nil, ErrClockSkew
+	}
+
+	if r.Method == http.MethodPost {
+		if err := verifyDigest(r); err != nil {
+			return nil, err
+		}
+	}
+
+	verifier, err := httpsig.NewVerifier(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSignature, err.Error())
+	}
+
+	k, err := v.keys.Get(verifier.KeyId())
+	if err != nil {
+		if k, err = v.refresh(verifier.KeyId()); err != nil {
+			return nil, err
+		}
+		if err := check(verifier, k); err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+
+	err = check(verifier, k)
+	if err == nil {
+		return k, nil
+	} else if !errors.Is(err, ErrInvalidSignature) {
+		return nil, err
+	}
+	fresh, refreshErr := v.refresh(verifier.KeyId())
+	if refreshErr != nil || fresh.PEM == k.PEM {
+		return nil, err
+	}
+	log.Printf("key %s has been rotated", fresh.ID)
+	if err := check(verifier, fresh); err != nil {
+		return nil, err
+	}
+	return fresh, nil
+}
+
+// check verifies a signature against a key.
+func check(verifier httpsig.Verifier, k *model.RemoteKey) error {
+	publicKey, err := key.ParsePublicKey(k.PEM)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, err.Error())
+	}
+	if err := verifier.Verify(publicKey, httpsig.RSA_SHA256); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSignature, err.Error())
+	}
+	return nil
+}
+
+// refresh dereferences the key with the given id and caches it.
+func (v *Verifier) refresh(id string) (*model.RemoteKey, error) {
+	k, err := v.fetchKey(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, err.Error())
+	}
+	if err := v.keys.Save(k); err != nil {
+		// we can still verify this request, we'll just have to fetch it again next time
+		log.Printf("error caching key %s: %s", id, err.Error())
+	}
+	return k, nil
+}
+
+// signedHeaders returns the set of headers covered by the request's signature.
+func signedHeaders(r *http.Request) (map[string]bool, error) {
+	value := r.Header.Get("Signature")
+	if value == "" {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Signature ") {
+			return nil, ErrUnsigned
+		}
+		value = strings.TrimPrefix(auth, "Signature ")
+	}
+
+	headers := make(map[string]bool)
+	for _, param := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) != 2 || kv[0] != "headers" {
+			continue
+		}
+		for _, h := range strings.Fields(strings.Trim(kv[1], `"`)) {
+			headers[strings.ToLower(h)] = true
+		}
+		return headers, nil
+	}
+
+	// per the spec, only the date is signed if the list is omitted
+	headers[headerDate] = true
+	return headers, nil
+}
+
+// verifyDigest checks the request body against the Digest header (RFC 3230) and restores the body so it can be read again.
+func verifyDigest(r *http.Request) error {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		r.Body.Close()
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	digest := r.Header.Get("Digest")
+	if digest == "" {
+		return ErrDigestMismatch
+	}
+	for _, d := range strings.Split(digest, ",") {
+		pieces := strings.SplitN(strings.TrimSpace(d), "=", 2)
+		if len(pieces) != 2 {
+			continue
+		}
+		var h hash.Hash
+		switch strings.ToUpper(pieces[0]) {
+		case "SHA-256":
+			h = sha256.New()
+		case "SHA-512":
+			h = sha512.New()
+		default:
+			continue
+		}
+		h.Write(body)
+		if base64.StdEncoding.EncodeToString(h.Sum(nil)) == pieces[1] {
+			return nil
+		}
+		return ErrDigestMismatch
+	}
+	return fmt.Errorf("%w: no supported algorithm", ErrDigestMismatch)
+}
+
+// StatusCode returns the http status that should be written when a request fails verification with the given error.
+func StatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrDigestMismatch):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrUnsigned),
+		errors.Is(err, ErrMissingHeaders),
+		errors.Is(err, ErrClockSkew),
+		errors.Is(err, ErrKeyNotFound),
+		errors.Is(err, ErrInvalidSignature):
+		return http.StatusUnauthorized
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,218 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/exlibris-fed/exlibris/key"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/go-fed/httpsig"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c fakeClock) Now() time.Time {
+	return c.now
+}
+
+type memoryKeys map[string]*model.RemoteKey
+
+func (m memoryKeys) Get(id string) (*model.RemoteKey, error) {
+	if k, ok := m[id]; ok {
+		return k, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (m memoryKeys) Save(k *model.RemoteKey) error {
+	m[k.ID] = k
+	return nil
+}
+
+// remoteActor is an actor on another server, whose key is fetched like the resolver would fetch it.
+type remoteActor struct {
+	private *rsa.PrivateKey
+	pem     string
+	fetches int
+	gone    bool
+}
+
+func newRemoteActor(t *testing.T) *remoteActor {
+	private, err := key.NewOfSize(2048)
+	assert.NoError(t, err)
+	pem, err := key.MarshalPublicKey(&private.PublicKey)
+	assert.NoError(t, err)
+	return &remoteActor{private: private, pem: pem}
+}
+
+func (a *remoteActor) fetchKey(id string) (*model.RemoteKey, error) {
+	a.fetches++
+	if a.gone || id != a.keyID() {
+		return nil, errors.New("not found")
+	}
+	return &model.RemoteKey{ID: id, Owner: a.actorID(), PEM: a.pem}, nil
+}
+
+func (a *remoteActor) actorID() string {
+	return "https://mastodon.example/users/alice"
+}
+
+func (a *remoteActor) keyID() string {
+	return a.actorID() + "#main-key"
+}
+
+// request builds a delivery to a local inbox signed by the remote actor.
+func (a *remoteActor) request(t *testing.T, date time.Time, headers []string) *http.Request {
+	body := []byte(`{"type":"Read","actor":"` + a.actorID() + `"}`)
+	r := httptest.NewRequest(http.MethodPost, "https://exlibris.example/user/bob/inbox", bytes.NewReader(body))
+	r.Header.Set("Date", date.UTC().Format(http.TimeFormat))
+	r.Header.Set("Content-Type", "application/activity+json")
+	r.Header.Set("Host", r.Host)
+
+	signer, _, err := httpsig.NewSigner([]httpsig.Algorithm{httpsig.RSA_SHA256}, httpsig.DigestSha256, headers, httpsig.Signature, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, signer.SignRequest(a.private, a.keyID(), r, body))
+	return r
+}
+
+func defaultHeaders() []string {
+	return []string{"(request-target)", "host", "date", "digest"}
+}
+
+func newVerifier(now time.Time, keys memoryKeys, remote *remoteActor) *Verifier {
+	return New(keys, remote.fetchKey, fakeClock{now: now})
+}
+
+func TestVerify(t *testing.T) {
+	remote := newRemoteActor(t)
+	now := time.Now()
+
+	v := newVerifier(now, memoryKeys{}, remote)
+	r := remote.request(t, now, defaultHeaders())
+	k, err := v.Verify(r)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, remote.actorID(), k.Owner)
+	}
+
+	// the body must still be readable by go-fed
+	b, err := ioutil.ReadAll(r.Body)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), remote.actorID())
+}
+
+func TestVerify_CachesKey(t *testing.T) {
+	remote := newRemoteActor(t)
+	now := time.Now()
+	keys := memoryKeys{}
+
+	v := newVerifier(now, keys, remote)
+	_, err := v.Verify(remote.request(t, now, defaultHeaders()))
+	assert.NoError(t, err)
+	_, err = v.Verify(remote.request(t, now, defaultHeaders()))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, remote.fetches)
+	assert.Contains(t, keys, remote.keyID())
+}
+
+func TestVerify_RotatedKey(t *testing.T) {
+	remote := newRemoteActor(t)
+	now := time.Now()
+
+	// the key cached before the actor rotated it
+	old, err := key.NewOfSize(2048)
+	assert.NoError(t, err)
+	pem, err := key.MarshalPublicKey(&old.PublicKey)
+	assert.NoError(t, err)
+	keys := memoryKeys{
+		remote.keyID(): &model.RemoteKey{ID: remote.keyID(), Owner: remote.actorID(), PEM: pem},
+	}
+
+	k, err := newVerifier(now, keys, remote).Verify(remote.request(t, now, defaultHeaders()))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, remote.fetches)
+	if assert.NotNil(t, k) {
+		assert.NotEqual(t, pem, k.PEM)
+		assert.Equal(t, k.PEM, keys[remote.keyID()].PEM)
+	}
+}
+
+func TestVerify_ErrUnsigned(t *testing.T) {
+	now := time.Now()
+	r := httptest.NewRequest(http.MethodPost, "https://exlibris.example/user/bob/inbox", bytes.NewReader([]byte("{}")))
+	r.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+
+	_, err := newVerifier(now, memoryKeys{}, newRemoteActor(t)).Verify(r)
+
+	assert.True(t, errors.Is(err, ErrUnsigned))
+	assert.Equal(t, http.StatusUnauthorized, StatusCode(err))
+}
+
+func TestVerify_ErrMissingHeaders(t *testing.T) {
+	remote := newRemoteActor(t)
+	now := time.Now()
+
+	r := remote.request(t, now, []string{"(request-target)", "host", "date"})
+	_, err := newVerifier(now, memoryKeys{}, remote).Verify(r)
+
+	assert.True(t, errors.Is(err, ErrMissingHeaders))
+	assert.Equal(t, http.StatusUnauthorized, StatusCode(err))
+}
+
+func TestVerify_ErrClockSkew(t *testing.T) {
+	remote := newRemoteActor(t)
+	now := time.Now()
+
+	r := remote.request(t, now.Add(-time.Hour), defaultHeaders())
+	_, err := newVerifier(now, memoryKeys{}, remote).Verify(r)
+
+	assert.True(t, errors.Is(err, ErrClockSkew))
+	assert.Equal(t, 0, remote.fetches)
+}
+
+func TestVerify_ErrDigestMismatch(t *testing.T) {
+	remote := newRemoteActor(t)
+	now := time.Now()
+
+	r := remote.request(t, now, defaultHeaders())
+	r.Body = ioutil.NopCloser(bytes.NewReader([]byte(`{"type":"Delete"}`)))
+	_, err := newVerifier(now, memoryKeys{}, remote).Verify(r)
+
+	assert.True(t, errors.Is(err, ErrDigestMismatch))
+	assert.Equal(t, http.StatusBadRequest, StatusCode(err))
+}
+
+func TestVerify_ErrInvalidSignature(t *testing.T) {
+	remote := newRemoteActor(t)
+	now := time.Now()
+
+	// sign with a key other than the one the remote actor advertises
+	impostor := newRemoteActor(t)
+
+	_, err := newVerifier(now, memoryKeys{}, remote).Verify(impostor.request(t, now, defaultHeaders()))
+
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+	assert.Equal(t, http.StatusUnauthorized, StatusCode(err))
+}
+
+func TestVerify_ErrKeyNotFound(t *testing.T) {
+	remote := newRemoteActor(t)
+	now := time.Now()
+
+	r := remote.request(t, now, defaultHeaders())
+	remote.gone = true
+	_, err := newVerifier(now, memoryKeys{}, remote).Verify(r)
+
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+}
@@ -0,0 +1,87 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/go-fed/activity/pub"
+)
+
+// vocabularyTransport adds model.VocabularyContext to the activities go-fed delivers, which it serializes itself.
+type vocabularyTransport struct {
+	pub.Transport
+}
+
+// Deliver adds the vocabulary to an activity and delivers it to a single inbox.
+func (t vocabularyTransport) Deliver(c context.Context, b []byte, to *url.URL) error {
+	b, err := model.MarshalWithVocabulary(b)
+	if err != nil {
+		return err
+	}
+	return t.Transport.Deliver(c, b, to)
+}
+
+// BatchDeliver adds the vocabulary to an activity and delivers it to each of the inboxes.
+func (t vocabularyTransport) BatchDeliver(c context.Context, b []byte, recipients []*url.URL) error {
+	b, err := model.MarshalWithVocabulary(b)
+	if err != nil {
+		return err
+	}
+	return t.Transport.BatchDeliver(c, b, recipients)
+}
+
+// bufferedResponse holds on to a response until it has been written in full, so that it can be changed before it's sent.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+// WithVocabulary wraps a go-fed handler which serves ActivityStreams values, such as the pages of an outbox, so that model.VocabularyContext is added to what it serves. Anything else it writes, such as an error, is passed on as it is.
+func WithVocabulary(handler pub.HandlerFunc) pub.HandlerFunc {
+	return func(c context.Context, w http.ResponseWriter, r *http.Request) (bool, error) {
+		buffered := &bufferedResponse{header: http.Header{}}
+		handled, err := handler(c, buffered, r)
+		if buffered.status == 0 {
+			return handled, err
+		}
+
+		body := buffered.body.Bytes()
+		if handled && err == nil && (buffered.status == http.StatusOK || buffered.status == http.StatusGone) {
+			if b, vocabErr := model.MarshalWithVocabulary(body); vocabErr == nil {
+				body = b
+				hashed := sha256.Sum256(body)
+				buffered.header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(hashed[:]))
+			}
+		}
+		for name, values := range buffered.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(buffered.status)
+		if _, writeErr := w.Write(body); err == nil {
+			err = writeErr
+		}
+		return handled, err
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/exlibris-fed/exlibris/activitypub"
+	"github.com/exlibris-fed/exlibris/infrastructure/users"
+
+	"github.com/jinzhu/gorm"
+)
+
+// runCommand runs a maintenance command given on the command line instead of starting the server:
+//
+//	exlibris rotate-key <username>
+func runCommand(args []string, db *gorm.DB, ap *activitypub.ActivityPub) error {
+	switch args[0] {
+	case "rotate-key":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: exlibris rotate-key <username>")
+		}
+		user, err := users.New(db).GetByUsername(args[1])
+		if err != nil {
+			return fmt.Errorf("error getting user %s: %w", args[1], err)
+		}
+		if !user.Local {
+			return fmt.Errorf("%s is not a local user", args[1])
+		}
+		if err := ap.RotateKey(user); err != nil {
+			return err
+		}
+		fmt.Printf("rotated key of %s, which is now %s\n", user.Username, user.PublicKeyID())
+		return nil
+	}
+	return fmt.Errorf("unknown command %s", args[0])
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+
+	"github.com/exlibris-fed/exlibris/key"
 )
 
 type Config struct {
@@ -13,6 +16,15 @@ type Config struct {
 	Secret string
 	DSN    string
 	SMTP   SMTPConfig
+
+	// SecureMode requires that ActivityPub GETs be signed by a remote actor or made by a logged in user.
+	SecureMode bool
+
+	// KeySize is the size in bits of the keypairs generated for users.
+	KeySize int
+
+	// AdvisoryLocks locks objects with Postgres advisory locks instead of only within the process, so that several replicas can share the database.
+	AdvisoryLocks bool
 }
 
 type SMTPConfig struct {
@@ -64,6 +76,32 @@ func Load() *Config {
 	if smtpPassword == "" {
 		log.Fatalf("SMTPPASSWORD not provided")
 	}
+	secureMode := false
+	if s := os.Getenv("SECURE_MODE"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			log.Fatalf("SECURE_MODE must be true or false")
+		}
+		secureMode = b
+	}
+
+	keySize := key.DefaultKeySize
+	if s := os.Getenv("KEY_SIZE"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || !key.IsAllowedSize(n) {
+			log.Fatalf("KEY_SIZE must be 2048 or 4096")
+		}
+		keySize = n
+	}
+
+	advisoryLocks := false
+	if s := os.Getenv("ADVISORY_LOCKS"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			log.Fatalf("ADVISORY_LOCKS must be true or false")
+		}
+		advisoryLocks = b
+	}
 
 	return &Config{
 		Host:   host,
@@ -78,5 +116,9 @@ func Load() *Config {
 			Username: smtpUsername,
 			Password: smtpPassword,
 		},
+		SecureMode: secureMode,
+		KeySize:    keySize,
+
+		AdvisoryLocks: advisoryLocks,
 	}
 }
@@ -0,0 +1,27 @@
+package dto
+
+import "time"
+
+// A Block is an actor a user has blocked.
+type Block struct {
+	Actor     string    `json:"actor"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// A DomainBlock is a server federation is limited with.
+type DomainBlock struct {
+	Domain    string    `json:"domain"`
+	Level     string    `json:"level"`
+	Reason    string    `json:"reason,omitempty"`
+	Timestamp time.Time `json:"timestamp,omitempty"`
+}
+
+// A Relay is an ActivityPub relay the server is subscribed to.
+type Relay struct {
+	ID        string    `json:"id,omitempty"`
+	Inbox     string    `json:"inbox"`
+	Actor     string    `json:"actor,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Publish   bool      `json:"publish"`
+	Timestamp time.Time `json:"timestamp,omitempty"`
+}
@@ -0,0 +1,15 @@
+package dto
+
+import "time"
+
+// An ActorRequest is the body of a request about another actor, such as to follow or block them, or to approve or reject their request to follow.
+type ActorRequest struct {
+	Actor string `json:"actor"`
+}
+
+// A Follow is an actor who follows a user, or who a user follows.
+type Follow struct {
+	Actor     string    `json:"actor"`
+	Pending   bool      `json:"pending"`
+	Timestamp time.Time `json:"timestamp"`
+}
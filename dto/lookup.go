@@ -0,0 +1,16 @@
+package dto
+
+// A Profile is a user found by looking up their handle, along with whether the authenticated user follows them.
+type Profile struct {
+	Actor                     string `json:"actor"`
+	Handle                    string `json:"handle"`
+	Username                  string `json:"username"`
+	Name                      string `json:"name"`
+	Summary                   string `json:"summary"`
+	URL                       string `json:"url"`
+	Icon                      string `json:"icon,omitempty"`
+	ManuallyApprovesFollowers bool   `json:"manuallyApprovesFollowers"`
+	Following                 bool   `json:"following"`
+	// Pending is set when the authenticated user has asked to follow them and hasn't been accepted yet.
+	Pending bool `json:"pending"`
+}
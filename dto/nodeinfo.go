@@ -0,0 +1,44 @@
+package dto
+
+// NodeInfoLinks is the response to the NodeInfo discovery endpoint (.well-known/nodeinfo), pointing at the documents for each supported schema version.
+type NodeInfoLinks struct {
+	Links []WebfingerLink `json:"links"`
+}
+
+// NodeInfo is a NodeInfo 2.x document (http://nodeinfo.diaspora.software), describing the server to crawlers and admin tools.
+type NodeInfo struct {
+	Version           string                 `json:"version"`
+	Software          NodeInfoSoftware       `json:"software"`
+	Protocols         []string               `json:"protocols"`
+	Services          NodeInfoServices       `json:"services"`
+	OpenRegistrations bool                   `json:"openRegistrations"`
+	Usage             NodeInfoUsage          `json:"usage"`
+	Metadata          map[string]interface{} `json:"metadata"`
+}
+
+// NodeInfoSoftware identifies the software a server is running. Repository and Homepage were added in 2.1.
+type NodeInfoSoftware struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Repository string `json:"repository,omitempty"`
+	Homepage   string `json:"homepage,omitempty"`
+}
+
+// NodeInfoServices are the third party sites a server can import from or publish to.
+type NodeInfoServices struct {
+	Inbound  []string `json:"inbound"`
+	Outbound []string `json:"outbound"`
+}
+
+// NodeInfoUsage is usage statistics for a server.
+type NodeInfoUsage struct {
+	Users      NodeInfoUsers `json:"users"`
+	LocalPosts int           `json:"localPosts"`
+}
+
+// NodeInfoUsers counts the users registered on a server.
+type NodeInfoUsers struct {
+	Total          int `json:"total"`
+	ActiveHalfyear int `json:"activeHalfyear"`
+	ActiveMonth    int `json:"activeMonth"`
+}
@@ -0,0 +1,14 @@
+package dto
+
+import "time"
+
+// An ObjectRequest is the body of a request about a read or review, such as to like or announce it. Object is its IRI.
+type ObjectRequest struct {
+	Object string `json:"object"`
+}
+
+// A Reaction is a like or announce of a read or review.
+type Reaction struct {
+	Object    string    `json:"object"`
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -4,5 +4,17 @@ import "time"
 
 type Read struct {
 	Book
-	Timestamp time.Time `json:"timestamp"`
+	// ReadID identifies the read itself, for deleting it.
+	ReadID string `json:"read_id"`
+	// IRI is the ActivityPub id of the read, for liking or announcing it.
+	IRI           string    `json:"iri"`
+	Timestamp     time.Time `json:"timestamp"`
+	FollowersOnly bool      `json:"followers_only"`
+	Likes         int       `json:"likes"`
+	Announces     int       `json:"announces"`
+}
+
+// A ReadRequest holds the options when marking a book as read. The body is optional.
+type ReadRequest struct {
+	FollowersOnly bool `json:"followers_only"`
 }
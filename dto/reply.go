@@ -0,0 +1,19 @@
+package dto
+
+import "time"
+
+// A Reply is a comment on a review or on another reply, along with the replies to it.
+type Reply struct {
+	ID        string    `json:"id"`
+	IRI       string    `json:"iri"`
+	Author    string    `json:"author"`
+	Text      string    `json:"text"`
+	Timestamp time.Time `json:"timestamp"`
+	Replies   []Reply   `json:"replies"`
+}
+
+// A ReplyRequest is the body of a request to reply to a review. InReplyTo is the ID of the reply being answered, if it isn't the review itself.
+type ReplyRequest struct {
+	Text      string `json:"text"`
+	InReplyTo string `json:"in_reply_to,omitempty"`
+}
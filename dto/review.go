@@ -3,7 +3,16 @@ package dto
 import "time"
 
 type Review struct {
+	ID string `json:"id"`
+	// IRI is the ActivityPub id of the review, for liking or announcing it.
+	IRI       string    `json:"iri"`
 	Author    string    `json:"author"`
 	Text      string    `json:"text"`
+	Spoiler   string    `json:"spoiler,omitempty"`
+	Rating    int       `json:"rating,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
+	Likes     int       `json:"likes"`
+	Announces int       `json:"announces"`
+	// Replies is the discussion under the review, as a tree.
+	Replies []Reply `json:"replies"`
 }
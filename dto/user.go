@@ -1,8 +1,14 @@
 package dto
 
 const ContextActivityStreams = "https://www.w3.org/ns/activitystreams"
+
+// ContextSecurity is the JSON-LD context that defines `publicKey` and `publicKeyPem`.
+const ContextSecurity = "https://w3id.org/security/v1"
 const TypePerson = "Person"
 
+// TypeApplication is the type of the instance actor, which represents the server rather than a person.
+const TypeApplication = "Application"
+
 // An ActivityPubUser is a DTO when the request accepts `application/activity+json` (ActivityPub)
 type ActivityPubUser struct {
 	Context                   []string          `json:"@context"`
@@ -12,6 +18,7 @@ type ActivityPubUser struct {
 	Followers                 string            `json:"followers"`
 	Inbox                     string            `json:"inbox"`
 	Outbox                    string            `json:"outbox"`
+	Liked                     string            `json:"liked"`
 	Username                  string            `json:"preferredUsername"`
 	Name                      string            `json:"name"`
 	URL                       string            `json:"url"`
@@ -32,6 +39,12 @@ type PublicKey struct {
 	PEM   string `json:"publicKeyPem"`
 }
 
+// A PublicKeyDocument is a user's public key served on its own, for servers that look keys up outside of the actor.
+type PublicKeyDocument struct {
+	Context []string `json:"@context"`
+	PublicKey
+}
+
 // An Object is an ActivityPub object.
 type Object struct {
 	Type      string `json:"type"`
@@ -43,7 +56,7 @@ type Object struct {
 // NewActivityPubUser returns a struct with default values filled in
 func NewActivityPubUser() *ActivityPubUser {
 	return &ActivityPubUser{
-		Context:                   []string{ContextActivityStreams},
+		Context:                   []string{ContextActivityStreams, ContextSecurity},
 		Type:                      TypePerson,
 		ManuallyApprovesFollowers: true, // possible TODO
 		Endpoints:                 make(map[string]string),
@@ -1,5 +1,7 @@
 package dto
 
+import "encoding/xml"
+
 // A Webfinger is a response to the webfinger endpoint (.well-known/webfinger) to dereference a username.
 type Webfinger struct {
 	Subject string          `json:"subject"`
@@ -7,10 +9,16 @@ type Webfinger struct {
 	Links   []WebfingerLink `json:"links"`
 }
 
-// A WebfingerLink is a structured link in a Webfinger.
+// A WebfingerLink is a structured link in a Webfinger. It's also used for host-meta, which can be served as XML.
 type WebfingerLink struct {
-	Rel      string `json:"rel"`
-	Type     string `json:"type,omitempty"`
-	Href     string `json:"href,omitempty"`
-	Template string `json:"template,omitempty"`
+	Rel      string `json:"rel" xml:"rel,attr"`
+	Type     string `json:"type,omitempty" xml:"type,attr,omitempty"`
+	Href     string `json:"href,omitempty" xml:"href,attr,omitempty"`
+	Template string `json:"template,omitempty" xml:"template,attr,omitempty"`
+}
+
+// HostMeta is a response to the host-meta endpoint (.well-known/host-meta), which points at the webfinger endpoint.
+type HostMeta struct {
+	XMLName xml.Name        `json:"-" xml:"http://docs.oasis-open.org/ns/xri/xrd-1.0 XRD"`
+	Links   []WebfingerLink `json:"links" xml:"Link"`
 }
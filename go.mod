@@ -13,7 +13,7 @@ require (
 	github.com/gorilla/handlers v1.4.2
 	github.com/gorilla/mux v1.7.4
 	github.com/jinzhu/gorm v1.9.14
-	github.com/lib/pq v1.7.0 // indirect
+	github.com/lib/pq v1.7.0
 	github.com/mattn/go-sqlite3 v2.0.1+incompatible // indirect
 	github.com/stretchr/testify v1.6.1
 	golang.org/x/crypto v0.0.0-20200709230013-948cd5f35899
@@ -3,12 +3,31 @@ package handler
 import (
 	"log"
 	"net/http"
+
+	"github.com/exlibris-fed/exlibris/activitypub"
 )
 
+// HandleActivityPubAction serves the ActivityStreams representation of local objects, such as reads and reviews, to other servers, if they may see them. Browsers are served the front end instead, without checking, since it only shows them what the API lets them see.
 func (h *Handler) HandleActivityPubAction(w http.ResponseWriter, r *http.Request) {
 	log.Println("handling ap action")
+	w.Header().Add("Vary", "Accept")
+
+	if activitypub.PrefersHTML(r) {
+		// browsers following links to our objects get the front end, which fetches what they can see through the API
+		serveFrontEnd(w, r)
+		return
+	}
+
+	c, authorized, err := h.ap.AuthorizeFetch(r.Context(), w, r)
+	if err != nil {
+		log.Println("error authorizing ActivityStreams request:", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if !authorized {
+		return
+	}
 
-	if isActivityPubRequest, err := h.streamHandler(r.Context(), w, r); err != nil {
+	if isActivityPubRequest, err := h.streamHandler(c, w, r); err != nil {
 		// Do something with `err`
 		log.Println("error handling ActivityStreams request:", err.Error())
 		return
@@ -17,10 +36,6 @@ func (h *Handler) HandleActivityPubAction(w http.ResponseWriter, r *http.Request
 		log.Println("go fed took care of it")
 		return
 	}
-	// Here we return an error, but you may just as well decide
-	// to render a webpage instead. But be sure you've already
-	// applied the appropriate authorizations.
-	log.Println("wtf...?")
 	http.Error(w, "Non-ActivityPub request", http.StatusBadRequest)
 	return
 }
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/exlibris-fed/exlibris/dto"
+	"github.com/exlibris-fed/exlibris/infrastructure/domainblocks"
+	"github.com/exlibris-fed/exlibris/infrastructure/relays"
+	"github.com/exlibris-fed/exlibris/model"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// GetDomainBlocks returns the servers federation is limited with.
+func (h *Handler) GetDomainBlocks(w http.ResponseWriter, r *http.Request) {
+	list, err := h.domainBlocksRepo.List()
+	if err != nil {
+		log.Printf("error getting domain blocks: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := []dto.DomainBlock{}
+	for _, b := range list {
+		response = append(response, dto.DomainBlock{
+			Domain:    b.Domain,
+			Level:     b.Level,
+			Reason:    b.Reason,
+			Timestamp: b.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// BlockDomain limits federation with a server, or changes how it is limited.
+func (h *Handler) BlockDomain(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var request dto.DomainBlock
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	request.Domain = strings.TrimSpace(request.Domain)
+	if request.Domain == "" || strings.ContainsAny(request.Domain, "/:@ ") || !model.IsDomainBlockLevel(request.Level) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	block := &model.DomainBlock{
+		Domain: request.Domain,
+		Level:  request.Level,
+		Reason: request.Reason,
+	}
+	if err := h.ap.BlockDomain(block); err != nil {
+		log.Printf("error blocking %s: %s", request.Domain, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.DomainBlock{
+		Domain:    block.Domain,
+		Level:     block.Level,
+		Reason:    block.Reason,
+		Timestamp: block.CreatedAt,
+	})
+}
+
+// UnblockDomain federates with a server normally again.
+func (h *Handler) UnblockDomain(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	domain := mux.Vars(r)["domain"]
+	if err := h.ap.UnblockDomain(domain); errors.Is(err, domainblocks.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("error unblocking %s: %s", domain, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRelays returns the relays the server is subscribed to.
+func (h *Handler) GetRelays(w http.ResponseWriter, r *http.Request) {
+	list, err := h.ap.Relays()
+	if err != nil {
+		log.Printf("error getting relays: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := []dto.Relay{}
+	for i := range list {
+		response = append(response, relayResponse(&list[i]))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// AddRelay subscribes the server to a relay, given its inbox, or changes whether our public reads are published to it.
+func (h *Handler) AddRelay(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var request dto.Relay
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	inbox, err := url.Parse(strings.TrimSpace(request.Inbox))
+	if err != nil || (inbox.Scheme != "https" && inbox.Scheme != "http") || inbox.Host == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	relay, err := h.ap.Subscribe(inbox, request.Publish)
+	if err != nil {
+		log.Printf("error subscribing to relay %s: %s", inbox, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, relayResponse(relay))
+}
+
+// RemoveRelay unsubscribes the server from a relay.
+func (h *Handler) RemoveRelay(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err := h.ap.Unsubscribe(id); errors.Is(err, relays.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("error unsubscribing from relay %s: %s", id, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// relayResponse returns a relay as it's shown to admins.
+func relayResponse(relay *model.Relay) dto.Relay {
+	return dto.Relay{
+		ID:        relay.ID.String(),
+		Inbox:     relay.Inbox,
+		Actor:     relay.ActorIRI,
+		Status:    relay.Status,
+		Publish:   relay.Publish,
+		Timestamp: relay.CreatedAt,
+	}
+}
@@ -42,6 +42,11 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if h.ap.IsInstanceUsername(request.Username) {
+		// the instance actor has it
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
 
 	user, err := model.NewUser(request.Username, request.Password, request.Email, request.DisplayName)
 	if err != nil {
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/exlibris-fed/exlibris/dto"
+	"github.com/exlibris-fed/exlibris/infrastructure/blocks"
+	"github.com/exlibris-fed/exlibris/model"
+)
+
+// GetBlocks returns the actors the authenticated user has blocked.
+func (h *Handler) GetBlocks(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(model.ContextKeyAuthenticatedUser).(*model.User)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	list, err := h.blocksRepo.List(user)
+	if err != nil {
+		log.Printf("error getting who %s has blocked: %s", user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := []dto.Block{}
+	for _, b := range list {
+		response = append(response, dto.Block{
+			Actor:     b.ID,
+			Timestamp: b.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Block stops an actor interacting with the authenticated user.
+func (h *Handler) Block(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, actor, ok := actorRequest(w, r)
+	if !ok {
+		return
+	}
+	if actor.String() == user.IRI().String() {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	b, err := h.ap.Block(user, actor)
+	if err != nil {
+		log.Printf("error blocking %s as %s: %s", actor, user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, dto.Block{
+		Actor:     b.ID,
+		Timestamp: b.CreatedAt,
+	})
+}
+
+// Unblock lets an actor interact with the authenticated user again.
+func (h *Handler) Unblock(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, actor, ok := actorRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.ap.Unblock(user, actor); errors.Is(err, blocks.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("error unblocking %s as %s: %s", actor, user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/exlibris-fed/exlibris/activitypub"
+	"github.com/exlibris-fed/exlibris/activitypub/bookwyrm"
 	"github.com/exlibris-fed/exlibris/dto"
 	"github.com/gorilla/mux"
 )
@@ -50,3 +52,61 @@ func (h *Handler) GetBook(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
 	w.Write(b)
 }
+
+// HandleBook serves the page of a book. ActivityPub clients are served the book as a BookWyrm Edition instead, since BookWyrm only knows about books it can fetch, and our reviews and reads are in reply to the book there.
+func (h *Handler) HandleBook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	if activitypub.PrefersHTML(r) {
+		serveFrontEnd(w, r)
+		return
+	}
+	book, err := h.bookService.Get(mux.Vars(r)["book"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeBookWyrm(w, bookwyrm.Edition(book))
+}
+
+// HandleBookWork serves a book as the BookWyrm Work its Edition belongs to. Browsers are sent to the book's page.
+func (h *Handler) HandleBookWork(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	if activitypub.PrefersHTML(r) {
+		http.Redirect(w, r, "/book/"+mux.Vars(r)["book"], http.StatusSeeOther)
+		return
+	}
+	book, err := h.bookService.Get(mux.Vars(r)["book"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeBookWyrm(w, bookwyrm.Work(book))
+}
+
+// HandleAuthor serves an author as a BookWyrm Author, for the BookWyrm servers that fetch the authors of our books. Browsers are sent to the author's page on OpenLibrary.
+func (h *Handler) HandleAuthor(w http.ResponseWriter, r *http.Request) {
+	id := "/authors/" + mux.Vars(r)["author"]
+	w.Header().Add("Vary", "Accept")
+	if activitypub.PrefersHTML(r) {
+		http.Redirect(w, r, "https://openlibrary.org"+id, http.StatusSeeOther)
+		return
+	}
+	author := h.authorService.Get(id)
+	if author == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeBookWyrm(w, bookwyrm.Author(author))
+}
+
+// writeBookWyrm writes an object in the form BookWyrm serves it as the response to a request.
+func writeBookWyrm(w http.ResponseWriter, object map[string]interface{}) {
+	b, err := json.Marshal(object)
+	if err != nil {
+		log.Printf("error marshalling json for %v: %s", object["id"], err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/activity+json")
+	w.Write(b)
+}
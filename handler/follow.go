@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/exlibris-fed/exlibris/dto"
+	"github.com/exlibris-fed/exlibris/infrastructure/followers"
+	"github.com/exlibris-fed/exlibris/infrastructure/following"
+	"github.com/exlibris-fed/exlibris/model"
+)
+
+// GetFollowers returns the actors who follow the authenticated user.
+func (h *Handler) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(model.ContextKeyAuthenticatedUser).(*model.User)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	list, err := h.followersRepo.List(user)
+	if err != nil {
+		log.Printf("error getting followers of %s: %s", user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeFollowers(w, list)
+}
+
+// GetFollowRequests returns the actors waiting for the authenticated user to approve their request to follow.
+func (h *Handler) GetFollowRequests(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(model.ContextKeyAuthenticatedUser).(*model.User)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	list, err := h.followersRepo.Pending(user)
+	if err != nil {
+		log.Printf("error getting follow requests for %s: %s", user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeFollowers(w, list)
+}
+
+// ApproveFollowRequest lets an actor follow the authenticated user.
+func (h *Handler) ApproveFollowRequest(w http.ResponseWriter, r *http.Request) {
+	h.respondToFollowRequest(w, r, h.ap.ApproveFollower)
+}
+
+// RejectFollowRequest declines an actor's request to follow the authenticated user. It can also be used to remove an existing follower.
+func (h *Handler) RejectFollowRequest(w http.ResponseWriter, r *http.Request) {
+	h.respondToFollowRequest(w, r, h.ap.RejectFollower)
+}
+
+func (h *Handler) respondToFollowRequest(w http.ResponseWriter, r *http.Request, respond func(*model.User, *url.URL) error) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, actor, ok := actorRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := respond(user, actor); errors.Is(err, followers.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("error responding to follow of %s by %s: %s", user.Username, actor, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetFollowing returns the actors the authenticated user follows, or has asked to follow.
+func (h *Handler) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(model.ContextKeyAuthenticatedUser).(*model.User)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	list, err := h.followingRepo.List(user)
+	if err != nil {
+		log.Printf("error getting who %s follows: %s", user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := []dto.Follow{}
+	for _, f := range list {
+		response = append(response, dto.Follow{
+			Actor:     f.ID,
+			Pending:   !f.Accepted,
+			Timestamp: f.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Follow sends a request from the authenticated user to follow an actor.
+func (h *Handler) Follow(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, actor, ok := actorRequest(w, r)
+	if !ok {
+		return
+	}
+	if actor.String() == user.IRI().String() {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f, err := h.ap.Follow(user, actor)
+	if err != nil {
+		log.Printf("error following %s as %s: %s", actor, user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, dto.Follow{
+		Actor:     f.ID,
+		Pending:   !f.Accepted,
+		Timestamp: f.CreatedAt,
+	})
+}
+
+// Unfollow stops the authenticated user following an actor.
+func (h *Handler) Unfollow(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, actor, ok := actorRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.ap.Unfollow(user, actor); errors.Is(err, following.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("error unfollowing %s as %s: %s", actor, user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// actorRequest reads the authenticated user and the actor in the body of a request about another actor. If it returns false a response has already been written.
+func actorRequest(w http.ResponseWriter, r *http.Request) (*model.User, *url.URL, bool) {
+	user, ok := r.Context().Value(model.ContextKeyAuthenticatedUser).(*model.User)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	var request dto.ActorRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, nil, false
+	}
+	actor, err := url.Parse(request.Actor)
+	if err != nil || (actor.Scheme != "https" && actor.Scheme != "http") || actor.Host == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, nil, false
+	}
+	return user, actor, true
+}
+
+func writeFollowers(w http.ResponseWriter, list []model.Follower) {
+	response := []dto.Follow{}
+	for _, f := range list {
+		response = append(response, dto.Follow{
+			Actor:     f.ID,
+			Pending:   f.Pending,
+			Timestamp: f.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	b, err := json.Marshal(response)
+	if err != nil {
+		log.Println("error marshalling json: " + err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
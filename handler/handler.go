@@ -4,10 +4,17 @@ import (
 	"github.com/exlibris-fed/exlibris/activitypub"
 	"github.com/exlibris-fed/exlibris/config"
 	"github.com/exlibris-fed/exlibris/infrastructure/authors"
+	"github.com/exlibris-fed/exlibris/infrastructure/blocks"
 	"github.com/exlibris-fed/exlibris/infrastructure/books"
+	"github.com/exlibris-fed/exlibris/infrastructure/domainblocks"
+	"github.com/exlibris-fed/exlibris/infrastructure/followers"
+	"github.com/exlibris-fed/exlibris/infrastructure/following"
+	"github.com/exlibris-fed/exlibris/infrastructure/reactions"
 	"github.com/exlibris-fed/exlibris/infrastructure/reads"
 	"github.com/exlibris-fed/exlibris/infrastructure/registrationkeys"
+	"github.com/exlibris-fed/exlibris/infrastructure/replies"
 	"github.com/exlibris-fed/exlibris/infrastructure/reviews"
+	"github.com/exlibris-fed/exlibris/infrastructure/tombstones"
 	"github.com/exlibris-fed/exlibris/infrastructure/users"
 	"github.com/exlibris-fed/exlibris/service"
 
@@ -29,15 +36,21 @@ type Handler struct {
 	authorsRepo          *authors.Repository
 	usersRepo            *users.Repository
 	readsRepo            *reads.Repository
+	followersRepo        *followers.Repository
+	followingRepo        *following.Repository
+	blocksRepo           *blocks.Repository
+	domainBlocksRepo     *domainblocks.Repository
 	registrationKeysRepo *registrationkeys.Repository
+	tombstonesRepo       *tombstones.Repository
+	reactionsRepo        *reactions.Repository
+	repliesRepo          *replies.Repository
 }
 
 // New creates a new Handler to be used in processing http requests.
-func New(db *gorm.DB, cfg *config.Config) *Handler {
-	ap := activitypub.New(db, cfg)
+func New(db *gorm.DB, cfg *config.Config, ap *activitypub.ActivityPub) *Handler {
 	return &Handler{
 		cfg:                  cfg,
-		ap:                   activitypub.New(db, cfg),
+		ap:                   ap,
 		actor:                ap.NewFederatingActor(),
 		streamHandler:        ap.NewStreamsHandler(),
 		bookService:          service.NewBook(db),
@@ -48,6 +61,13 @@ func New(db *gorm.DB, cfg *config.Config) *Handler {
 		authorsRepo:          authors.New(db),
 		usersRepo:            users.New(db),
 		readsRepo:            reads.New(db),
+		followersRepo:        followers.New(db),
+		followingRepo:        following.New(db),
+		blocksRepo:           blocks.New(db),
+		domainBlocksRepo:     domainblocks.New(db),
 		registrationKeysRepo: registrationkeys.New(db),
+		tombstonesRepo:       tombstones.New(db),
+		reactionsRepo:        reactions.New(db),
+		repliesRepo:          replies.New(db),
 	}
 }
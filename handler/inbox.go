@@ -3,6 +3,8 @@ package handler
 import (
 	"log"
 	"net/http"
+
+	"github.com/exlibris-fed/exlibris/activitypub"
 )
 
 // HandleInbox is the http handler for an ActivityPub user's inbox.
@@ -11,14 +13,20 @@ func (h *Handler) HandleInbox(w http.ResponseWriter, r *http.Request) {
 
 	c := r.Context()
 
-	if handled, err := h.actor.PostInbox(c, w, r); err != nil {
+	if handled, err := h.ap.PostInbox(c, w, r); err != nil {
 		log.Printf("error handling PostInbox: %s", err)
 		w.WriteHeader(http.StatusInternalServerError) // TODO
 		return
 	} else if handled {
 		log.Println("handled PostInbox")
 		return
-	} else if handled, err = h.actor.GetInbox(c, w, r); err != nil {
+	} else if handled, err = h.ap.GetInboxCollection(c, w, r); err != nil {
+		log.Printf("error handling GetInboxCollection: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if handled {
+		return
+	} else if handled, err = activitypub.WithVocabulary(h.actor.GetInbox)(c, w, r); err != nil {
 		log.Printf("error handling GetInbox: %s", err)
 		w.WriteHeader(http.StatusInternalServerError) // TODO
 		// Write to w
@@ -30,3 +38,14 @@ func (h *Handler) HandleInbox(w http.ResponseWriter, r *http.Request) {
 	log.Println("else...?")
 	w.WriteHeader(http.StatusInternalServerError)
 }
+
+// HandleSharedInbox is the http handler for the shared inbox, which remote servers deliver to once for all the local users an activity is addressed to.
+func (h *Handler) HandleSharedInbox(w http.ResponseWriter, r *http.Request) {
+	if handled, err := h.ap.PostSharedInbox(r.Context(), w, r); err != nil {
+		log.Printf("error handling shared inbox delivery: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if !handled {
+		http.Error(w, "Non-ActivityPub request", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/exlibris-fed/exlibris/activitypub/resolver"
+	"github.com/exlibris-fed/exlibris/dto"
+	"github.com/exlibris-fed/exlibris/infrastructure/following"
+	"github.com/exlibris-fed/exlibris/infrastructure/users"
+	"github.com/exlibris-fed/exlibris/model"
+)
+
+// LookupUser finds a user by their handle, such as @alice@books.example, so that they can be followed. Users on other servers are found through Webfinger.
+func (h *Handler) LookupUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, ok := r.Context().Value(model.ContextKeyAuthenticatedUser).(*model.User)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	handle := r.URL.Query().Get("handle")
+	username, host, err := resolver.ParseHandle(handle)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var profile dto.Profile
+	if strings.EqualFold(host, h.cfg.Domain) {
+		local, err := h.usersRepo.GetByUsername(username)
+		if errors.Is(err, users.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("error looking up %s: %s", handle, err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		profile = dto.Profile{
+			Actor:                     local.IRI().String(),
+			Username:                  local.Username,
+			Name:                      local.DisplayName,
+			Summary:                   local.Summary,
+			URL:                       fmt.Sprintf("%s://%s/@%s", h.cfg.Scheme, h.cfg.Domain, local.Username),
+			ManuallyApprovesFollowers: local.ManuallyApprovesFollowers,
+		}
+	} else {
+		actor, err := h.ap.Lookup(r.Context(), handle)
+		if err != nil {
+			log.Printf("error looking up %s: %s", handle, err.Error())
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if profile, err = remoteProfile(actor); err != nil {
+			log.Printf("error reading the actor of %s: %s", handle, err.Error())
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+	}
+	profile.Handle = fmt.Sprintf("%s@%s", username, host)
+
+	f, err := h.followingRepo.Get(user, profile.Actor)
+	if err != nil && !errors.Is(err, following.ErrNotFound) {
+		log.Printf("error checking whether %s follows %s: %s", user.Username, profile.Actor, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if f != nil {
+		profile.Following = f.Accepted
+		profile.Pending = !f.Accepted
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// remoteProfile reads the profile of a user on another server out of their cached actor.
+func remoteProfile(actor *model.RemoteActor) (dto.Profile, error) {
+	var document struct {
+		PreferredUsername         string          `json:"preferredUsername"`
+		Name                      string          `json:"name"`
+		Summary                   string          `json:"summary"`
+		URL                       json.RawMessage `json:"url"`
+		Icon                      json.RawMessage `json:"icon"`
+		ManuallyApprovesFollowers bool            `json:"manuallyApprovesFollowers"`
+	}
+	if err := json.Unmarshal([]byte(actor.Document), &document); err != nil {
+		return dto.Profile{}, err
+	}
+	return dto.Profile{
+		Actor:                     actor.ID,
+		Username:                  document.PreferredUsername,
+		Name:                      document.Name,
+		Summary:                   document.Summary,
+		URL:                       linkHref(document.URL),
+		Icon:                      linkHref(document.Icon),
+		ManuallyApprovesFollowers: document.ManuallyApprovesFollowers,
+	}, nil
+}
+
+// linkHref returns the url of an ActivityStreams property that may be a plain url, a Link or Image, or a list of them, in which case the first is used.
+func linkHref(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var object struct {
+		URL  json.RawMessage `json:"url"`
+		Href string          `json:"href"`
+	}
+	if err := json.Unmarshal(raw, &object); err == nil {
+		if object.Href != "" {
+			return object.Href
+		}
+		return linkHref(object.URL)
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err == nil && len(list) > 0 {
+		return linkHref(list[0])
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/exlibris-fed/exlibris/activitypub"
+	"github.com/exlibris-fed/exlibris/dto"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// NodeInfoSchema is the rel of NodeInfo discovery links, which is followed by the schema version.
+	NodeInfoSchema = "http://nodeinfo.diaspora.software/ns/schema/"
+
+	// NodeInfoRepository is where the source code for exlibris lives.
+	NodeInfoRepository = "https://github.com/exlibris-fed/exlibris"
+)
+
+// nodeInfoVersions are the NodeInfo schema versions served, oldest first.
+var nodeInfoVersions = []string{"2.0", "2.1"}
+
+// HandleNodeInfoDiscovery lists the NodeInfo documents this server provides (http://nodeinfo.diaspora.software/protocol), so that crawlers can find out what software it runs.
+func (h *Handler) HandleNodeInfoDiscovery(w http.ResponseWriter, r *http.Request) {
+	response := dto.NodeInfoLinks{}
+	for _, version := range nodeInfoVersions {
+		response.Links = append(response.Links, dto.WebfingerLink{
+			Rel:  NodeInfoSchema + version,
+			Href: fmt.Sprintf("%s://%s/nodeinfo/%s", h.cfg.Scheme, h.cfg.Domain, version),
+		})
+	}
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("error marshalling json for nodeinfo discovery: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// HandleNodeInfo serves the NodeInfo document for the schema version in the url, reporting what software this server runs and how much it's used.
+func (h *Handler) HandleNodeInfo(w http.ResponseWriter, r *http.Request) {
+	version := mux.Vars(r)["version"]
+
+	now := time.Now()
+	total, err := h.usersRepo.CountLocal()
+	if err != nil {
+		log.Printf("error counting users for nodeinfo: %s", err.Error())
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	activeMonth, err := h.usersRepo.CountActiveLocal(now.AddDate(0, -1, 0))
+	if err != nil {
+		log.Printf("error counting monthly active users for nodeinfo: %s", err.Error())
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	activeHalfyear, err := h.usersRepo.CountActiveLocal(now.AddDate(0, -6, 0))
+	if err != nil {
+		log.Printf("error counting half-yearly active users for nodeinfo: %s", err.Error())
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	readCount, err := h.readsRepo.CountLocal()
+	if err != nil {
+		log.Printf("error counting reads for nodeinfo: %s", err.Error())
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	reviewCount, err := h.reviewsRepo.CountLocal()
+	if err != nil {
+		log.Printf("error counting reviews for nodeinfo: %s", err.Error())
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	response := dto.NodeInfo{
+		Version: version,
+		Software: dto.NodeInfoSoftware{
+			Name:    activitypub.Software,
+			Version: activitypub.Version,
+		},
+		Protocols: []string{"activitypub"},
+		Services: dto.NodeInfoServices{
+			Inbound:  []string{},
+			Outbound: []string{},
+		},
+		OpenRegistrations: true,
+		Usage: dto.NodeInfoUsage{
+			Users: dto.NodeInfoUsers{
+				Total:          total,
+				ActiveHalfyear: activeHalfyear,
+				ActiveMonth:    activeMonth,
+			},
+			// reads and reviews are what exlibris federates in place of statuses
+			LocalPosts: readCount + reviewCount,
+		},
+		Metadata: map[string]interface{}{
+			"nodeName": h.cfg.Domain,
+		},
+	}
+	if version != "2.0" {
+		response.Software.Repository = NodeInfoRepository
+		response.Software.Homepage = NodeInfoRepository
+	}
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("error marshalling json for nodeinfo: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", fmt.Sprintf(`application/json; profile="%s%s#"`, NodeInfoSchema, version))
+	w.Write(b)
+}
@@ -3,6 +3,8 @@ package handler
 import (
 	"log"
 	"net/http"
+
+	"github.com/exlibris-fed/exlibris/activitypub"
 )
 
 func (h *Handler) HandleOutbox(w http.ResponseWriter, r *http.Request) {
@@ -19,7 +21,13 @@ func (h *Handler) HandleOutbox(w http.ResponseWriter, r *http.Request) {
 	} else if handled {
 		log.Println("handled post outbox")
 		return
-	} else if handled, err = actor.GetOutbox(c, w, r); err != nil {
+	} else if handled, err = h.ap.GetOutboxCollection(c, w, r); err != nil {
+		log.Println("error getting outbox collection:", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if handled {
+		return
+	} else if handled, err = activitypub.WithVocabulary(actor.GetOutbox)(c, w, r); err != nil {
 		// Write to w
 		log.Println("error getting outbox:", err.Error())
 		return
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/exlibris-fed/exlibris/activitypub"
+	"github.com/exlibris-fed/exlibris/dto"
+	"github.com/exlibris-fed/exlibris/infrastructure/reactions"
+	"github.com/exlibris-fed/exlibris/model"
+)
+
+// Like likes a read or review as the authenticated user.
+func (h *Handler) Like(w http.ResponseWriter, r *http.Request) {
+	h.react(w, r, h.ap.Like)
+}
+
+// Unlike withdraws the authenticated user's like of a read or review.
+func (h *Handler) Unlike(w http.ResponseWriter, r *http.Request) {
+	h.unreact(w, r, h.ap.Unlike)
+}
+
+// Announce shares a read or review with the authenticated user's followers.
+func (h *Handler) Announce(w http.ResponseWriter, r *http.Request) {
+	h.react(w, r, h.ap.Announce)
+}
+
+// Unannounce withdraws the authenticated user's announce of a read or review.
+func (h *Handler) Unannounce(w http.ResponseWriter, r *http.Request) {
+	h.unreact(w, r, h.ap.Unannounce)
+}
+
+func (h *Handler) react(w http.ResponseWriter, r *http.Request, react func(*model.User, *url.URL) (*model.Reaction, error)) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, object, ok := objectRequest(w, r)
+	if !ok {
+		return
+	}
+
+	reaction, err := react(user, object)
+	if errors.Is(err, activitypub.ErrNotReactable) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("error reacting to %s as %s: %s", object, user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, dto.Reaction{
+		Object:    reaction.ObjectIRI,
+		Timestamp: reaction.CreatedAt,
+	})
+}
+
+func (h *Handler) unreact(w http.ResponseWriter, r *http.Request, unreact func(*model.User, *url.URL) error) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, object, ok := objectRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := unreact(user, object); errors.Is(err, reactions.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("error undoing reaction to %s as %s: %s", object, user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// objectRequest reads the authenticated user and the read or review a request is about. If it returns false a response has already been written.
+func objectRequest(w http.ResponseWriter, r *http.Request) (*model.User, *url.URL, bool) {
+	user, ok := r.Context().Value(model.ContextKeyAuthenticatedUser).(*model.User)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	var request dto.ObjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, nil, false
+	}
+	object, err := url.Parse(request.Object)
+	if err != nil || (object.Scheme != "https" && object.Scheme != "http") || object.Host == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, nil, false
+	}
+	return user, object, true
+}
+
+// reactionCounts returns how many times each of the reads or reviews with the given IRIs has been liked and announced. Errors are logged, and leave the counts at zero.
+func (h *Handler) reactionCounts(iris []string) (likes, announces map[string]int) {
+	likes, err := h.reactionsRepo.Counts(model.ReactionLike, iris)
+	if err != nil {
+		log.Printf("error counting likes: %s", err.Error())
+		likes = map[string]int{}
+	}
+	announces, err = h.reactionsRepo.Counts(model.ReactionAnnounce, iris)
+	if err != nil {
+		log.Printf("error counting announces: %s", err.Error())
+		announces = map[string]int{}
+	}
+	return
+}
@@ -2,13 +2,17 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"path"
 	"strings"
 	"time"
 
 	"github.com/exlibris-fed/exlibris/dto"
+	"github.com/exlibris-fed/exlibris/infrastructure/reads"
 	"github.com/exlibris-fed/exlibris/model"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -36,6 +40,12 @@ func (h *Handler) GetReads(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var iris []string
+	for _, read := range reads {
+		iris = append(iris, read.ID)
+	}
+	likes, announces := h.reactionCounts(iris)
+
 	for _, read := range reads {
 		bookDTO := dto.Read{
 			Book: dto.Book{
@@ -44,7 +54,12 @@ func (h *Handler) GetReads(w http.ResponseWriter, r *http.Request) {
 				Published:   time.Unix(int64(read.Book.Published), 0),
 				Description: read.Book.Description,
 			},
-			Timestamp: read.CreatedAt,
+			ReadID:        path.Base(read.ID),
+			IRI:           read.ID,
+			Timestamp:     read.CreatedAt,
+			FollowersOnly: read.FollowersOnly,
+			Likes:         likes[read.ID],
+			Announces:     announces[read.ID],
 		}
 		for _, author := range read.Book.Authors {
 			bookDTO.Authors = append(bookDTO.Authors, author.Name)
@@ -81,6 +96,12 @@ func (h *Handler) Read(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var request dto.ReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
 	book, err := h.bookService.Get(id)
 
 	if err != nil {
@@ -89,23 +110,61 @@ func (h *Handler) Read(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bookID := fmt.Sprintf("%s://%s/user/%s/%s", h.cfg.Scheme, h.cfg.Domain, strings.ToLower(user.Username), uuid.New())
+	bookID := fmt.Sprintf("%s://%s/user/%s/read/%s", h.cfg.Scheme, h.cfg.Domain, strings.ToLower(user.Username), uuid.New())
 	read := model.Read{
-		ID:     bookID,
-		User:   *user,
-		Book:   *book,
-		BookID: book.OpenLibraryID,
+		ID:            bookID,
+		User:          *user,
+		Book:          *book,
+		BookID:        book.OpenLibraryID,
+		FollowersOnly: request.FollowersOnly,
 	}
 
 	// TODO: we're going to want to actually create as part of the AP flow. That's nearly ready but I'd like to discuss how much to grab here vs there (I think either is fine, because we can populate the data here and when it checks if we have the book/author/subjects/etc in activitypub/database's Create we don't fetch them)
-	h.readsRepo.Create(&read)
+	if _, err := h.readsRepo.Create(&read); err != nil {
+		log.Printf("error saving read for %s: %s", user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
-	/*
-		if _, err := h.actor.Send(c, user.OutboxIRI(), read.ToType()); err != nil {
-			log.Printf("error sending to outbox for read %s: %s", read.ID, err.Error())
-		}
-	*/
+	h.ap.Send(user, read.ToType())
 
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 }
+
+// DeleteRead removes one of the authenticated user's reads.
+func (h *Handler) DeleteRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, ok := r.Context().Value(model.ContextKeyAuthenticatedUser).(*model.User)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	read, err := h.readsRepo.GetByID(fmt.Sprintf("%s://%s/user/%s/read/%s", h.cfg.Scheme, h.cfg.Domain, strings.ToLower(user.Username), id))
+	if errors.Is(err, reads.ErrNotFound) || (err == nil && read.UserID != user.ID) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("error getting read %s of %s: %s", id, user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.ap.DeleteRead(read); err != nil {
+		log.Printf("error deleting read %s of %s: %s", id, user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/exlibris-fed/exlibris/dto"
+	"github.com/exlibris-fed/exlibris/infrastructure/replies"
+	reviewsinfra "github.com/exlibris-fed/exlibris/infrastructure/reviews"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Reply adds the authenticated user's reply to a review, or to another reply under it.
+func (h *Handler) Reply(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, ok := r.Context().Value(model.ContextKeyAuthenticatedUser).(*model.User)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var replyData dto.ReplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&replyData); err != nil || strings.TrimSpace(replyData.Text) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	review, ok := h.bookReview(w, r, user)
+	if !ok {
+		return
+	}
+
+	var parent *model.Reply
+	if replyData.InReplyTo != "" {
+		parentID, err := uuid.Parse(replyData.InReplyTo)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		parent, err = h.repliesRepo.GetByID(parentID)
+		if errors.Is(err, replies.ErrNotFound) || (err == nil && parent.ReviewID != review.ID) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("error getting reply %s: %s", parentID, err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	reply, err := h.ap.Reply(user, review, parent, replyData.Text)
+	if err != nil {
+		log.Printf("error replying to review %s as %s: %s", review.ID, user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, replyResponse(reply))
+}
+
+// DeleteReply removes one of the authenticated user's replies. Replies to it stay, but are no longer part of the thread.
+func (h *Handler) DeleteReply(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, ok := r.Context().Value(model.ContextKeyAuthenticatedUser).(*model.User)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	reviewID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	replyID, err := uuid.Parse(vars["reply"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	reply, err := h.repliesRepo.GetByID(replyID)
+	if errors.Is(err, replies.ErrNotFound) || (err == nil && (reply.UserID != user.ID || reply.ReviewID != reviewID)) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("error getting reply %s: %s", replyID, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.ap.DeleteReply(reply); err != nil {
+		log.Printf("error deleting reply %s: %s", reply.ID, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bookReview returns the review of a book a request is about, which the user must be able to see. If it returns false a response has already been written.
+func (h *Handler) bookReview(w http.ResponseWriter, r *http.Request, user *model.User) (*model.Review, bool) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	review, err := h.reviewsRepo.GetByID(id)
+	if errors.Is(err, reviewsinfra.ErrNotFound) || (err == nil && review.BookID != "/works/"+vars["book"]) {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		log.Printf("error getting review %s: %s", id, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(h.visibleReviews(user, []model.Review{*review})) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	return review, true
+}
+
+// replyThreads fetches the replies under the given reviews and returns a function that arranges the ones under a review, or under a reply, into a tree. Replies by authors the user doesn't want to see are left out, along with everything under them.
+func (h *Handler) replyThreads(user *model.User, reviewIDs []uuid.UUID) func(iri string) []dto.Reply {
+	list, err := h.repliesRepo.ListByReviews(reviewIDs)
+	if err != nil {
+		log.Printf("error getting replies: %s", err.Error())
+	}
+
+	children := make(map[string][]model.Reply)
+	for _, reply := range list {
+		if hidden, err := h.ap.Hides(user, reply.User.IRI()); err != nil {
+			log.Printf("error checking whether %s can see reply %s: %s", viewer(user), reply.ID, err.Error())
+			continue
+		} else if hidden {
+			continue
+		}
+		children[reply.InReplyTo] = append(children[reply.InReplyTo], reply)
+	}
+
+	var thread func(iri string) []dto.Reply
+	thread = func(iri string) []dto.Reply {
+		response := []dto.Reply{}
+		for i := range children[iri] {
+			reply := replyResponse(&children[iri][i])
+			reply.Replies = thread(reply.IRI)
+			response = append(response, reply)
+		}
+		return response
+	}
+	return thread
+}
+
+// replyResponse returns the API representation of a reply, without anything under it.
+func replyResponse(reply *model.Reply) dto.Reply {
+	return dto.Reply{
+		ID:        reply.ID.String(),
+		IRI:       reply.IRI().String(),
+		Author:    reply.User.DisplayName,
+		Text:      reply.Text,
+		Timestamp: reply.CreatedAt,
+		Replies:   []dto.Reply{},
+	}
+}
@@ -9,11 +9,14 @@ import (
 	"github.com/exlibris-fed/exlibris/dto"
 	reviewsinfra "github.com/exlibris-fed/exlibris/infrastructure/reviews"
 	"github.com/exlibris-fed/exlibris/model"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
 type ReviewRequest struct {
-	Review string `json:"review"`
+	Review  string `json:"review"`
+	Spoiler string `json:"spoiler"`
+	Rating  int    `json:"rating"`
 }
 
 func (h *Handler) Review(w http.ResponseWriter, r *http.Request) {
@@ -46,13 +49,14 @@ func (h *Handler) Review(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			log.Println(err)
 		}
+		reviews = h.visibleReviews(user, reviews)
 	} else if r.Method == http.MethodPost {
 		// Create review of book
 
 		decoder := json.NewDecoder(r.Body)
 		var reviewData ReviewRequest
 		err = decoder.Decode(&reviewData)
-		if err != nil {
+		if err != nil || reviewData.Rating < 0 || reviewData.Rating > model.MaxRating {
 			// error with request
 			log.Println("Could not read review")
 			w.WriteHeader(http.StatusBadRequest)
@@ -60,13 +64,16 @@ func (h *Handler) Review(w http.ResponseWriter, r *http.Request) {
 		}
 
 		var review *model.Review
-		review, err = h.reviewsRepo.CreateReview(user, book, reviewData.Review, 0)
+		review, err = h.reviewsRepo.CreateReview(user, book, reviewData.Review, reviewData.Spoiler, reviewData.Rating)
 		if errors.Is(err, reviewsinfra.ErrNotFound) {
 			// Trying to create a review about a book no one has viewed or read
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
-		reviews = []model.Review{*review}
+		if err == nil {
+			reviews = []model.Review{*review}
+			h.ap.Send(user, review.CreateToType())
+		}
 	} else {
 		log.Println("Bad request")
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -79,11 +86,28 @@ func (h *Handler) Review(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var iris []string
+	var ids []uuid.UUID
 	for _, review := range reviews {
+		iris = append(iris, review.IRI().String())
+		ids = append(ids, review.ID)
+	}
+	likes, announces := h.reactionCounts(iris)
+	threads := h.replyThreads(user, ids)
+
+	for _, review := range reviews {
+		iri := review.IRI().String()
 		response = append(response, dto.Review{
+			ID:        review.ID.String(),
+			IRI:       iri,
 			Author:    review.User.DisplayName,
 			Text:      review.Text,
+			Spoiler:   review.Spoiler,
+			Rating:    review.Rating,
 			Timestamp: review.CreatedAt,
+			Likes:     likes[iri],
+			Announces: announces[iri],
+			Replies:   threads(iri),
 		})
 	}
 
@@ -97,3 +121,112 @@ func (h *Handler) Review(w http.ResponseWriter, r *http.Request) {
 	w.Write(b)
 
 }
+
+// visibleReviews leaves out reviews by authors the user has blocked, or whose servers are blocked.
+func (h *Handler) visibleReviews(user *model.User, reviews []model.Review) (visible []model.Review) {
+	for _, review := range reviews {
+		if hidden, err := h.ap.Hides(user, review.User.IRI()); err != nil {
+			log.Printf("error checking whether %s can see review %s: %s", viewer(user), review.ID, err.Error())
+		} else if hidden {
+			continue
+		}
+		visible = append(visible, review)
+	}
+	return
+}
+
+// viewer describes who is viewing something in logs. They may not be logged in.
+func viewer(user *model.User) string {
+	if user == nil {
+		return "an anonymous viewer"
+	}
+	return user.IRI().String()
+}
+
+// EditReview replaces the text, spoiler and rating of one of the authenticated user's reviews.
+func (h *Handler) EditReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	review, ok := h.ownReview(w, r)
+	if !ok {
+		return
+	}
+
+	var reviewData ReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&reviewData); err != nil || reviewData.Rating < 0 || reviewData.Rating > model.MaxRating {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	review.Text = reviewData.Review
+	review.Spoiler = reviewData.Spoiler
+	review.Rating = reviewData.Rating
+
+	if err := h.ap.UpdateReview(review); err != nil {
+		log.Printf("error updating review %s: %s", review.ID, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	iri := review.IRI().String()
+	likes, announces := h.reactionCounts([]string{iri})
+	writeJSON(w, http.StatusOK, dto.Review{
+		ID:        review.ID.String(),
+		IRI:       iri,
+		Author:    review.User.DisplayName,
+		Text:      review.Text,
+		Spoiler:   review.Spoiler,
+		Rating:    review.Rating,
+		Timestamp: review.CreatedAt,
+		Likes:     likes[iri],
+		Announces: announces[iri],
+	})
+}
+
+// DeleteReview removes one of the authenticated user's reviews.
+func (h *Handler) DeleteReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	review, ok := h.ownReview(w, r)
+	if !ok {
+		return
+	}
+	if err := h.ap.DeleteReview(review); err != nil {
+		log.Printf("error deleting review %s: %s", review.ID, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownReview returns the review of a book a request is about, which must have been written by the authenticated user. If it returns false a response has already been written.
+func (h *Handler) ownReview(w http.ResponseWriter, r *http.Request) (*model.Review, bool) {
+	user, ok := r.Context().Value(model.ContextKeyAuthenticatedUser).(*model.User)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, false
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	review, err := h.reviewsRepo.GetByID(id)
+	if errors.Is(err, reviewsinfra.ErrNotFound) || (err == nil && (review.UserID != user.ID || review.BookID != "/works/"+vars["book"])) {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		log.Printf("error getting review %s: %s", id, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return review, true
+}
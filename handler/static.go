@@ -29,7 +29,7 @@ func (h *Handler) HandleStaticFile(w http.ResponseWriter, r *http.Request) {
 	_, err = os.Stat(path)
 	if os.IsNotExist(err) {
 		// file does not exist, serve index.html
-		http.ServeFile(w, r, filepath.Join(staticPath, "/"))
+		serveFrontEnd(w, r)
 		return
 	} else if err != nil {
 		// if we got an error (that wasn't that the file doesn't exist) stating the
@@ -42,3 +42,8 @@ func (h *Handler) HandleStaticFile(w http.ResponseWriter, r *http.Request) {
 	// otherwise, use http.FileServer to serve the static dir
 	http.FileServer(http.Dir(staticPath)).ServeHTTP(w, r)
 }
+
+// serveFrontEnd serves the front end's index page, which renders whatever page of the app the url is for.
+func serveFrontEnd(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, filepath.Join(staticPath, "/"))
+}
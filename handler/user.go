@@ -6,14 +6,32 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
+	"github.com/exlibris-fed/exlibris/activitypub"
 	"github.com/exlibris-fed/exlibris/dto"
+	"github.com/exlibris-fed/exlibris/infrastructure/tombstones"
 	"github.com/exlibris-fed/exlibris/infrastructure/users"
-	"github.com/exlibris-fed/exlibris/key"
+	"github.com/exlibris-fed/exlibris/model"
 
+	"github.com/go-fed/activity/streams"
 	"github.com/gorilla/mux"
 )
 
+// HandleProfile serves a user's profile at /@username or /user/username, deciding by the Accept header whether to send their actor or the front end's profile page. Browsers asking for /user/username are sent to /@username, which is the url given in the actor, while ActivityPub clients get the same actor from either.
+func (h *Handler) HandleProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	if !activitypub.PrefersHTML(r) {
+		h.HandleActivityPubProfile(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/user/") {
+		http.Redirect(w, r, "/@"+mux.Vars(r)["username"], http.StatusSeeOther)
+		return
+	}
+	serveFrontEnd(w, r)
+}
+
 // HandleActivityPubProfile returns a user's profile when requested with the AP content type.
 func (h *Handler) HandleActivityPubProfile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -25,7 +43,7 @@ func (h *Handler) HandleActivityPubProfile(w http.ResponseWriter, r *http.Reques
 
 	user, err := h.usersRepo.GetByUsername(username)
 	if err != nil && errors.Is(err, users.ErrNotFound) {
-		w.WriteHeader(http.StatusNotFound)
+		h.writeTombstone(w, fmt.Sprintf("%s://%s/user/%s", h.cfg.Scheme, h.cfg.Domain, strings.ToLower(username)))
 		return
 	} else if err != nil && errors.Is(err, users.ErrStorage) {
 		log.Printf("error retrieving user %s: %s", username, err.Error())
@@ -33,21 +51,28 @@ func (h *Handler) HandleActivityPubProfile(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	profile := fmt.Sprintf("%s://%s/user/%s", h.cfg.Scheme, h.cfg.Domain, user.Username)
+	profile := user.IRI().String()
 	response := dto.NewActivityPubUser()
 	response.ID = profile
 	response.Following = profile + "/following"
 	response.Followers = profile + "/followers"
 	response.Inbox = profile + "/inbox"
 	response.Outbox = profile + "/outbox"
+	response.Liked = profile + "/liked"
 	response.Username = user.Username
 	response.Name = user.DisplayName
 	response.URL = fmt.Sprintf("%s://%s/@%s", h.cfg.Scheme, h.cfg.Domain, user.Username)
-	response.Endpoints["sharedInbox"] = fmt.Sprintf("%s/inbox", h.cfg.Domain)
+	response.ManuallyApprovesFollowers = user.ManuallyApprovesFollowers
+	response.Endpoints["sharedInbox"] = fmt.Sprintf("%s://%s/inbox", h.cfg.Scheme, h.cfg.Domain)
+	if user.Instance {
+		// the server has no profile page of its own, so its front page stands in
+		response.Type = dto.TypeApplication
+		response.URL = fmt.Sprintf("%s://%s/", h.cfg.Scheme, h.cfg.Domain)
+	}
 
-	if publicKey, err := marshalPublicKey(user.PrivateKey); err == nil {
+	if publicKey, err := user.PublicKeyPEM(); err == nil {
 		response.PublicKey = dto.PublicKey{
-			ID:    profile + "#main-key",
+			ID:    user.PublicKeyID(),
 			Owner: profile,
 			PEM:   publicKey,
 		}
@@ -66,10 +91,118 @@ func (h *Handler) HandleActivityPubProfile(w http.ResponseWriter, r *http.Reques
 	w.Write(b)
 }
 
-func marshalPublicKey(b []byte) (string, error) {
-	privateKey, err := key.DeserializeRSAPrivateKey(b)
+// HandlePublicKey returns a user's current public key as a document of its own, with the same id requests are signed with. Other servers usually find it in the user's actor instead, under that id.
+func (h *Handler) HandlePublicKey(w http.ResponseWriter, r *http.Request) {
+	user, err := h.usersRepo.GetByUsername(mux.Vars(r)["username"])
+	if errors.Is(err, users.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("error retrieving user %s: %s", mux.Vars(r)["username"], err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	pem, err := user.PublicKeyPEM()
+	if err != nil {
+		log.Printf("unable to marshal public key for user %s: %s", user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	b, err := json.Marshal(dto.PublicKeyDocument{
+		Context: []string{dto.ContextSecurity},
+		PublicKey: dto.PublicKey{
+			ID:    user.PublicKeyID(),
+			Owner: user.IRI().String(),
+			PEM:   pem,
+		},
+	})
+	if err != nil {
+		log.Printf("error marshalling json for key of %s: %s", user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/activity+json")
+	w.Write(b)
+}
+
+// writeTombstone responds that a deleted local object is gone, or that it was never found if it didn't exist.
+func (h *Handler) writeTombstone(w http.ResponseWriter, iri string) {
+	tombstone, err := h.tombstonesRepo.Get(iri)
+	if errors.Is(err, tombstones.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("error looking up tombstone of %s: %s", iri, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	m, err := streams.Serialize(tombstone.ToType())
+	if err != nil {
+		log.Printf("error serializing tombstone of %s: %s", iri, err.Error())
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		log.Printf("error marshalling json for tombstone of %s: %s", iri, err.Error())
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	w.Header().Add("Content-Type", "application/activity+json")
+	w.WriteHeader(http.StatusGone)
+	w.Write(b)
+}
+
+// RotateKey gives the authenticated user a new keypair, and tells other servers about it. The new public key is returned.
+func (h *Handler) RotateKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, ok := r.Context().Value(model.ContextKeyAuthenticatedUser).(*model.User)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if err := h.ap.RotateKey(user); err != nil {
+		log.Printf("error rotating key of %s: %s", user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	pem, err := user.PublicKeyPEM()
 	if err != nil {
-		return "", err
+		log.Printf("unable to marshal public key for user %s: %s", user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.PublicKey{
+		ID:    user.PublicKeyID(),
+		Owner: user.IRI().String(),
+		PEM:   pem,
+	})
+}
+
+// DeleteAccount deletes the authenticated user, along with everything they've read and reviewed, and tells other servers that they're gone. Their username can't be registered again.
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, ok := r.Context().Value(model.ContextKeyAuthenticatedUser).(*model.User)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if err := h.ap.DeleteAccount(user); err != nil {
+		log.Printf("error deleting account of %s: %s", user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
-	return key.MarshalPublicKeyFromPrivateKey(privateKey)
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -2,10 +2,12 @@ package handler
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/exlibris-fed/exlibris/dto"
@@ -21,38 +23,49 @@ const (
 
 	// RelSelf is the "self" rel link
 	RelSelf = "self"
+
+	// RelLRDD is the rel of the host-meta link template pointing at Webfinger, from RFC 6415.
+	RelLRDD = "lrdd"
+
+	// ContentTypeJRD is the media type of a JSON Resource Descriptor, which is what Webfinger responds with.
+	ContentTypeJRD = "application/jrd+json"
+
+	// ContentTypeXRD is the media type of an Extensible Resource Descriptor, the XML form host-meta is served in by default.
+	ContentTypeXRD = "application/xrd+xml"
+)
+
+var (
+	// errMalformedResource is returned when a Webfinger resource isn't a URI identifying a user.
+	errMalformedResource = errors.New("malformed webfinger resource")
+
+	// errForeignResource is returned when a Webfinger resource identifies something on another server.
+	errForeignResource = errors.New("webfinger resource is not on this server")
 )
 
 // HandleWebfinger handles Webfinger requests (https://tools.ietf.org/html/rfc7033)
-// to look up a user by their profile id. It is required for Mastodon interoperability.
-//
-// TODO: this provides basic functionality; I haven't actually looked at the RFC.
+// to look up a user by their account (RFC 7565) or by the url of their profile or actor. It is required for Mastodon interoperability.
 func (h *Handler) HandleWebfinger(w http.ResponseWriter, r *http.Request) {
-	resource := r.FormValue("resource")
-	if resource == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
+	// Webfinger is meant to be queried from browsers on other origins too.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	split := strings.SplitN(resource, ":", 2)
-	if len(split) != 2 || split[0] != AccountURIScheme {
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	username := strings.Split(split[1], "@")
-	if len(username) != 2 {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-	if username[1] != h.cfg.Domain {
-		w.WriteHeader(http.StatusNotFound)
+	username, err := h.webfingerUsername(resource)
+	if err != nil {
+		if errors.Is(err, errForeignResource) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
 		return
 	}
 
-	user, err := h.usersRepo.GetByUsername(username[0])
+	user, err := h.usersRepo.GetByUsername(username)
 	if err != nil {
-		// TODO may not be a 404
 		if errors.Is(err, users.ErrNotFound) {
 			w.WriteHeader(http.StatusNotFound)
 		} else {
@@ -60,33 +73,142 @@ func (h *Handler) HandleWebfinger(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if !user.Local {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
 
 	atProfile := fmt.Sprintf("%s://%s/@%s", h.cfg.Scheme, h.cfg.Domain, user.Username)
-	userProfile := fmt.Sprintf("%s://%s/user/%s", h.cfg.Scheme, h.cfg.Domain, user.Username)
+	if user.Instance {
+		atProfile = fmt.Sprintf("%s://%s/", h.cfg.Scheme, h.cfg.Domain)
+	}
+	userProfile := user.IRI().String()
+	links := []dto.WebfingerLink{
+		dto.WebfingerLink{
+			Rel:  RelWebfingerProfilePage,
+			Type: "text/html",
+			Href: atProfile,
+		},
+		dto.WebfingerLink{
+			Rel:  RelSelf,
+			Type: "application/activity+json",
+			Href: userProfile,
+		},
+	}
+
+	// Section 4.3: only the links with the requested rels are returned, if any were asked for.
+	if rels := r.URL.Query()["rel"]; len(rels) > 0 {
+		filtered := []dto.WebfingerLink{}
+		for _, link := range links {
+			for _, rel := range rels {
+				if link.Rel == rel {
+					filtered = append(filtered, link)
+					break
+				}
+			}
+		}
+		links = filtered
+	}
+
 	response := dto.Webfinger{
-		Subject: resource,
+		Subject: fmt.Sprintf("%s:%s@%s", AccountURIScheme, user.Username, h.cfg.Domain),
 		Aliases: []string{atProfile, userProfile},
+		Links:   links,
+	}
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("error marshalling json for webfinger user %s: %s", user.Username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", ContentTypeJRD)
+	w.Write(b)
+}
+
+// webfingerUsername returns the username of the local user a Webfinger resource refers to. The resource can be an acct: URI or the url of a user's profile page or actor.
+func (h *Handler) webfingerUsername(resource string) (string, error) {
+	u, err := url.Parse(resource)
+	if err != nil {
+		return "", errMalformedResource
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case AccountURIScheme:
+		// acct:user@host has no authority, so it's all in the opaque part. Its userpart may be percent-encoded.
+		account := u.Opaque
+		if account == "" {
+			return "", errMalformedResource
+		}
+		i := strings.LastIndex(account, "@")
+		if i <= 0 || i == len(account)-1 {
+			return "", errMalformedResource
+		}
+		if !strings.EqualFold(account[i+1:], h.cfg.Domain) {
+			return "", errForeignResource
+		}
+		username, err := url.PathUnescape(account[:i])
+		if err != nil {
+			return "", errMalformedResource
+		}
+		// Mastodon users are used to typing @user@host, and some clients send it on
+		return strings.TrimPrefix(username, "@"), nil
+
+	case "http", "https":
+		if !strings.EqualFold(u.Host, h.cfg.Domain) {
+			return "", errForeignResource
+		}
+		path := strings.TrimSuffix(u.Path, "/")
+		switch {
+		case strings.HasPrefix(path, "/@"):
+			path = strings.TrimPrefix(path, "/@")
+		case strings.HasPrefix(path, "/user/"):
+			path = strings.TrimPrefix(path, "/user/")
+		default:
+			return "", errMalformedResource
+		}
+		if path == "" || strings.Contains(path, "/") {
+			return "", errMalformedResource
+		}
+		return path, nil
+	}
+	return "", errMalformedResource
+}
+
+// HandleHostMeta serves host-meta (https://tools.ietf.org/html/rfc6415), which tells older software where to find Webfinger. It's XML unless JSON is asked for, either through the Accept header or by requesting host-meta.json.
+func (h *Handler) HandleHostMeta(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	response := dto.HostMeta{
 		Links: []dto.WebfingerLink{
 			dto.WebfingerLink{
-				Rel:  RelWebfingerProfilePage,
-				Type: "text/html",
-				Href: atProfile,
-			},
-			dto.WebfingerLink{
-				Rel:  RelSelf,
-				Type: "application/activity+json",
-				Href: userProfile,
+				Rel:      RelLRDD,
+				Type:     ContentTypeJRD,
+				Template: fmt.Sprintf("%s://%s/.well-known/webfinger?resource={uri}", h.cfg.Scheme, h.cfg.Domain),
 			},
 		},
 	}
 
-	b, err := json.Marshal(response)
+	if strings.HasSuffix(r.URL.Path, ".json") || strings.Contains(r.Header.Get("Accept"), "json") {
+		b, err := json.Marshal(response)
+		if err != nil {
+			log.Printf("error marshalling json for host-meta: %s", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Content-Type", ContentTypeJRD)
+		w.Write(b)
+		return
+	}
+
+	b, err := xml.MarshalIndent(response, "", "  ")
 	if err != nil {
-		log.Printf("error marshalling json for webfinger user %s: %s", user.Username, err.Error())
+		log.Printf("error marshalling xml for host-meta: %s", err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-
-	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("Content-Type", ContentTypeXRD)
+	w.Write([]byte(xml.Header))
 	w.Write(b)
 }
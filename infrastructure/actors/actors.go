@@ -0,0 +1,65 @@
+// Package actors contains the repository for the cached actor documents of remote actors.
+package actors
+
+import (
+	"errors"
+	"log"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrNotFound is returned when a record cannot be found.
+	ErrNotFound = errors.New("actor could not be found")
+	// ErrNotSaved is returned when a record cannot be saved.
+	ErrNotSaved = errors.New("actor could not be saved")
+	// ErrNotDeleted is returned when a record cannot be deleted.
+	ErrNotDeleted = errors.New("actor could not be deleted")
+	// ErrStorage is returned when an unknown storage issue occurs.
+	ErrStorage = errors.New("error with storage")
+)
+
+// New creates a new Repository instance for remote actors.
+func New(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Repository is used for querying and saving remote actors.
+type Repository struct {
+	db *gorm.DB
+}
+
+// Get returns a cached actor given their IRI.
+func (r *Repository) Get(id string) (*model.RemoteActor, error) {
+	var actor model.RemoteActor
+	if err := r.db.Where("id = ?", id).First(&actor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		log.Printf("error getting actor %s: %s", id, err.Error())
+		return nil, ErrStorage
+	}
+	return &actor, nil
+}
+
+// Save creates or replaces a cached actor.
+func (r *Repository) Save(actor *model.RemoteActor) error {
+	if err := r.db.Save(actor).Error; err != nil {
+		log.Printf("error saving actor %s: %s", actor.ID, err.Error())
+		return ErrNotSaved
+	}
+	return nil
+}
+
+// Delete removes a cached actor, such as one whose server says they're gone.
+func (r *Repository) Delete(id string) error {
+	if err := r.db.Unscoped().
+		Where("id = ?", id).
+		Delete(&model.RemoteActor{}).
+		Error; err != nil {
+		log.Printf("error deleting actor %s: %s", id, err.Error())
+		return ErrNotDeleted
+	}
+	return nil
+}
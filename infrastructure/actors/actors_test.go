@@ -0,0 +1,90 @@
+package actors
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+const aliceIRI = "https://mastodon.example/users/alice"
+
+func actorsRows() *sqlmock.Rows {
+	ts := time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	return sqlmock.NewRows([]string{"created_at", "updated_at", "deleted_at", "id", "inbox", "shared_inbox", "public_key_id", "document", "fetched_at"}).
+		AddRow(ts, ts, nil, aliceIRI, aliceIRI+"/inbox", "https://mastodon.example/inbox", aliceIRI+"#main-key", "{}", ts)
+}
+
+func TestGet(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"remote_actors\"  WHERE \"remote_actors\".\"deleted_at\" IS NULL AND ((id = $1)) ORDER BY \"remote_actors\".\"id\" ASC LIMIT 1") + "$").
+		WithArgs(aliceIRI).
+		WillReturnRows(actorsRows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	actor, err := repo.Get(aliceIRI)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, actor) {
+		assert.Equal(t, "https://mastodon.example/inbox", actor.SharedInbox)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_ErrNotFound(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"remote_actors\"")).
+		WithArgs(aliceIRI).
+		WillReturnError(gorm.ErrRecordNotFound)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	actor, err := repo.Get(aliceIRI)
+
+	assert.Nil(t, actor)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSave_ErrNotSaved(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("UPDATE \"remote_actors\" SET")).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Save(&model.RemoteActor{
+		ID:        aliceIRI,
+		Inbox:     aliceIRI + "/inbox",
+		Document:  "{}",
+		FetchedAt: time.Now(),
+	})
+
+	assert.True(t, errors.Is(err, ErrNotSaved))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"remote_actors\"  WHERE (id = $1)") + "$").
+		WithArgs(aliceIRI).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Delete(aliceIRI)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
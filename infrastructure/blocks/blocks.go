@@ -0,0 +1,85 @@
+// Package blocks contains the repository for the actors local users have blocked.
+package blocks
+
+import (
+	"errors"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrNotFound is returned when a record cannot be found.
+	ErrNotFound = errors.New("block could not be found")
+	// ErrNotSaved is returned when a record cannot be saved.
+	ErrNotSaved = errors.New("block could not be saved")
+	// ErrNotDeleted is returned when a record cannot be deleted.
+	ErrNotDeleted = errors.New("block could not be deleted")
+	// ErrStorage is returned when an unknown storage issue occurs.
+	ErrStorage = errors.New("error with storage")
+)
+
+// New creates a new Repository instance for blocks.
+func New(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Repository is used for querying and saving who users have blocked.
+type Repository struct {
+	db *gorm.DB
+}
+
+// Get returns a user's block of the actor with the given IRI.
+func (r *Repository) Get(user *model.User, actorIRI string) (*model.Block, error) {
+	var block model.Block
+	if err := r.db.Where("user_id = ? AND id = ?", user.ID, actorIRI).First(&block).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrStorage
+	}
+	block.User = *user
+	return &block, nil
+}
+
+// List returns who a user has blocked, newest first.
+func (r *Repository) List(user *model.User) ([]model.Block, error) {
+	var blocks []model.Block
+	if err := r.db.Where("user_id = ?", user.ID).
+		Order("created_at desc").
+		Find(&blocks).
+		Error; err != nil {
+		return nil, ErrStorage
+	}
+	return blocks, nil
+}
+
+// Blocks returns whether a user has blocked the actor with the given IRI.
+func (r *Repository) Blocks(user *model.User, actorIRI string) (bool, error) {
+	var count int
+	if err := r.db.Model(&model.Block{}).
+		Where("user_id = ? AND id = ?", user.ID, actorIRI).
+		Count(&count).
+		Error; err != nil {
+		return false, ErrStorage
+	}
+	return count > 0, nil
+}
+
+// Save creates or updates a block.
+func (r *Repository) Save(block *model.Block) error {
+	if err := r.db.Save(block).Error; err != nil {
+		return ErrNotSaved
+	}
+	return nil
+}
+
+// Delete removes a block.
+func (r *Repository) Delete(block *model.Block) error {
+	if err := r.db.Where("user_id = ? AND id = ?", block.UserID, block.ID).
+		Delete(&model.Block{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package blocks
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+var user = &model.User{
+	Base: model.Base{
+		ID: uuid.MustParse("b3032140-e824-4b39-9be2-47e99f383f2b"),
+	},
+	Username: "bob",
+}
+
+func rows() *sqlmock.Rows {
+	ts := time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	return sqlmock.NewRows([]string{"id", "user_id", "block_id", "created_at", "updated_at"}).
+		AddRow("https://mastodon.example/users/troll", user.ID, "https://exlibris.example/user/bob/block/1", ts, ts)
+}
+
+func TestGet(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"blocks\"  WHERE (user_id = $1 AND id = $2) ORDER BY \"blocks\".\"id\" ASC LIMIT 1")+"$").
+		WithArgs(user.ID, "https://mastodon.example/users/troll").
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	b, err := repo.Get(user, "https://mastodon.example/users/troll")
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, b) {
+		assert.Equal(t, "https://exlibris.example/user/bob/block/1", b.BlockID)
+		assert.Equal(t, "bob", b.User.Username)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_ErrNotFound(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"blocks\"")).
+		WillReturnError(gorm.ErrRecordNotFound)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	b, err := repo.Get(user, "https://mastodon.example/users/alice")
+
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestList(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"blocks\"  WHERE (user_id = $1) ORDER BY created_at desc") + "$").
+		WithArgs(user.ID).
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	list, err := repo.List(user)
+
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBlocks(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT count(*) FROM \"blocks\"  WHERE (user_id = $1 AND id = $2)")+"$").
+		WithArgs(user.ID, "https://mastodon.example/users/troll").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	blocks, err := repo.Blocks(user, "https://mastodon.example/users/troll")
+
+	assert.NoError(t, err)
+	assert.True(t, blocks)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBlocks_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT count(*) FROM \"blocks\"")).
+		WillReturnError(fmt.Errorf("oops"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	blocks, err := repo.Blocks(user, "https://mastodon.example/users/troll")
+
+	assert.False(t, blocks)
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("DELETE FROM \"blocks\"  WHERE (user_id = $1 AND id = $2)")+"$").
+		WithArgs(user.ID, "https://mastodon.example/users/troll").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Delete(&model.Block{
+		ID:     "https://mastodon.example/users/troll",
+		UserID: user.ID,
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -107,8 +107,8 @@ func TestCreate(t *testing.T) {
 		AddRow("/work/OL1234567W")
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"books\" (\"created_at\",\"updated_at\",\"deleted_at\",\"open_library_id\",\"title\",\"published\",\"isbn\",\"description\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING \"books\".\"open_library_id\"")+"$").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "/work/OL1234567W", "title", 123456789, "1234567890", "").
+	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"books\" (\"created_at\",\"updated_at\",\"deleted_at\",\"open_library_id\",\"title\",\"published\",\"isbn\",\"description\",\"isbn10\",\"edition_id\",\"wikidata_id\",\"pages\",\"subjects\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13) RETURNING \"books\".\"open_library_id\"")+"$").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "/work/OL1234567W", "title", 123456789, "1234567890", "", "", "", "", 0, nil).
 		WillReturnRows(bookSourceRows)
 	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"authors\" SET \"updated_at\" = $1, \"deleted_at\" = $2, \"name\" = $3  WHERE \"authors\".\"deleted_at\" IS NULL AND \"authors\".\"open_library_id\" = $4")+"$").
 		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "writer mcwriterface", "/author/OL1234567A").
@@ -155,8 +155,8 @@ func TestCreate_Error(t *testing.T) {
 	conn, mock, _ := sqlmock.New()
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"books\" (\"created_at\",\"updated_at\",\"deleted_at\",\"open_library_id\",\"title\",\"published\",\"isbn\",\"description\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING \"books\".\"open_library_id\"")+"$").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "/work/OL1234567W", "title", 123456789, "1234567890", "").
+	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"books\" (\"created_at\",\"updated_at\",\"deleted_at\",\"open_library_id\",\"title\",\"published\",\"isbn\",\"description\",\"isbn10\",\"edition_id\",\"wikidata_id\",\"pages\",\"subjects\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13) RETURNING \"books\".\"open_library_id\"")+"$").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "/work/OL1234567W", "title", 123456789, "1234567890", "", "", "", "", 0, nil).
 		WillReturnError(fmt.Errorf("could not update"))
 	mock.ExpectRollback()
 
@@ -0,0 +1,123 @@
+// Package deliveries contains the repository for queued outbound deliveries.
+package deliveries
+
+import (
+	"errors"
+	"time"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrNotCreated is returned when a record cannot be created.
+	ErrNotCreated = errors.New("delivery could not be queued")
+	// ErrNotSaved is returned when a record cannot be updated.
+	ErrNotSaved = errors.New("delivery could not be saved")
+	// ErrStorage is returned when an unknown storage issue occurs.
+	ErrStorage = errors.New("error with storage")
+)
+
+// New creates a new Repository instance for deliveries.
+func New(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Repository is used for queueing and claiming deliveries.
+type Repository struct {
+	db *gorm.DB
+}
+
+// Create queues new deliveries.
+func (r *Repository) Create(deliveries []*model.Delivery) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for _, d := range deliveries {
+			if err := tx.Create(d).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ErrNotCreated
+	}
+	return nil
+}
+
+// Claim returns up to limit pending deliveries that are due, along with the user they're sent on behalf of. Claimed deliveries are leased so that they won't be claimed again until the lease expires, which allows multiple workers (or servers) to share the queue.
+func (r *Repository) Claim(now time.Time, lease time.Duration, limit int) ([]*model.Delivery, error) {
+	var deliveries []*model.Delivery
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+			Where("status = ? AND next_attempt_at <= ?", model.DeliveryStatusPending, now).
+			Order("next_attempt_at asc").
+			Limit(limit).
+			Find(&deliveries).
+			Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]interface{}, len(deliveries))
+		for i, d := range deliveries {
+			ids[i] = d.ID
+		}
+		return tx.Model(&model.Delivery{}).
+			Where("id IN (?)", ids).
+			Update("next_attempt_at", now.Add(lease)).
+			Error
+	})
+	if err != nil {
+		return nil, ErrStorage
+	}
+
+	// the user may have deleted their account since, but their last activities must still be delivered
+	for _, d := range deliveries {
+		if err := r.db.Unscoped().Model(d).Related(&d.User).Error; err != nil {
+			return nil, ErrStorage
+		}
+	}
+	return deliveries, nil
+}
+
+// Delivered removes a delivery that succeeded.
+func (r *Repository) Delivered(d *model.Delivery) error {
+	if err := r.db.Unscoped().Delete(d).Error; err != nil {
+		return ErrNotSaved
+	}
+	return nil
+}
+
+// Retry records a failed attempt at a delivery and schedules the next one.
+func (r *Repository) Retry(d *model.Delivery, next time.Time, cause error) error {
+	if err := r.db.Model(d).Updates(map[string]interface{}{
+		"attempts":        d.Attempts,
+		"next_attempt_at": next,
+		"last_error":      cause.Error(),
+	}).Error; err != nil {
+		return ErrNotSaved
+	}
+	return nil
+}
+
+// Postpone reschedules a delivery without counting it as an attempt, such as when its host is known to be down.
+func (r *Repository) Postpone(d *model.Delivery, next time.Time) error {
+	if err := r.db.Model(d).Update("next_attempt_at", next).Error; err != nil {
+		return ErrNotSaved
+	}
+	return nil
+}
+
+// Dead moves a delivery to the dead letter queue. It will not be attempted again.
+func (r *Repository) Dead(d *model.Delivery, cause error) error {
+	if err := r.db.Model(d).Updates(map[string]interface{}{
+		"attempts":   d.Attempts,
+		"status":     model.DeliveryStatusDead,
+		"last_error": cause.Error(),
+	}).Error; err != nil {
+		return ErrNotSaved
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package deliveries
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	now        = time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	deliveryID = uuid.MustParse("8d3bb7a2-3f0c-4d1e-9f5e-2f6f1a0b9c11")
+	userID     = uuid.MustParse("46ef0f8e-3f3c-4a33-a1c5-0e0b7b6f4a10")
+)
+
+func delivery() *model.Delivery {
+	return &model.Delivery{
+		Base: model.Base{
+			ID: deliveryID,
+		},
+		ActivityID:    "https://exlibris.example/user/bob/read/c9f8e9ce-2e3a-4d7e-9a43-2d1e2f5f1b0a",
+		Inbox:         "https://mastodon.example/users/alice/inbox",
+		Host:          "mastodon.example",
+		Payload:       `{"type":"Read"}`,
+		UserID:        userID,
+		Status:        model.DeliveryStatusPending,
+		NextAttemptAt: now,
+	}
+}
+
+func TestCreate(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("INSERT INTO \"deliveries\"")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(deliveryID))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Create([]*model.Delivery{delivery()})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreate_ErrNotCreated(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("INSERT INTO \"deliveries\"")).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Create([]*model.Delivery{delivery()})
+
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrNotCreated))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaim(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"deliveries\" WHERE \"deliveries\".\"deleted_at\" IS NULL AND ((status = $1 AND next_attempt_at <= $2)) ORDER BY next_attempt_at asc LIMIT 4 FOR UPDATE SKIP LOCKED")+"$").
+		WithArgs(model.DeliveryStatusPending, now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "activity_id", "inbox", "host", "payload", "user_id", "status", "attempts", "next_attempt_at"}).
+			AddRow(deliveryID, "https://exlibris.example/user/bob/read/1", "https://mastodon.example/users/alice/inbox", "mastodon.example", "{}", userID, model.DeliveryStatusPending, 0, now))
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"deliveries\" SET \"next_attempt_at\" = $1, \"updated_at\" = $2 WHERE \"deliveries\".\"deleted_at\" IS NULL AND ((id IN ($3)))")+"$").
+		WithArgs(now.Add(time.Minute), sqlmock.AnyArg(), deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"users\"  WHERE (\"id\" = $1)")).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow(userID, "bob"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	ds, err := repo.Claim(now, time.Minute, 4)
+
+	assert.NoError(t, err)
+	if assert.Len(t, ds, 1) {
+		assert.Equal(t, "bob", ds[0].User.Username)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaim_Empty(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"deliveries\"")).
+		WithArgs(model.DeliveryStatusPending, now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	ds, err := repo.Claim(now, time.Minute, 4)
+
+	assert.NoError(t, err)
+	assert.Empty(t, ds)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaim_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"deliveries\"")).
+		WithArgs(model.DeliveryStatusPending, now).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	ds, err := repo.Claim(now, time.Minute, 4)
+
+	assert.Nil(t, ds)
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelivered(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"deliveries\" WHERE \"deliveries\".\"id\" = $1") + "$").
+		WithArgs(deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Delivered(delivery())
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetry(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"deliveries\" SET \"attempts\" = $1, \"last_error\" = $2, \"next_attempt_at\" = $3, \"updated_at\" = $4 WHERE \"deliveries\".\"deleted_at\" IS NULL AND \"deliveries\".\"id\" = $5")+"$").
+		WithArgs(1, "connection refused", now.Add(time.Minute), sqlmock.AnyArg(), deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	d := delivery()
+	d.Attempts = 1
+	err := repo.Retry(d, now.Add(time.Minute), fmt.Errorf("connection refused"))
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostpone(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"deliveries\" SET \"next_attempt_at\" = $1, \"updated_at\" = $2 WHERE \"deliveries\".\"deleted_at\" IS NULL AND \"deliveries\".\"id\" = $3")+"$").
+		WithArgs(now.Add(time.Hour), sqlmock.AnyArg(), deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Postpone(delivery(), now.Add(time.Hour))
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDead(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"deliveries\" SET \"attempts\" = $1, \"last_error\" = $2, \"status\" = $3, \"updated_at\" = $4 WHERE \"deliveries\".\"deleted_at\" IS NULL AND \"deliveries\".\"id\" = $5")+"$").
+		WithArgs(10, "gone", model.DeliveryStatusDead, sqlmock.AnyArg(), deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	d := delivery()
+	d.Attempts = 10
+	err := repo.Dead(d, fmt.Errorf("gone"))
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDead_ErrNotSaved(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("UPDATE \"deliveries\" SET")).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Dead(delivery(), fmt.Errorf("gone"))
+
+	assert.True(t, errors.Is(err, ErrNotSaved))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
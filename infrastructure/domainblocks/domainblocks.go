@@ -0,0 +1,69 @@
+// Package domainblocks contains the repository for the servers this one limits federation with.
+package domainblocks
+
+import (
+	"errors"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrNotFound is returned when a record cannot be found.
+	ErrNotFound = errors.New("domain block could not be found")
+	// ErrNotSaved is returned when a record cannot be saved.
+	ErrNotSaved = errors.New("domain block could not be saved")
+	// ErrNotDeleted is returned when a record cannot be deleted.
+	ErrNotDeleted = errors.New("domain block could not be deleted")
+	// ErrStorage is returned when an unknown storage issue occurs.
+	ErrStorage = errors.New("error with storage")
+)
+
+// New creates a new Repository instance for domain blocks.
+func New(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Repository is used for querying and saving domain blocks.
+type Repository struct {
+	db *gorm.DB
+}
+
+// Get returns the block of a domain.
+func (r *Repository) Get(domain string) (*model.DomainBlock, error) {
+	var block model.DomainBlock
+	if err := r.db.Where("domain = ?", domain).First(&block).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrStorage
+	}
+	return &block, nil
+}
+
+// List returns every blocked domain, in alphabetical order.
+func (r *Repository) List() ([]model.DomainBlock, error) {
+	var blocks []model.DomainBlock
+	if err := r.db.Order("domain asc").Find(&blocks).Error; err != nil {
+		return nil, ErrStorage
+	}
+	return blocks, nil
+}
+
+// Save creates or updates a domain block.
+func (r *Repository) Save(block *model.DomainBlock) error {
+	if err := r.db.Save(block).Error; err != nil {
+		return ErrNotSaved
+	}
+	return nil
+}
+
+// Delete removes a domain block.
+func (r *Repository) Delete(block *model.DomainBlock) error {
+	if err := r.db.Where("domain = ?", block.Domain).
+		Delete(&model.DomainBlock{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
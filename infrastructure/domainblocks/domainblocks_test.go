@@ -0,0 +1,99 @@
+package domainblocks
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func rows() *sqlmock.Rows {
+	ts := time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	return sqlmock.NewRows([]string{"domain", "level", "reason", "created_at", "updated_at"}).
+		AddRow("spam.example", model.DomainBlockReject, "spam", ts, ts)
+}
+
+func TestGet(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"domain_blocks\"  WHERE (domain = $1) ORDER BY \"domain_blocks\".\"domain\" ASC LIMIT 1") + "$").
+		WithArgs("spam.example").
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	block, err := repo.Get("spam.example")
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, block) {
+		assert.Equal(t, model.DomainBlockReject, block.Level)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_ErrNotFound(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"domain_blocks\"")).
+		WillReturnError(gorm.ErrRecordNotFound)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	block, err := repo.Get("friendly.example")
+
+	assert.Nil(t, block)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestList(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"domain_blocks\"   ORDER BY domain asc") + "$").
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	blocks, err := repo.List()
+
+	assert.NoError(t, err)
+	assert.Len(t, blocks, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSave(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("UPDATE \"domain_blocks\" SET")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Save(&model.DomainBlock{
+		Domain: "spam.example",
+		Level:  model.DomainBlockSilence,
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete_ErrNotDeleted(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"domain_blocks\"  WHERE (domain = $1)") + "$").
+		WithArgs("spam.example").
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Delete(&model.DomainBlock{Domain: "spam.example"})
+
+	assert.True(t, errors.Is(err, ErrNotDeleted))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,110 @@
+// Package followers contains the repository for the followers of local users.
+package followers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrNotFound is returned when a record cannot be found.
+	ErrNotFound = errors.New("follower could not be found")
+	// ErrNotSaved is returned when a record cannot be saved.
+	ErrNotSaved = errors.New("follower could not be saved")
+	// ErrNotDeleted is returned when a record cannot be deleted.
+	ErrNotDeleted = errors.New("follower could not be deleted")
+	// ErrStorage is returned when an unknown storage issue occurs.
+	ErrStorage = errors.New("error with storage")
+)
+
+// hostOfID is the SQL for the lowercased host of an actor's IRI. It avoids question marks, which gorm would take for placeholders.
+const hostOfID = "lower(substring(id from '^https{0,1}://([^/:#]+)'))"
+
+// likeEscaper escapes the characters LIKE treats specially, so that a domain is matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// New creates a new Repository instance for followers.
+func New(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Repository is used for querying and saving followers.
+type Repository struct {
+	db *gorm.DB
+}
+
+// Get returns the actor with the given IRI if they follow, or have asked to follow, a user.
+func (r *Repository) Get(user *model.User, actorIRI string) (*model.Follower, error) {
+	var follower model.Follower
+	if err := r.db.Where("user_id = ? AND id = ?", user.ID, actorIRI).First(&follower).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrStorage
+	}
+	follower.User = *user
+	return &follower, nil
+}
+
+// List returns a user's followers, newest first. Pending followers are not included.
+func (r *Repository) List(user *model.User) ([]model.Follower, error) {
+	return r.find(user, false)
+}
+
+// Pending returns the follow requests waiting for a user's approval, newest first.
+func (r *Repository) Pending(user *model.User) ([]model.Follower, error) {
+	return r.find(user, true)
+}
+
+func (r *Repository) find(user *model.User, pending bool) ([]model.Follower, error) {
+	var followers []model.Follower
+	if err := r.db.Where("user_id = ? AND pending = ?", user.ID, pending).
+		Order("created_at desc").
+		Find(&followers).
+		Error; err != nil {
+		return nil, ErrStorage
+	}
+	return followers, nil
+}
+
+// Save creates or updates a follower.
+func (r *Repository) Save(follower *model.Follower) error {
+	if err := r.db.Save(follower).Error; err != nil {
+		return ErrNotSaved
+	}
+	return nil
+}
+
+// Delete removes a follower, or declines their request to follow.
+func (r *Repository) Delete(follower *model.Follower) error {
+	if err := r.db.Where("user_id = ? AND id = ?", follower.UserID, follower.ID).
+		Delete(&model.Follower{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
+
+// DeleteByDomain removes every follower on a server, or on any of its subdomains, since that's what a domain block applies to.
+func (r *Repository) DeleteByDomain(domain string) error {
+	domain = strings.ToLower(domain)
+	if err := r.db.Where(hostOfID+" = ? OR "+hostOfID+" LIKE ?", domain, "%."+likeEscaper.Replace(domain)).
+		Delete(&model.Follower{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
+
+// DeleteByActor removes an actor as a follower of every local user.
+func (r *Repository) DeleteByActor(actorIRI string) error {
+	if err := r.db.Where("id = ?", actorIRI).
+		Delete(&model.Follower{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package followers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	followersRows *sqlmock.Rows
+	user          = &model.User{
+		Base: model.Base{
+			ID: uuid.MustParse("b3032140-e824-4b39-9be2-47e99f383f2b"),
+		},
+		Username: "bob",
+	}
+)
+
+func setup() {
+	ts := time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	followersRows = sqlmock.NewRows([]string{"id", "user_id", "follow_id", "pending", "created_at", "updated_at"}).
+		AddRow("https://mastodon.example/users/alice", "b3032140-e824-4b39-9be2-47e99f383f2b", "https://mastodon.example/1", true, ts, ts)
+}
+
+func teardown() {
+	followersRows = nil
+}
+
+func TestGet(t *testing.T) {
+	setup()
+	defer teardown()
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"followers\"  WHERE (user_id = $1 AND id = $2) ORDER BY \"followers\".\"id\" ASC LIMIT 1")+"$").
+		WithArgs(user.ID, "https://mastodon.example/users/alice").
+		WillReturnRows(followersRows)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	f, err := repo.Get(user, "https://mastodon.example/users/alice")
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, f) {
+		assert.True(t, f.Pending)
+		assert.Equal(t, "https://mastodon.example/1", f.FollowID)
+		assert.Equal(t, "bob", f.User.Username)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_ErrNotFound(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"followers\"")).
+		WillReturnError(gorm.ErrRecordNotFound)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	f, err := repo.Get(user, "https://mastodon.example/users/alice")
+
+	assert.Nil(t, f)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPending(t *testing.T) {
+	setup()
+	defer teardown()
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"followers\"  WHERE (user_id = $1 AND pending = $2) ORDER BY created_at desc")+"$").
+		WithArgs(user.ID, true).
+		WillReturnRows(followersRows)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	list, err := repo.Pending(user)
+
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestList_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"followers\"")).
+		WithArgs(user.ID, false).
+		WillReturnError(fmt.Errorf("oops"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	list, err := repo.List(user)
+
+	assert.Nil(t, list)
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSave(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("UPDATE \"followers\" SET")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Save(&model.Follower{
+		ID:       "https://mastodon.example/users/alice",
+		UserID:   user.ID,
+		FollowID: "https://mastodon.example/1",
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSave_ErrNotSaved(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("UPDATE \"followers\" SET")).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Save(&model.Follower{
+		ID:     "https://mastodon.example/users/alice",
+		UserID: user.ID,
+	})
+
+	assert.True(t, errors.Is(err, ErrNotSaved))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("DELETE FROM \"followers\"  WHERE (user_id = $1 AND id = $2)")+"$").
+		WithArgs(user.ID, "https://mastodon.example/users/alice").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Delete(&model.Follower{
+		ID:     "https://mastodon.example/users/alice",
+		UserID: user.ID,
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteByDomain(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("DELETE FROM \"followers\"  WHERE (lower(substring(id from '^https{0,1}://([^/:#]+)')) = $1 OR lower(substring(id from '^https{0,1}://([^/:#]+)')) LIKE $2)")+"$").
+		WithArgs("spam.example", "%.spam.example").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.DeleteByDomain("spam.example")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteByDomain_Escaped(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("DELETE FROM \"followers\"")).
+		WithArgs("my_spam.example", `%.my\_spam.example`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.DeleteByDomain("My_Spam.example")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteByActor(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"followers\"  WHERE (id = $1)") + "$").
+		WithArgs("https://elsewhere.example/user/alice").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.DeleteByActor("https://elsewhere.example/user/alice")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,125 @@
+// Package following contains the repository for the actors local users follow.
+package following
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrNotFound is returned when a record cannot be found.
+	ErrNotFound = errors.New("follow could not be found")
+	// ErrNotSaved is returned when a record cannot be saved.
+	ErrNotSaved = errors.New("follow could not be saved")
+	// ErrNotDeleted is returned when a record cannot be deleted.
+	ErrNotDeleted = errors.New("follow could not be deleted")
+	// ErrStorage is returned when an unknown storage issue occurs.
+	ErrStorage = errors.New("error with storage")
+)
+
+// hostOfID is the SQL for the lowercased host of an actor's IRI. It avoids question marks, which gorm would take for placeholders.
+const hostOfID = "lower(substring(id from '^https{0,1}://([^/:#]+)'))"
+
+// likeEscaper escapes the characters LIKE treats specially, so that a domain is matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// New creates a new Repository instance for following.
+func New(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Repository is used for querying and saving who users follow.
+type Repository struct {
+	db *gorm.DB
+}
+
+// Get returns the follow of the actor with the given IRI by a user, whether or not it has been accepted.
+func (r *Repository) Get(user *model.User, actorIRI string) (*model.Following, error) {
+	var following model.Following
+	if err := r.db.Where("user_id = ? AND id = ?", user.ID, actorIRI).First(&following).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrStorage
+	}
+	following.User = *user
+	return &following, nil
+}
+
+// GetByFollowID returns a follow given the id of the Follow activity that was sent. It includes the user who sent it.
+func (r *Repository) GetByFollowID(followID string) (*model.Following, error) {
+	var following model.Following
+	if err := r.db.Preload("User").Where("follow_id = ?", followID).First(&following).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrStorage
+	}
+	return &following, nil
+}
+
+// List returns who a user follows or has asked to follow, newest first.
+func (r *Repository) List(user *model.User) ([]model.Following, error) {
+	var following []model.Following
+	if err := r.db.Where("user_id = ?", user.ID).
+		Order("created_at desc").
+		Find(&following).
+		Error; err != nil {
+		return nil, ErrStorage
+	}
+	return following, nil
+}
+
+// Followers returns the accepted follows of an actor by local users, including the users.
+func (r *Repository) Followers(actorIRI string) ([]model.Following, error) {
+	var following []model.Following
+	if err := r.db.Preload("User").
+		Where("id = ? AND accepted = ?", actorIRI, true).
+		Find(&following).
+		Error; err != nil {
+		return nil, ErrStorage
+	}
+	return following, nil
+}
+
+// Save creates or updates a follow.
+func (r *Repository) Save(following *model.Following) error {
+	if err := r.db.Save(following).Error; err != nil {
+		return ErrNotSaved
+	}
+	return nil
+}
+
+// Delete removes a follow.
+func (r *Repository) Delete(following *model.Following) error {
+	if err := r.db.Where("user_id = ? AND id = ?", following.UserID, following.ID).
+		Delete(&model.Following{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
+
+// DeleteByDomain removes every follow of an actor on a server, or on any of its subdomains, since that's what a domain block applies to.
+func (r *Repository) DeleteByDomain(domain string) error {
+	domain = strings.ToLower(domain)
+	if err := r.db.Where(hostOfID+" = ? OR "+hostOfID+" LIKE ?", domain, "%."+likeEscaper.Replace(domain)).
+		Delete(&model.Following{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
+
+// DeleteByActor removes every follow of an actor.
+func (r *Repository) DeleteByActor(actorIRI string) error {
+	if err := r.db.Where("id = ?", actorIRI).
+		Delete(&model.Following{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package following
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	followingRows *sqlmock.Rows
+	user          = &model.User{
+		Base: model.Base{
+			ID: uuid.MustParse("b3032140-e824-4b39-9be2-47e99f383f2b"),
+		},
+		Username: "bob",
+	}
+)
+
+func setup() {
+	ts := time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	followingRows = sqlmock.NewRows([]string{"id", "user_id", "follow_id", "accepted", "created_at", "updated_at"}).
+		AddRow("https://mastodon.example/users/alice", "b3032140-e824-4b39-9be2-47e99f383f2b", "https://exlibris.example/user/bob/follow/1", false, ts, ts)
+}
+
+func teardown() {
+	followingRows = nil
+}
+
+func TestGet(t *testing.T) {
+	setup()
+	defer teardown()
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"following\"  WHERE (user_id = $1 AND id = $2) ORDER BY \"following\".\"id\" ASC LIMIT 1")+"$").
+		WithArgs(user.ID, "https://mastodon.example/users/alice").
+		WillReturnRows(followingRows)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	f, err := repo.Get(user, "https://mastodon.example/users/alice")
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, f) {
+		assert.False(t, f.Accepted)
+		assert.Equal(t, "bob", f.User.Username)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_ErrNotFound(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"following\"")).
+		WillReturnError(gorm.ErrRecordNotFound)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	f, err := repo.Get(user, "https://mastodon.example/users/alice")
+
+	assert.Nil(t, f)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByFollowID(t *testing.T) {
+	setup()
+	defer teardown()
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"following\"  WHERE (follow_id = $1) ORDER BY \"following\".\"id\" ASC LIMIT 1") + "$").
+		WithArgs("https://exlibris.example/user/bob/follow/1").
+		WillReturnRows(followingRows)
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"users\"")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow(user.ID, "bob"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	f, err := repo.GetByFollowID("https://exlibris.example/user/bob/follow/1")
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, f) {
+		assert.Equal(t, "https://mastodon.example/users/alice", f.ID)
+		assert.Equal(t, "bob", f.User.Username)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByFollowID_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"following\"")).
+		WillReturnError(fmt.Errorf("oops"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	f, err := repo.GetByFollowID("https://exlibris.example/user/bob/follow/1")
+
+	assert.Nil(t, f)
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestList(t *testing.T) {
+	setup()
+	defer teardown()
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"following\"  WHERE (user_id = $1) ORDER BY created_at desc") + "$").
+		WithArgs(user.ID).
+		WillReturnRows(followingRows)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	list, err := repo.List(user)
+
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowers(t *testing.T) {
+	setup()
+	defer teardown()
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"following\"  WHERE (id = $1 AND accepted = $2)")+"$").
+		WithArgs("https://mastodon.example/users/alice", true).
+		WillReturnRows(followingRows)
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"users\"")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow(user.ID, "bob"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	list, err := repo.Followers("https://mastodon.example/users/alice")
+
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "bob", list[0].User.Username)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSave(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("UPDATE \"following\" SET")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Save(&model.Following{
+		ID:       "https://mastodon.example/users/alice",
+		UserID:   user.ID,
+		FollowID: "https://exlibris.example/user/bob/follow/1",
+		Accepted: true,
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete_ErrNotDeleted(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("DELETE FROM \"following\"  WHERE (user_id = $1 AND id = $2)")+"$").
+		WithArgs(user.ID, "https://mastodon.example/users/alice").
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Delete(&model.Following{
+		ID:     "https://mastodon.example/users/alice",
+		UserID: user.ID,
+	})
+
+	assert.True(t, errors.Is(err, ErrNotDeleted))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteByDomain(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("DELETE FROM \"following\"  WHERE (lower(substring(id from '^https{0,1}://([^/:#]+)')) = $1 OR lower(substring(id from '^https{0,1}://([^/:#]+)')) LIKE $2)")+"$").
+		WithArgs("spam.example", "%.spam.example").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.DeleteByDomain("spam.example")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteByDomain_Escaped(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("DELETE FROM \"following\"")).
+		WithArgs("my_spam.example", `%.my\_spam.example`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.DeleteByDomain("My_Spam.example")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteByActor(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"following\"  WHERE (id = $1)") + "$").
+		WithArgs("https://elsewhere.example/user/alice").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.DeleteByActor("https://elsewhere.example/user/alice")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
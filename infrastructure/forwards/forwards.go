@@ -0,0 +1,40 @@
+// Package forwards contains the repository for records of activities forwarded to the followers of local users.
+package forwards
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrNotCreated is returned when a record cannot be created.
+	ErrNotCreated = errors.New("forward could not be created")
+)
+
+// New creates a new Repository instance for forwards.
+func New(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Repository is used for recording forwarded activities.
+type Repository struct {
+	db *gorm.DB
+}
+
+// Claim records that an activity is being forwarded. It returns false if it already has been, in which case it must not be forwarded again.
+func (r *Repository) Claim(activityID string) (bool, error) {
+	result := r.db.Set("gorm:insert_option", "ON CONFLICT DO NOTHING").
+		Create(&model.Forward{ID: activityID})
+	if errors.Is(result.Error, sql.ErrNoRows) {
+		// nothing was inserted, so nothing was returned
+		return false, nil
+	} else if result.Error != nil {
+		log.Printf("error recording forward of %s: %s", activityID, result.Error.Error())
+		return false, ErrNotCreated
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,63 @@
+package forwards
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaim(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"forwards\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\") VALUES ($1,$2,$3,$4) ON CONFLICT DO NOTHING RETURNING \"forwards\".\"id\"")+"$").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "https://mastodon.example/users/alice/statuses/1/activity").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("https://mastodon.example/users/alice/statuses/1/activity"))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	first, err := repo.Claim("https://mastodon.example/users/alice/statuses/1/activity")
+
+	assert.NoError(t, err)
+	assert.True(t, first)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaim_AlreadyForwarded(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"forwards\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\") VALUES ($1,$2,$3,$4) ON CONFLICT DO NOTHING RETURNING \"forwards\".\"id\"")+"$").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "https://mastodon.example/users/alice/statuses/1/activity").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	first, err := repo.Claim("https://mastodon.example/users/alice/statuses/1/activity")
+
+	assert.NoError(t, err)
+	assert.False(t, first)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaim_ErrNotCreated(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("INSERT INTO \"forwards\"")).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	first, err := repo.Claim("https://mastodon.example/users/alice/statuses/1/activity")
+
+	assert.Error(t, err)
+	assert.False(t, first)
+	assert.True(t, errors.Is(err, ErrNotCreated))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
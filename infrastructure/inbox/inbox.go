@@ -5,6 +5,7 @@ import (
 
 	"github.com/exlibris-fed/exlibris/model"
 
+	"github.com/google/uuid"
 	"github.com/jinzhu/gorm"
 )
 
@@ -18,14 +19,47 @@ type Repository struct {
 	db *gorm.DB
 }
 
-// GetByIRI retrieves the contents of a user's inbox given the IRI to it.
-//
-// TODO pagination
-func (r *Repository) GetByIRI(inboxIRI *url.URL) (entries []*model.InboxEntry, err error) {
+// Page retrieves up to limit entries of a user's inbox given the IRI to it, newest first. If before is set, the entries immediately older than that entry are returned. If after is set, the entries immediately newer than it are.
+func (r *Repository) Page(inboxIRI *url.URL, before, after uuid.UUID, limit int) (entries []*model.InboxEntry, err error) {
+	q := r.db.Where("inbox_iri = ?", inboxIRI.String())
+	switch {
+	case before != uuid.Nil:
+		q = q.Where("(created_at, id) < (SELECT created_at, id FROM inbox_entries WHERE id = ?)", before).
+			Order("created_at desc, id desc")
+	case after != uuid.Nil:
+		q = q.Where("(created_at, id) > (SELECT created_at, id FROM inbox_entries WHERE id = ?)", after).
+			Order("created_at asc, id asc")
+	default:
+		q = q.Order("created_at desc, id desc")
+	}
+	if gormErr := q.Limit(limit).Find(&entries).Error; gormErr != nil {
+		return nil, ErrNotFound
+	}
+	if after != uuid.Nil {
+		reverse(entries)
+	}
+	return
+}
+
+// Last retrieves up to limit of the oldest entries of a user's inbox, newest first.
+func (r *Repository) Last(inboxIRI *url.URL, limit int) (entries []*model.InboxEntry, err error) {
 	if gormErr := r.db.Where("inbox_iri = ?", inboxIRI.String()).
-		Order("created_at desc").
+		Order("created_at asc, id asc").
+		Limit(limit).
 		Find(&entries).
 		Error; gormErr != nil {
+		return nil, ErrNotFound
+	}
+	reverse(entries)
+	return
+}
+
+// Count returns how many entries are in a user's inbox.
+func (r *Repository) Count(inboxIRI *url.URL) (count int, err error) {
+	if gormErr := r.db.Model(&model.InboxEntry{}).
+		Where("inbox_iri = ?", inboxIRI.String()).
+		Count(&count).
+		Error; gormErr != nil {
 		err = ErrNotFound
 	}
 	return
@@ -46,6 +80,21 @@ func (r *Repository) Contains(inboxIRI, iri *url.URL) (contains bool, err error)
 	return
 }
 
+// Seen returns whether an activity has been delivered to any local inbox.
+func (r *Repository) Seen(iri *url.URL) (seen bool, err error) {
+	var count int
+	if gormErr := r.db.Model(&model.InboxEntry{}).
+		Where("uri = ?", iri.String()).
+		Count(&count).
+		Error; gormErr != nil {
+		err = ErrNotFound
+		return
+	}
+
+	seen = count > 0
+	return
+}
+
 // Create persists a new InboxEntry
 func (r *Repository) Create(i *model.InboxEntry) error {
 	if err := r.db.Create(i).Error; err != nil {
@@ -53,3 +102,9 @@ func (r *Repository) Create(i *model.InboxEntry) error {
 	}
 	return nil
 }
+
+func reverse(entries []*model.InboxEntry) {
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+}
@@ -0,0 +1,153 @@
+package inbox
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	inboxIRI, _ = url.Parse("https://exlibris.example/user/bob/inbox")
+	firstID     = uuid.MustParse("0b6f7a0e-58d2-4a4c-8d7e-2b1d3c0f6a01")
+	secondID    = uuid.MustParse("0b6f7a0e-58d2-4a4c-8d7e-2b1d3c0f6a02")
+)
+
+func rows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "inbox_iri", "uri"}).
+		AddRow(firstID, inboxIRI.String(), "https://exlibris.example/user/bob/read/1").
+		AddRow(secondID, inboxIRI.String(), "https://exlibris.example/user/bob/read/2")
+}
+
+func TestPage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"inbox_entries\" WHERE \"inbox_entries\".\"deleted_at\" IS NULL AND ((inbox_iri = $1)) ORDER BY created_at desc, id desc LIMIT 11") + "$").
+		WithArgs(inboxIRI.String()).
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	entries, err := repo.Page(inboxIRI, uuid.Nil, uuid.Nil, 11)
+
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, firstID, entries[0].ID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPage_Before(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"inbox_entries\" WHERE \"inbox_entries\".\"deleted_at\" IS NULL AND ((inbox_iri = $1) AND ((created_at, id) < (SELECT created_at, id FROM inbox_entries WHERE id = $2))) ORDER BY created_at desc, id desc LIMIT 11")+"$").
+		WithArgs(inboxIRI.String(), secondID).
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	entries, err := repo.Page(inboxIRI, secondID, uuid.Nil, 11)
+
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPage_After(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"inbox_entries\" WHERE \"inbox_entries\".\"deleted_at\" IS NULL AND ((inbox_iri = $1) AND ((created_at, id) > (SELECT created_at, id FROM inbox_entries WHERE id = $2))) ORDER BY created_at asc, id asc LIMIT 11")+"$").
+		WithArgs(inboxIRI.String(), firstID).
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	entries, err := repo.Page(inboxIRI, uuid.Nil, firstID, 11)
+
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, secondID, entries[0].ID, "entries should be newest first")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPage_ErrNotFound(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"inbox_entries\"")).
+		WillReturnError(fmt.Errorf("oops"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	entries, err := repo.Page(inboxIRI, uuid.Nil, uuid.Nil, 11)
+
+	assert.Nil(t, entries)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLast(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"inbox_entries\" WHERE \"inbox_entries\".\"deleted_at\" IS NULL AND ((inbox_iri = $1)) ORDER BY created_at asc, id asc LIMIT 11") + "$").
+		WithArgs(inboxIRI.String()).
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	entries, err := repo.Last(inboxIRI, 11)
+
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, secondID, entries[0].ID, "entries should be newest first")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCount(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT count(*) FROM \"inbox_entries\" WHERE \"inbox_entries\".\"deleted_at\" IS NULL AND ((inbox_iri = $1))") + "$").
+		WithArgs(inboxIRI.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	count, err := repo.Count(inboxIRI)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 42, count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestContains(t *testing.T) {
+	iri, _ := url.Parse("https://exlibris.example/user/bob/read/1")
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT count(*) FROM \"inbox_entries\" WHERE \"inbox_entries\".\"deleted_at\" IS NULL AND ((inbox_iri = $1 AND uri = $2))")+"$").
+		WithArgs(inboxIRI.String(), iri.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	contains, err := repo.Contains(inboxIRI, iri)
+
+	assert.NoError(t, err)
+	assert.True(t, contains)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSeen(t *testing.T) {
+	iri, _ := url.Parse("https://mastodon.example/users/alice/statuses/1/activity")
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT count(*) FROM \"inbox_entries\" WHERE \"inbox_entries\".\"deleted_at\" IS NULL AND ((uri = $1))") + "$").
+		WithArgs(iri.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	seen, err := repo.Seen(iri)
+
+	assert.NoError(t, err)
+	assert.False(t, seen)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
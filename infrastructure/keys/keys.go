@@ -0,0 +1,62 @@
+// Package keys contains the repository for the public keys of remote actors.
+package keys
+
+import (
+	"errors"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrNotFound is returned when a record cannot be found.
+	ErrNotFound = errors.New("key could not be found")
+	// ErrNotSaved is returned when a record cannot be saved.
+	ErrNotSaved = errors.New("key could not be saved")
+	// ErrNotDeleted is returned when a record cannot be deleted.
+	ErrNotDeleted = errors.New("key could not be deleted")
+	// ErrStorage is returned when an unknown storage issue occurs.
+	ErrStorage = errors.New("error with storage")
+)
+
+// New creates a new Repository instance for remote keys.
+func New(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Repository is used for querying and saving remote keys.
+type Repository struct {
+	db *gorm.DB
+}
+
+// Get returns a cached key given its id, which is the `keyId` used in HTTP Signatures.
+func (r *Repository) Get(id string) (*model.RemoteKey, error) {
+	var key model.RemoteKey
+	result := r.db.Where("id = ?", id).First(&key)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrStorage
+	}
+	return &key, nil
+}
+
+// Save creates or replaces a cached key.
+func (r *Repository) Save(key *model.RemoteKey) error {
+	if err := r.db.Save(key).Error; err != nil {
+		return ErrNotSaved
+	}
+	return nil
+}
+
+// DeleteByOwner removes every cached key belonging to an actor, such as one whose server says they're gone.
+func (r *Repository) DeleteByOwner(owner string) error {
+	if err := r.db.Unscoped().
+		Where("owner = ?", owner).
+		Delete(&model.RemoteKey{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+var keysRows *sqlmock.Rows
+
+func setup() {
+	ts := time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	keysRows = sqlmock.NewRows([]string{"created_at", "updated_at", "deleted_at", "id", "owner", "pem"}).
+		AddRow(ts, ts, nil, "https://mastodon.example/users/alice#main-key", "https://mastodon.example/users/alice", "-----BEGIN PUBLIC KEY-----")
+}
+
+func teardown() {
+	keysRows = nil
+}
+
+func TestGet(t *testing.T) {
+	setup()
+	defer teardown()
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"remote_keys\" WHERE \"remote_keys\".\"deleted_at\" IS NULL AND ((id = $1)) ORDER BY \"remote_keys\".\"id\" ASC LIMIT 1") + "$").
+		WithArgs("https://mastodon.example/users/alice#main-key").
+		WillReturnRows(keysRows)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	key, err := repo.Get("https://mastodon.example/users/alice#main-key")
+
+	assert.NoError(t, err)
+	assert.NotNil(t, key)
+	assert.Equal(t, "https://mastodon.example/users/alice", key.Owner)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_ErrNotFound(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"remote_keys\"")).
+		WithArgs("https://mastodon.example/users/alice#main-key").
+		WillReturnError(gorm.ErrRecordNotFound)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	key, err := repo.Get("https://mastodon.example/users/alice#main-key")
+
+	assert.Error(t, err)
+	assert.Nil(t, key)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"remote_keys\"")).
+		WithArgs("https://mastodon.example/users/alice#main-key").
+		WillReturnError(fmt.Errorf("oops"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	key, err := repo.Get("https://mastodon.example/users/alice#main-key")
+
+	assert.Error(t, err)
+	assert.Nil(t, key)
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSave(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("UPDATE \"remote_keys\" SET")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Save(&model.RemoteKey{
+		ID:    "https://mastodon.example/users/alice#main-key",
+		Owner: "https://mastodon.example/users/alice",
+		PEM:   "-----BEGIN PUBLIC KEY-----",
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSave_ErrNotSaved(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("UPDATE \"remote_keys\" SET")).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Save(&model.RemoteKey{
+		ID:    "https://mastodon.example/users/alice#main-key",
+		Owner: "https://mastodon.example/users/alice",
+		PEM:   "-----BEGIN PUBLIC KEY-----",
+	})
+
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrNotSaved))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteByOwner(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"remote_keys\"  WHERE (owner = $1)") + "$").
+		WithArgs("https://mastodon.example/users/alice").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.DeleteByOwner("https://mastodon.example/users/alice")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
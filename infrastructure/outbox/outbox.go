@@ -5,6 +5,7 @@ import (
 
 	"github.com/exlibris-fed/exlibris/model"
 
+	"github.com/google/uuid"
 	"github.com/jinzhu/gorm"
 )
 
@@ -18,14 +19,64 @@ type Repository struct {
 	db *gorm.DB
 }
 
-// GetByIRI retrieves the contents of a user's outbox given the IRI to it.
-func (r *Repository) GetByIRI(outboxIRI *url.URL) (entries []*model.OutboxEntry, err error) {
+// Page retrieves up to limit entries of a user's outbox given the IRI to it, newest first. If before is set, the entries immediately older than that entry are returned. If after is set, the entries immediately newer than it are.
+func (r *Repository) Page(outboxIRI *url.URL, before, after uuid.UUID, limit int) (entries []*model.OutboxEntry, err error) {
+	q := r.db.Where("outbox_iri = ?", outboxIRI.String())
+	switch {
+	case before != uuid.Nil:
+		q = q.Where("(created_at, id) < (SELECT created_at, id FROM outbox_entries WHERE id = ?)", before).
+			Order("created_at desc, id desc")
+	case after != uuid.Nil:
+		q = q.Where("(created_at, id) > (SELECT created_at, id FROM outbox_entries WHERE id = ?)", after).
+			Order("created_at asc, id asc")
+	default:
+		q = q.Order("created_at desc, id desc")
+	}
+	if gormErr := q.Limit(limit).Find(&entries).Error; gormErr != nil {
+		return nil, ErrNotFound
+	}
+	if after != uuid.Nil {
+		reverse(entries)
+	}
+	return
+}
+
+// Last retrieves up to limit of the oldest entries of a user's outbox, newest first.
+func (r *Repository) Last(outboxIRI *url.URL, limit int) (entries []*model.OutboxEntry, err error) {
 	if gormErr := r.db.Where("outbox_iri = ?", outboxIRI.String()).
-		Order("created_at desc").
+		Order("created_at asc, id asc").
+		Limit(limit).
 		Find(&entries).
 		Error; gormErr != nil {
-			err = ErrNotFound
-		}
+		return nil, ErrNotFound
+	}
+	reverse(entries)
+	return
+}
+
+// Count returns how many entries are in a user's outbox.
+func (r *Repository) Count(outboxIRI *url.URL) (count int, err error) {
+	if gormErr := r.db.Model(&model.OutboxEntry{}).
+		Where("outbox_iri = ?", outboxIRI.String()).
+		Count(&count).
+		Error; gormErr != nil {
+		err = ErrNotFound
+	}
+	return
+}
+
+// Contains returns whether an outbox contains a specific IRI.
+func (r *Repository) Contains(outboxIRI, iri *url.URL) (contains bool, err error) {
+	var count int
+	if gormErr := r.db.Model(&model.OutboxEntry{}).
+		Where("outbox_iri = ? AND uri = ?", outboxIRI.String(), iri.String()).
+		Count(&count).
+		Error; gormErr != nil {
+		err = ErrNotFound
+		return
+	}
+
+	contains = count > 0
 	return
 }
 
@@ -36,3 +87,9 @@ func (r *Repository) Create(o *model.OutboxEntry) error {
 	}
 	return nil
 }
+
+func reverse(entries []*model.OutboxEntry) {
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+}
@@ -3,6 +3,7 @@ package infrastructure
 import (
 	"log"
 
+	"github.com/exlibris-fed/exlibris/infrastructure/users"
 	"github.com/exlibris-fed/exlibris/model"
 	"github.com/jinzhu/gorm"
 )
@@ -26,8 +27,23 @@ func Migrate(db *gorm.DB) {
 	db.AutoMigrate(model.Review{})
 	db.AutoMigrate(model.User{})
 	db.AutoMigrate(model.Follower{})
+	db.AutoMigrate(model.Following{})
 	db.AutoMigrate(model.RegistrationKey{})
 	db.AutoMigrate(model.Cover{})
+	db.AutoMigrate(model.RemoteKey{})
+	db.AutoMigrate(model.RemoteActor{})
+	db.AutoMigrate(model.Delivery{})
+	db.AutoMigrate(model.Block{})
+	db.AutoMigrate(model.DomainBlock{})
+	db.AutoMigrate(model.Tombstone{})
+	db.AutoMigrate(model.Reaction{})
+	db.AutoMigrate(model.Reply{})
+	db.AutoMigrate(model.Forward{})
+	db.AutoMigrate(model.Relay{})
+
+	if err := users.New(db).MarkLocal(); err != nil {
+		log.Printf("error marking local users: %s", err.Error())
+	}
 
 	db.Table("book_authors").AddForeignKey("author_open_library_id", "authors(open_library_id)", "CASCADE", "CASCADE")
 	db.Table("book_authors").AddForeignKey("book_open_library_id", "books(open_library_id)", "CASCADE", "CASCADE")
@@ -40,8 +56,18 @@ func Migrate(db *gorm.DB) {
 	db.Model(&model.Review{}).AddForeignKey("user_id", "users(id)", "CASCADE", "CASCADE")
 	db.Model(&model.Review{}).AddForeignKey("book_id", "books(open_library_id)", "CASCADE", "CASCADE")
 
+	db.Model(&model.Reply{}).AddForeignKey("review_id", "reviews(id)", "CASCADE", "CASCADE")
+	db.Model(&model.Reply{}).AddForeignKey("user_id", "users(id)", "CASCADE", "CASCADE")
+
 	db.Model(&model.Cover{}).AddForeignKey("book_id", "books(open_library_id)", "CASCADE", "CASCADE")
 
 	db.Model(&model.RegistrationKey{}).AddForeignKey("user_id", "users(id)", "CASCADE", "CASCADE")
 
+	db.Model(&model.Follower{}).AddForeignKey("user_id", "users(id)", "CASCADE", "CASCADE")
+	db.Model(&model.Following{}).AddForeignKey("user_id", "users(id)", "CASCADE", "CASCADE")
+
+	db.Model(&model.Delivery{}).AddForeignKey("user_id", "users(id)", "CASCADE", "CASCADE")
+
+	db.Model(&model.Block{}).AddForeignKey("user_id", "users(id)", "CASCADE", "CASCADE")
+
 }
@@ -0,0 +1,145 @@
+// Package reactions contains the repository for likes and announces of reads and reviews.
+package reactions
+
+import (
+	"errors"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrNotFound is returned when a record cannot be found.
+	ErrNotFound = errors.New("reaction could not be found")
+	// ErrNotSaved is returned when a record cannot be saved.
+	ErrNotSaved = errors.New("reaction could not be saved")
+	// ErrNotDeleted is returned when a record cannot be deleted.
+	ErrNotDeleted = errors.New("reaction could not be deleted")
+	// ErrStorage is returned when an unknown storage issue occurs.
+	ErrStorage = errors.New("error with storage")
+)
+
+// New creates a new Repository instance for reactions.
+func New(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Repository is used for querying and saving likes and announces.
+type Repository struct {
+	db *gorm.DB
+}
+
+// Get returns the reaction with the given activity IRI.
+func (r *Repository) Get(id string) (*model.Reaction, error) {
+	var reaction model.Reaction
+	if err := r.db.Where("id = ?", id).First(&reaction).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrStorage
+	}
+	return &reaction, nil
+}
+
+// GetByActor returns an actor's reaction of the given kind to an object.
+func (r *Repository) GetByActor(kind, actorIRI, objectIRI string) (*model.Reaction, error) {
+	var reaction model.Reaction
+	if err := r.db.Where("kind = ? AND actor_iri = ? AND object_iri = ?", kind, actorIRI, objectIRI).First(&reaction).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrStorage
+	}
+	return &reaction, nil
+}
+
+// List returns the reactions of the given kind to an object, newest first.
+func (r *Repository) List(kind, objectIRI string) ([]model.Reaction, error) {
+	var reactions []model.Reaction
+	if err := r.db.Where("kind = ? AND object_iri = ?", kind, objectIRI).
+		Order("created_at desc").
+		Find(&reactions).
+		Error; err != nil {
+		return nil, ErrStorage
+	}
+	return reactions, nil
+}
+
+// ListByActor returns an actor's reactions of the given kind, newest first.
+func (r *Repository) ListByActor(kind, actorIRI string) ([]model.Reaction, error) {
+	var reactions []model.Reaction
+	if err := r.db.Where("kind = ? AND actor_iri = ?", kind, actorIRI).
+		Order("created_at desc").
+		Find(&reactions).
+		Error; err != nil {
+		return nil, ErrStorage
+	}
+	return reactions, nil
+}
+
+// Counts returns how many reactions of the given kind each of the objects has. Objects without any are left out.
+func (r *Repository) Counts(kind string, objectIRIs []string) (map[string]int, error) {
+	counts := make(map[string]int)
+	if len(objectIRIs) == 0 {
+		return counts, nil
+	}
+	rows, err := r.db.Model(&model.Reaction{}).
+		Select("object_iri, count(*)").
+		Where("kind = ? AND object_iri IN (?)", kind, objectIRIs).
+		Group("object_iri").
+		Rows()
+	if err != nil {
+		return nil, ErrStorage
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var objectIRI string
+		var count int
+		if err := rows.Scan(&objectIRI, &count); err != nil {
+			return nil, ErrStorage
+		}
+		counts[objectIRI] = count
+	}
+	if rows.Err() != nil {
+		return nil, ErrStorage
+	}
+	return counts, nil
+}
+
+// Create saves a reaction.
+func (r *Repository) Create(reaction *model.Reaction) error {
+	if err := r.db.Create(reaction).Error; err != nil {
+		return ErrNotSaved
+	}
+	return nil
+}
+
+// Delete removes a reaction.
+func (r *Repository) Delete(reaction *model.Reaction) error {
+	if err := r.db.Where("id = ?", reaction.ID).
+		Delete(&model.Reaction{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
+
+// DeleteByActor removes every reaction by an actor.
+func (r *Repository) DeleteByActor(actorIRI string) error {
+	if err := r.db.Where("actor_iri = ?", actorIRI).
+		Delete(&model.Reaction{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
+
+// DeleteByObject removes every reaction to an object.
+func (r *Repository) DeleteByObject(objectIRI string) error {
+	if err := r.db.Where("object_iri = ?", objectIRI).
+		Delete(&model.Reaction{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package reactions
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	likeID    = "https://mastodon.example/users/alice#likes/1"
+	actorIRI  = "https://mastodon.example/users/alice"
+	objectIRI = "https://exlibris.example/user/bob/review/10698c21-f094-4a83-8ec7-3221fa9e806e"
+	authorIRI = "https://exlibris.example/user/bob"
+)
+
+func rows() *sqlmock.Rows {
+	ts := time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	return sqlmock.NewRows([]string{"id", "kind", "actor_iri", "object_iri", "author_iri", "created_at", "updated_at"}).
+		AddRow(likeID, model.ReactionLike, actorIRI, objectIRI, authorIRI, ts, ts)
+}
+
+func TestGet(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"reactions\"  WHERE (id = $1) ORDER BY \"reactions\".\"id\" ASC LIMIT 1") + "$").
+		WithArgs(likeID).
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	reaction, err := repo.Get(likeID)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, reaction) {
+		assert.Equal(t, model.ReactionLike, reaction.Kind)
+		assert.Equal(t, objectIRI, reaction.ObjectIRI)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_ErrNotFound(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"reactions\"")).
+		WillReturnError(gorm.ErrRecordNotFound)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	reaction, err := repo.Get(likeID)
+
+	assert.Nil(t, reaction)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByActor(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"reactions\"  WHERE (kind = $1 AND actor_iri = $2 AND object_iri = $3) ORDER BY \"reactions\".\"id\" ASC LIMIT 1")+"$").
+		WithArgs(model.ReactionLike, actorIRI, objectIRI).
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	reaction, err := repo.GetByActor(model.ReactionLike, actorIRI, objectIRI)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, reaction) {
+		assert.Equal(t, likeID, reaction.ID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByActor_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"reactions\"")).
+		WillReturnError(fmt.Errorf("oops"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	reaction, err := repo.GetByActor(model.ReactionAnnounce, actorIRI, objectIRI)
+
+	assert.Nil(t, reaction)
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestList(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"reactions\"  WHERE (kind = $1 AND object_iri = $2) ORDER BY created_at desc")+"$").
+		WithArgs(model.ReactionLike, objectIRI).
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	list, err := repo.List(model.ReactionLike, objectIRI)
+
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListByActor(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"reactions\"  WHERE (kind = $1 AND actor_iri = $2) ORDER BY created_at desc")+"$").
+		WithArgs(model.ReactionLike, actorIRI).
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	list, err := repo.ListByActor(model.ReactionLike, actorIRI)
+
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCounts(t *testing.T) {
+	other := "https://exlibris.example/user/bob/read/1f5b3e9c-7a1c-4c55-a4a3-4b6fb5d0a2d1"
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT object_iri, count(*) FROM \"reactions\"  WHERE (kind = $1 AND object_iri IN ($2,$3)) GROUP BY object_iri")+"$").
+		WithArgs(model.ReactionAnnounce, objectIRI, other).
+		WillReturnRows(sqlmock.NewRows([]string{"object_iri", "count"}).AddRow(objectIRI, 3))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	counts, err := repo.Counts(model.ReactionAnnounce, []string{objectIRI, other})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, counts[objectIRI])
+	assert.Equal(t, 0, counts[other])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCounts_Empty(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	counts, err := repo.Counts(model.ReactionLike, nil)
+
+	assert.NoError(t, err)
+	assert.Empty(t, counts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreate(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"reactions\" (\"id\",\"kind\",\"actor_iri\",\"object_iri\",\"author_iri\",\"created_at\",\"updated_at\") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING \"reactions\".\"id\"")+"$").
+		WithArgs(likeID, model.ReactionLike, actorIRI, objectIRI, authorIRI, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(likeID))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Create(&model.Reaction{
+		ID:        likeID,
+		Kind:      model.ReactionLike,
+		ActorIRI:  actorIRI,
+		ObjectIRI: objectIRI,
+		AuthorIRI: authorIRI,
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreate_ErrNotSaved(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("INSERT INTO \"reactions\"")).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Create(&model.Reaction{
+		ID:        likeID,
+		Kind:      model.ReactionLike,
+		ActorIRI:  actorIRI,
+		ObjectIRI: objectIRI,
+	})
+
+	assert.True(t, errors.Is(err, ErrNotSaved))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"reactions\"  WHERE (id = $1)") + "$").
+		WithArgs(likeID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Delete(&model.Reaction{ID: likeID})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteByActor(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"reactions\"  WHERE (actor_iri = $1)") + "$").
+		WithArgs(actorIRI).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.DeleteByActor(actorIRI)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteByObject(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"reactions\"  WHERE (object_iri = $1)") + "$").
+		WithArgs(objectIRI).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.DeleteByObject(objectIRI)
+
+	assert.True(t, errors.Is(err, ErrNotDeleted))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -12,6 +12,10 @@ var (
 	ErrNotFound = errors.New("reads could not be found for user")
 	// ErrNotCreated is returned when a record cannot be created.
 	ErrNotCreated = errors.New("read could not be saved")
+	// ErrNotDeleted is returned when a record cannot be deleted.
+	ErrNotDeleted = errors.New("read could not be deleted")
+	// ErrStorage is returned when an unknown storage issue occurs.
+	ErrStorage = errors.New("could not retrieve data")
 )
 
 // New creates a new Repository instance for reads.
@@ -51,12 +55,40 @@ func (r *Repository) Create(read *model.Read) (*model.Read, error) {
 }
 
 // GetByID retrieves a read by its id (which is a uri to the activity).
+// Will also return the user, and the book and its authors.
 func (r *Repository) GetByID(id string) (result *model.Read, err error) {
 	result = new(model.Read)
-	if err = r.db.Where("id = ?", id).
+	if err = r.db.Preload("User").
+		Preload("Book").
+		Preload("Book.Authors").
+		Where("id = ?", id).
 		First(result).
 		Error; err != nil {
 			return nil, ErrNotFound
 		}
 	return
 }
+
+// Delete removes a read.
+func (r *Repository) Delete(read *model.Read) error {
+	if err := r.db.Unscoped().
+		Where("id = ?", read.ID).
+		Delete(&model.Read{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
+
+// CountLocal returns how many reads have been logged by users of this server.
+func (r *Repository) CountLocal() (int, error) {
+	var count int
+	if err := r.db.Model(&model.Read{}).
+		Joins("JOIN users ON users.id = reads.user_id").
+		Where("users.local = ?", true).
+		Count(&count).
+		Error; err != nil {
+		return 0, ErrStorage
+	}
+	return count, nil
+}
@@ -116,8 +116,8 @@ func TestCreate(t *testing.T) {
 	conn, mock, _ := sqlmock.New()
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"reads\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"book_id\",\"user_id\") VALUES ($1,$2,$3,$4,$5,$6) RETURNING \"reads\".\"id\"")+"$").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "c9f8e9ce-f4bf-4770-88c3-6c5cfe541734", "/works/OL20473909W", "b3032140-e824-4b39-9be2-47e99f383f2b").
+	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"reads\" (\"id\",\"created_at\",\"updated_at\",\"deleted_at\",\"book_id\",\"user_id\",\"followers_only\") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING \"reads\".\"id\"")+"$").
+		WithArgs("c9f8e9ce-f4bf-4770-88c3-6c5cfe541734", sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "/works/OL20473909W", "b3032140-e824-4b39-9be2-47e99f383f2b", false).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1f3325e2-ee0d-478f-aecc-122235d7a6ce"))
 	mock.ExpectCommit()
 
@@ -126,9 +126,7 @@ func TestCreate(t *testing.T) {
 	repo := New(db)
 
 	read, err := repo.Create(&model.Read{
-		Base: model.Base{
-			ID: uuid.MustParse("c9f8e9ce-f4bf-4770-88c3-6c5cfe541734"),
-		},
+		ID: "c9f8e9ce-f4bf-4770-88c3-6c5cfe541734",
 		Book: model.Book{
 			OpenLibraryID: "/works/OL20473909W",
 		},
@@ -151,8 +149,8 @@ func TestCreate_Error(t *testing.T) {
 	conn, mock, _ := sqlmock.New()
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"reads\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"book_id\",\"user_id\") VALUES ($1,$2,$3,$4,$5,$6) RETURNING \"reads\".\"id\"")+"$").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "c9f8e9ce-f4bf-4770-88c3-6c5cfe541734", "/works/OL20473909W", "b3032140-e824-4b39-9be2-47e99f383f2b").
+	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"reads\" (\"id\",\"created_at\",\"updated_at\",\"deleted_at\",\"book_id\",\"user_id\",\"followers_only\") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING \"reads\".\"id\"")+"$").
+		WithArgs("c9f8e9ce-f4bf-4770-88c3-6c5cfe541734", sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "/works/OL20473909W", "b3032140-e824-4b39-9be2-47e99f383f2b", false).
 		WillReturnError(fmt.Errorf("could not update"))
 	mock.ExpectRollback()
 
@@ -161,9 +159,7 @@ func TestCreate_Error(t *testing.T) {
 	repo := New(db)
 
 	read, err := repo.Create(&model.Read{
-		Base: model.Base{
-			ID: uuid.MustParse("c9f8e9ce-f4bf-4770-88c3-6c5cfe541734"),
-		},
+		ID: "c9f8e9ce-f4bf-4770-88c3-6c5cfe541734",
 		Book: model.Book{
 			OpenLibraryID: "/works/OL20473909W",
 		},
@@ -181,3 +177,51 @@ func TestCreate_Error(t *testing.T) {
 	assert.True(t, errors.Is(err, ErrNotCreated))
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestDelete(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"reads\"  WHERE (id = $1)") + "$").
+		WithArgs("https://exlibris.example/user/bob/read/10698c21-f094-4a83-8ec7-3221fa9e806e").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Delete(&model.Read{
+		ID: "https://exlibris.example/user/bob/read/10698c21-f094-4a83-8ec7-3221fa9e806e",
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountLocal(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT count(*) FROM \"reads\" JOIN users ON users.id = reads.user_id WHERE \"reads\".\"deleted_at\" IS NULL AND ((users.local = $1))") + "$").
+		WithArgs(true).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	count, err := repo.CountLocal()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 12, count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountLocal_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT count(*) FROM \"reads\"")).
+		WillReturnError(fmt.Errorf("oops"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	count, err := repo.CountLocal()
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, count)
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,102 @@
+// Package relays contains the repository for the ActivityPub relays this server is subscribed to.
+package relays
+
+import (
+	"errors"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrNotFound is returned when a record cannot be found.
+	ErrNotFound = errors.New("relay could not be found")
+	// ErrNotSaved is returned when a record cannot be saved.
+	ErrNotSaved = errors.New("relay could not be saved")
+	// ErrNotDeleted is returned when a record cannot be deleted.
+	ErrNotDeleted = errors.New("relay could not be deleted")
+	// ErrStorage is returned when an unknown storage issue occurs.
+	ErrStorage = errors.New("error with storage")
+)
+
+// New creates a new Repository instance for relays.
+func New(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Repository is used for querying and saving relays.
+type Repository struct {
+	db *gorm.DB
+}
+
+// Get returns the relay with the given id.
+func (r *Repository) Get(id uuid.UUID) (*model.Relay, error) {
+	return r.first("id = ?", id)
+}
+
+// GetByInbox returns the relay with the given inbox.
+func (r *Repository) GetByInbox(inbox string) (*model.Relay, error) {
+	return r.first("inbox = ?", inbox)
+}
+
+// GetByFollowID returns the relay the instance sent the Follow with the given IRI to.
+func (r *Repository) GetByFollowID(followID string) (*model.Relay, error) {
+	return r.first("follow_id = ?", followID)
+}
+
+// GetByActor returns the relay with the given actor IRI.
+func (r *Repository) GetByActor(actorIRI string) (*model.Relay, error) {
+	return r.first("actor_iri = ?", actorIRI)
+}
+
+// first returns the first relay matching a condition.
+func (r *Repository) first(query string, arg interface{}) (*model.Relay, error) {
+	var relay model.Relay
+	if err := r.db.Where(query, arg).First(&relay).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrStorage
+	}
+	return &relay, nil
+}
+
+// List returns every relay, oldest first.
+func (r *Repository) List() ([]model.Relay, error) {
+	var relays []model.Relay
+	if err := r.db.Order("created_at asc").Find(&relays).Error; err != nil {
+		return nil, ErrStorage
+	}
+	return relays, nil
+}
+
+// Publishing returns the relays that accepted the instance's follow and are sent our public reads.
+func (r *Repository) Publishing() ([]model.Relay, error) {
+	var relays []model.Relay
+	if err := r.db.Where("status = ? AND publish = ?", model.RelayAccepted, true).
+		Order("created_at asc").
+		Find(&relays).
+		Error; err != nil {
+		return nil, ErrStorage
+	}
+	return relays, nil
+}
+
+// Save creates or updates a relay.
+func (r *Repository) Save(relay *model.Relay) error {
+	if err := r.db.Save(relay).Error; err != nil {
+		return ErrNotSaved
+	}
+	return nil
+}
+
+// Delete removes a relay.
+func (r *Repository) Delete(relay *model.Relay) error {
+	if err := r.db.Where("id = ?", relay.ID).
+		Delete(&model.Relay{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
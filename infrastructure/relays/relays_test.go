@@ -0,0 +1,199 @@
+package relays
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+var id = uuid.MustParse("6d1e0a4c-54a1-4d63-a3c4-3f1b6c1a4e0f")
+
+func rows() *sqlmock.Rows {
+	ts := time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	return sqlmock.NewRows([]string{"id", "inbox", "actor_iri", "follow_id", "status", "publish", "created_at", "updated_at"}).
+		AddRow(id, "https://relay.example/inbox", "https://relay.example/actor", "https://exlibris.example/user/exlibris.example/follow/1", model.RelayAccepted, true, ts, ts)
+}
+
+func TestGet(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"relays\"  WHERE (id = $1) ORDER BY \"relays\".\"id\" ASC LIMIT 1") + "$").
+		WithArgs(id).
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	relay, err := repo.Get(id)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, relay) {
+		assert.Equal(t, "https://relay.example/inbox", relay.Inbox)
+		assert.True(t, relay.Accepted())
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_ErrNotFound(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"relays\"")).
+		WillReturnError(gorm.ErrRecordNotFound)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	relay, err := repo.Get(id)
+
+	assert.Nil(t, relay)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"relays\"")).
+		WillReturnError(fmt.Errorf("oops"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	relay, err := repo.Get(id)
+
+	assert.Nil(t, relay)
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByFollowID(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"relays\"  WHERE (follow_id = $1) ORDER BY \"relays\".\"id\" ASC LIMIT 1") + "$").
+		WithArgs("https://exlibris.example/user/exlibris.example/follow/1").
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	relay, err := repo.GetByFollowID("https://exlibris.example/user/exlibris.example/follow/1")
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, relay) {
+		assert.Equal(t, id, relay.ID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByActor(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"relays\"  WHERE (actor_iri = $1) ORDER BY \"relays\".\"id\" ASC LIMIT 1") + "$").
+		WithArgs("https://relay.example/actor").
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	relay, err := repo.GetByActor("https://relay.example/actor")
+
+	assert.NoError(t, err)
+	assert.NotNil(t, relay)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestList(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"relays\"   ORDER BY created_at asc") + "$").
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	relays, err := repo.List()
+
+	assert.NoError(t, err)
+	assert.Len(t, relays, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPublishing(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"relays\"  WHERE (status = $1 AND publish = $2) ORDER BY created_at asc")+"$").
+		WithArgs(model.RelayAccepted, true).
+		WillReturnRows(rows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	relays, err := repo.Publishing()
+
+	assert.NoError(t, err)
+	if assert.Len(t, relays, 1) {
+		assert.True(t, relays[0].Publish)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSave(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("UPDATE \"relays\" SET")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Save(&model.Relay{
+		ID:       id,
+		Inbox:    "https://relay.example/inbox",
+		FollowID: "https://exlibris.example/user/exlibris.example/follow/1",
+		Status:   model.RelayPending,
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSave_ErrNotSaved(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("UPDATE \"relays\" SET")).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Save(&model.Relay{ID: id})
+
+	assert.True(t, errors.Is(err, ErrNotSaved))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"relays\"  WHERE (id = $1)") + "$").
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Delete(&model.Relay{ID: id})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete_ErrNotDeleted(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"relays\"  WHERE (id = $1)") + "$").
+		WithArgs(id).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Delete(&model.Relay{ID: id})
+
+	assert.True(t, errors.Is(err, ErrNotDeleted))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,137 @@
+// Package replies contains the repository for the discussion threads under reviews.
+package replies
+
+import (
+	"errors"
+	"log"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrNotFound is returned when a record cannot be found.
+	ErrNotFound = errors.New("reply could not be found")
+	// ErrNotCreated is returned when a record cannot be created.
+	ErrNotCreated = errors.New("reply could not be created")
+	// ErrNotSaved is returned when a record cannot be saved.
+	ErrNotSaved = errors.New("reply could not be saved")
+	// ErrNotDeleted is returned when a record cannot be deleted.
+	ErrNotDeleted = errors.New("reply could not be deleted")
+	// ErrStorage is returned when an unknown storage issue occurs.
+	ErrStorage = errors.New("error with storage")
+)
+
+// New creates a new Repository instance for replies.
+func New(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Repository is used for querying and saving replies to reviews.
+type Repository struct {
+	db *gorm.DB
+}
+
+// GetByID returns a reply by its ID.
+// Preloads the User object.
+func (r *Repository) GetByID(id uuid.UUID) (*model.Reply, error) {
+	var reply model.Reply
+	if err := r.db.Preload("User").
+		Where("id = ?", id).
+		First(&reply).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		log.Printf("error getting reply %s: %s", id, err.Error())
+		return nil, ErrStorage
+	}
+	return &reply, nil
+}
+
+// GetByURI returns a reply from another server by its ActivityPub id.
+// Preloads the User object.
+func (r *Repository) GetByURI(uri string) (*model.Reply, error) {
+	var reply model.Reply
+	if err := r.db.Preload("User").
+		Where("uri = ?", uri).
+		First(&reply).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		log.Printf("error getting reply %s: %s", uri, err.Error())
+		return nil, ErrStorage
+	}
+	return &reply, nil
+}
+
+// ListByReviews returns the replies in the threads under the given reviews, oldest first.
+// Preloads the User object.
+func (r *Repository) ListByReviews(reviewIDs []uuid.UUID) ([]model.Reply, error) {
+	var replies []model.Reply
+	if len(reviewIDs) == 0 {
+		return replies, nil
+	}
+	if err := r.db.Preload("User").
+		Where("review_id IN (?)", reviewIDs).
+		Order("created_at asc").
+		Find(&replies).Error; err != nil {
+		return nil, ErrStorage
+	}
+	return replies, nil
+}
+
+// ListByInReplyTo returns the direct replies to the review or reply with the given IRI, oldest first.
+// Preloads the User object.
+func (r *Repository) ListByInReplyTo(iri string) ([]model.Reply, error) {
+	var replies []model.Reply
+	if err := r.db.Preload("User").
+		Where("in_reply_to = ?", iri).
+		Order("created_at asc").
+		Find(&replies).Error; err != nil {
+		return nil, ErrStorage
+	}
+	return replies, nil
+}
+
+// ListByUser returns every reply a user has written.
+// Preloads the User object.
+func (r *Repository) ListByUser(user *model.User) ([]model.Reply, error) {
+	var replies []model.Reply
+	if err := r.db.Preload("User").
+		Where("user_id = ?", user.ID).
+		Find(&replies).Error; err != nil {
+		return nil, ErrStorage
+	}
+	return replies, nil
+}
+
+// Create saves a new reply. Its user and review must already exist.
+func (r *Repository) Create(reply *model.Reply) error {
+	if err := r.db.Create(reply).Error; err != nil {
+		log.Printf("error creating reply %s: %s", reply.ID, err.Error())
+		return ErrNotCreated
+	}
+	return nil
+}
+
+// Save updates an existing reply.
+func (r *Repository) Save(reply *model.Reply) error {
+	if err := r.db.Save(reply).Error; err != nil {
+		log.Printf("error saving reply %s: %s", reply.ID, err.Error())
+		return ErrNotSaved
+	}
+	return nil
+}
+
+// Delete removes a reply. Replies to it are kept, but drop out of the thread.
+func (r *Repository) Delete(reply *model.Reply) error {
+	if err := r.db.Unscoped().
+		Where("id = ?", reply.ID).
+		Delete(&model.Reply{}).
+		Error; err != nil {
+		log.Printf("error deleting reply %s: %s", reply.ID, err.Error())
+		return ErrNotDeleted
+	}
+	return nil
+}
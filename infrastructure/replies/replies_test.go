@@ -0,0 +1,205 @@
+package replies
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	replyID  = uuid.MustParse("5c3b2f7e-0a8f-4b8e-9d55-2f0c5a1e7b61")
+	reviewID = uuid.MustParse("10698c21-f094-4a83-8ec7-3221fa9e806e")
+	userID   = uuid.MustParse("b3032140-e824-4b39-9be2-47e99f383f2b")
+)
+
+const reviewIRI = "https://exlibris.example/user/bob/review/10698c21-f094-4a83-8ec7-3221fa9e806e"
+
+func repliesRows() *sqlmock.Rows {
+	ts := time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	return sqlmock.NewRows([]string{"created_at", "updated_at", "deleted_at", "id", "uri", "review_id", "in_reply_to", "in_reply_to_author", "user_id", "text"}).
+		AddRow(ts, ts, nil, replyID, "https://mastodon.example/users/alice/statuses/1", reviewID, reviewIRI, "https://exlibris.example/user/bob", userID, "I loved it though")
+}
+
+func usersRows() *sqlmock.Rows {
+	ts := time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	return sqlmock.NewRows([]string{"created_at", "updated_at", "deleted_at", "id", "human_id", "username", "email", "display_name"}).
+		AddRow(ts, ts, nil, userID, "https://mastodon.example/users/alice", "alice@mastodon.example", "", "Alice")
+}
+
+func TestGetByURI(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"replies\"  WHERE \"replies\".\"deleted_at\" IS NULL AND ((uri = $1)) ORDER BY \"replies\".\"id\" ASC LIMIT 1") + "$").
+		WithArgs("https://mastodon.example/users/alice/statuses/1").
+		WillReturnRows(repliesRows())
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"users\"  WHERE \"users\".\"deleted_at\" IS NULL AND ((\"id\" IN ($1))) ORDER BY \"users\".\"id\" ASC") + "$").
+		WithArgs(userID).
+		WillReturnRows(usersRows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	reply, err := repo.GetByURI("https://mastodon.example/users/alice/statuses/1")
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, reply) {
+		assert.Equal(t, reviewIRI, reply.InReplyTo)
+		assert.Equal(t, "Alice", reply.User.DisplayName)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByID_ErrNotFound(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"replies\"  WHERE \"replies\".\"deleted_at\" IS NULL AND ((id = $1)) ORDER BY \"replies\".\"id\" ASC LIMIT 1") + "$").
+		WithArgs(replyID).
+		WillReturnError(gorm.ErrRecordNotFound)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	reply, err := repo.GetByID(replyID)
+
+	assert.Nil(t, reply)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListByReviews(t *testing.T) {
+	other := uuid.MustParse("9f9fc264-bea2-4faf-b3ad-c68dd272bae6")
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"replies\"  WHERE \"replies\".\"deleted_at\" IS NULL AND ((review_id IN ($1,$2))) ORDER BY created_at asc")+"$").
+		WithArgs(reviewID, other).
+		WillReturnRows(repliesRows())
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"users\"")).
+		WillReturnRows(usersRows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	list, err := repo.ListByReviews([]uuid.UUID{reviewID, other})
+
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListByReviews_Empty(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	list, err := repo.ListByReviews(nil)
+
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListByInReplyTo(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"replies\"  WHERE \"replies\".\"deleted_at\" IS NULL AND ((in_reply_to = $1)) ORDER BY created_at asc") + "$").
+		WithArgs(reviewIRI).
+		WillReturnRows(repliesRows())
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"users\"")).
+		WillReturnRows(usersRows())
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	list, err := repo.ListByInReplyTo(reviewIRI)
+
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListByUser_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"replies\"  WHERE \"replies\".\"deleted_at\" IS NULL AND ((user_id = $1))") + "$").
+		WithArgs(userID).
+		WillReturnError(fmt.Errorf("oops"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	list, err := repo.ListByUser(&model.User{
+		Base: model.Base{
+			ID: userID,
+		},
+	})
+
+	assert.Nil(t, list)
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreate(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"replies\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"uri\",\"review_id\",\"in_reply_to\",\"in_reply_to_author\",\"user_id\",\"text\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING \"replies\".\"id\"")+"$").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, replyID, "", reviewID, reviewIRI, "https://exlibris.example/user/bob", userID, "same").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(replyID))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Create(&model.Reply{
+		Base: model.Base{
+			ID: replyID,
+		},
+		ReviewID:        reviewID,
+		InReplyTo:       reviewIRI,
+		InReplyToAuthor: "https://exlibris.example/user/bob",
+		UserID:          userID,
+		Text:            "same",
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSave(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("UPDATE \"replies\" SET")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Save(&model.Reply{
+		Base: model.Base{
+			ID: replyID,
+		},
+		ReviewID:  reviewID,
+		InReplyTo: reviewIRI,
+		UserID:    userID,
+		Text:      "edited",
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"replies\"  WHERE (id = $1)") + "$").
+		WithArgs(replyID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Delete(&model.Reply{
+		Base: model.Base{
+			ID: replyID,
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -16,6 +16,10 @@ var (
 	ErrNotFound = errors.New("reviews could not be found for book")
 	// ErrNotCreated is returned when a record cannot be created.
 	ErrNotCreated = errors.New("review for book could not be created")
+	// ErrNotSaved is returned when a record cannot be saved.
+	ErrNotSaved = errors.New("review could not be saved")
+	// ErrNotDeleted is returned when a record cannot be deleted.
+	ErrNotDeleted = errors.New("review could not be deleted")
 	// ErrStorage is returned when an unknown storage issue occurs.
 	ErrStorage = errors.New("could not retrieve data")
 )
@@ -42,9 +46,51 @@ func (r *Repository) GetReviews(book *model.Book) ([]model.Review, error) {
 	return reviews, nil
 }
 
+// GetByID returns a local review by its ID.
+// Preloads the User and Book objects.
+func (r *Repository) GetByID(id uuid.UUID) (*model.Review, error) {
+	var review model.Review
+	if err := r.db.Preload("User").
+		Preload("Book").
+		Where("id = ?", id).
+		First(&review).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		log.Printf("error getting review %s: %s", id, err.Error())
+		return nil, ErrStorage
+	}
+	return &review, nil
+}
+
+// GetByURI returns a review from another server by its ActivityPub id.
+// Preloads the User and Book objects.
+func (r *Repository) GetByURI(uri string) (*model.Review, error) {
+	var review model.Review
+	if err := r.db.Preload("User").
+		Preload("Book").
+		Where("uri = ?", uri).
+		First(&review).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		log.Printf("error getting review %s: %s", uri, err.Error())
+		return nil, ErrStorage
+	}
+	return &review, nil
+}
+
+// Create saves a review from another server. Its user and book must already exist.
+func (r *Repository) Create(review *model.Review) error {
+	if err := r.db.Create(review).Error; err != nil {
+		log.Printf("error creating review %s: %s", review.URI, err.Error())
+		return ErrNotCreated
+	}
+	return nil
+}
+
 // CreateReview will create a new review for a given book.
-func (r *Repository) CreateReview(user *model.User, book *model.Book, text string, rating int) (*model.Review, error) {
-	// @TODO: rating
+func (r *Repository) CreateReview(user *model.User, book *model.Book, text, spoiler string, rating int) (*model.Review, error) {
 	book, err := books.New(r.db).GetByID(book.OpenLibraryID)
 	if err != nil {
 		if errors.Is(err, books.ErrNotFound) {
@@ -58,11 +104,13 @@ func (r *Repository) CreateReview(user *model.User, book *model.Book, text strin
 		Base: model.Base{
 			ID: uuid.New(),
 		},
-		Book:   *book,
-		BookID: book.OpenLibraryID,
-		Text:   text,
-		User:   *user,
-		UserID: user.ID,
+		Book:    *book,
+		BookID:  book.OpenLibraryID,
+		Text:    text,
+		Spoiler: spoiler,
+		Rating:  rating,
+		User:    *user,
+		UserID:  user.ID,
 	}
 
 	if result := r.db.Create(&review); result.Error != nil {
@@ -71,3 +119,51 @@ func (r *Repository) CreateReview(user *model.User, book *model.Book, text strin
 
 	return review, nil
 }
+
+// ListByUser returns every review a user has written.
+// Preloads the User and Book objects.
+func (r *Repository) ListByUser(user *model.User) ([]model.Review, error) {
+	var reviews []model.Review
+	if err := r.db.Preload("User").
+		Preload("Book").
+		Where("user_id = ?", user.ID).
+		Find(&reviews).Error; err != nil {
+		return nil, ErrStorage
+	}
+	return reviews, nil
+}
+
+// Save updates an existing review.
+func (r *Repository) Save(review *model.Review) error {
+	if err := r.db.Save(review).Error; err != nil {
+		log.Printf("error saving review %s: %s", review.ID, err.Error())
+		return ErrNotSaved
+	}
+	return nil
+}
+
+// Delete removes a review.
+func (r *Repository) Delete(review *model.Review) error {
+	if err := r.db.Unscoped().
+		Where("id = ?", review.ID).
+		Delete(&model.Review{}).
+		Error; err != nil {
+		log.Printf("error deleting review %s: %s", review.ID, err.Error())
+		return ErrNotDeleted
+	}
+	return nil
+}
+
+// CountLocal returns how many reviews have been written by users of this server.
+func (r *Repository) CountLocal() (int, error) {
+	var count int
+	if err := r.db.Model(&model.Review{}).
+		Joins("JOIN users ON users.id = reviews.user_id").
+		Where("users.local = ?", true).
+		Count(&count).
+		Error; err != nil {
+		log.Printf("error counting local reviews: %s", err.Error())
+		return 0, ErrStorage
+	}
+	return count, nil
+}
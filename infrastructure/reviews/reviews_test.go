@@ -107,8 +107,8 @@ func TestCreateReview(t *testing.T) {
 		WithArgs("/works/OL20473909W").
 		WillReturnRows(bookAuthorsRows)
 	mock.ExpectBegin()
-	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"reviews\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"book_id\",\"user_id\",\"text\") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING \"reviews\".\"id\"")+"$").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg(), "/works/OL20473909W", "b3032140-e824-4b39-9be2-47e99f383f2b", "text").
+	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"reviews\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"uri\",\"book_id\",\"user_id\",\"text\",\"spoiler\",\"rating\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING \"reviews\".\"id\"")+"$").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg(), "", "/works/OL20473909W", "b3032140-e824-4b39-9be2-47e99f383f2b", "text", "", 5).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("10698c21-f094-4a83-8ec7-3221fa9e806e"))
 	mock.ExpectCommit()
 
@@ -125,7 +125,7 @@ func TestCreateReview(t *testing.T) {
 		DisplayName: "guardianBob",
 	}, &model.Book{
 		OpenLibraryID: "/works/OL20473909W",
-	}, "text", 5)
+	}, "text", "", 5)
 	assert.NoError(t, err)
 	assert.NotNil(t, review)
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -151,7 +151,7 @@ func TestCreateReview_ErrNotFound(t *testing.T) {
 		DisplayName: "guardianBob",
 	}, &model.Book{
 		OpenLibraryID: "/works/OL20473909W",
-	}, "text", 5)
+	}, "text", "", 5)
 	assert.Error(t, err)
 	assert.Nil(t, review)
 	assert.True(t, errors.Is(err, ErrNotFound))
@@ -177,7 +177,7 @@ func TestCreateReview_ErrStorage(t *testing.T) {
 		DisplayName: "guardianBob",
 	}, &model.Book{
 		OpenLibraryID: "/works/OL20473909W",
-	}, "text", 5)
+	}, "text", "", 5)
 	assert.Error(t, err)
 	assert.Nil(t, review)
 	assert.True(t, errors.Is(err, ErrStorage))
@@ -197,8 +197,8 @@ func TestCreateReview_ErrNotCreated(t *testing.T) {
 		WithArgs("/works/OL20473909W").
 		WillReturnRows(bookAuthorsRows)
 	mock.ExpectBegin()
-	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"reviews\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"book_id\",\"user_id\",\"text\") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING \"reviews\".\"id\"")+"$").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg(), "/works/OL20473909W", "b3032140-e824-4b39-9be2-47e99f383f2b", "text").
+	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"reviews\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"uri\",\"book_id\",\"user_id\",\"text\",\"spoiler\",\"rating\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING \"reviews\".\"id\"")+"$").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg(), "", "/works/OL20473909W", "b3032140-e824-4b39-9be2-47e99f383f2b", "text", "", 5).
 		WillReturnError(fmt.Errorf("oops"))
 	mock.ExpectRollback()
 
@@ -215,10 +215,185 @@ func TestCreateReview_ErrNotCreated(t *testing.T) {
 		DisplayName: "guardianBob",
 	}, &model.Book{
 		OpenLibraryID: "/works/OL20473909W",
-	}, "text", 5)
+	}, "text", "", 5)
 	assert.Error(t, err)
 	assert.Nil(t, review)
 	assert.True(t, errors.Is(err, ErrNotCreated))
 	assert.NoError(t, mock.ExpectationsWereMet())
 
 }
+
+func TestGetByURI(t *testing.T) {
+	setup()
+	defer teardown()
+	ts := time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"reviews\"  WHERE \"reviews\".\"deleted_at\" IS NULL AND ((uri = $1)) ORDER BY \"reviews\".\"id\" ASC LIMIT 1") + "$").
+		WithArgs("https://elsewhere.example/user/alice/review/1").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "deleted_at", "id", "uri", "book_id", "user_id", "text", "spoiler", "rating"}).
+			AddRow(ts, ts, nil, "10698c21-f094-4a83-8ec7-3221fa9e806e", "https://elsewhere.example/user/alice/review/1", "/works/OL20473909W", "b3032140-e824-4b39-9be2-47e99f383f2b", "I had a hard time with this book", "", 2))
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"users\"  WHERE \"users\".\"deleted_at\" IS NULL AND ((\"id\" IN ($1))) ORDER BY \"users\".\"id\" ASC") + "$").
+		WithArgs("b3032140-e824-4b39-9be2-47e99f383f2b").
+		WillReturnRows(usersRows)
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"books\"  WHERE \"books\".\"deleted_at\" IS NULL AND ((\"open_library_id\" IN ($1))) ORDER BY \"books\".\"open_library_id\" ASC") + "$").
+		WithArgs("/works/OL20473909W").
+		WillReturnRows(booksRows)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	review, err := repo.GetByURI("https://elsewhere.example/user/alice/review/1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, review.Rating)
+	assert.Equal(t, "bob", review.User.Username)
+	assert.Equal(t, "This Is How You Lose the Time War", review.Book.Title)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByURI_ErrNotFound(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"reviews\"  WHERE \"reviews\".\"deleted_at\" IS NULL AND ((uri = $1)) ORDER BY \"reviews\".\"id\" ASC LIMIT 1") + "$").
+		WithArgs("https://elsewhere.example/user/alice/review/1").
+		WillReturnError(gorm.ErrRecordNotFound)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	review, err := repo.GetByURI("https://elsewhere.example/user/alice/review/1")
+
+	assert.Nil(t, review)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByID_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"reviews\"  WHERE \"reviews\".\"deleted_at\" IS NULL AND ((id = $1)) ORDER BY \"reviews\".\"id\" ASC LIMIT 1") + "$").
+		WithArgs(uuid.MustParse("10698c21-f094-4a83-8ec7-3221fa9e806e")).
+		WillReturnError(fmt.Errorf("oops"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	review, err := repo.GetByID(uuid.MustParse("10698c21-f094-4a83-8ec7-3221fa9e806e"))
+
+	assert.Nil(t, review)
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreate(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"reviews\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"uri\",\"book_id\",\"user_id\",\"text\",\"spoiler\",\"rating\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING \"reviews\".\"id\"")+"$").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg(), "https://elsewhere.example/user/alice/review/1", "/works/OL20473909W", "b3032140-e824-4b39-9be2-47e99f383f2b", "text", "it ends", 4).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("10698c21-f094-4a83-8ec7-3221fa9e806e"))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Create(&model.Review{
+		Base: model.Base{
+			ID: uuid.MustParse("10698c21-f094-4a83-8ec7-3221fa9e806e"),
+		},
+		URI:     "https://elsewhere.example/user/alice/review/1",
+		BookID:  "/works/OL20473909W",
+		UserID:  uuid.MustParse("b3032140-e824-4b39-9be2-47e99f383f2b"),
+		Text:    "text",
+		Spoiler: "it ends",
+		Rating:  4,
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListByUser_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"reviews\"  WHERE \"reviews\".\"deleted_at\" IS NULL AND ((user_id = $1))") + "$").
+		WithArgs(uuid.MustParse("b3032140-e824-4b39-9be2-47e99f383f2b")).
+		WillReturnError(fmt.Errorf("oops"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	reviews, err := repo.ListByUser(&model.User{
+		Base: model.Base{
+			ID: uuid.MustParse("b3032140-e824-4b39-9be2-47e99f383f2b"),
+		},
+	})
+
+	assert.Nil(t, reviews)
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSave(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("UPDATE \"reviews\" SET")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Save(&model.Review{
+		Base: model.Base{
+			ID: uuid.MustParse("10698c21-f094-4a83-8ec7-3221fa9e806e"),
+		},
+		BookID: "/works/OL20473909W",
+		UserID: uuid.MustParse("b3032140-e824-4b39-9be2-47e99f383f2b"),
+		Text:   "better the second time",
+		Rating: 5,
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"reviews\"  WHERE (id = $1)") + "$").
+		WithArgs(uuid.MustParse("10698c21-f094-4a83-8ec7-3221fa9e806e")).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Delete(&model.Review{
+		Base: model.Base{
+			ID: uuid.MustParse("10698c21-f094-4a83-8ec7-3221fa9e806e"),
+		},
+	})
+
+	assert.True(t, errors.Is(err, ErrNotDeleted))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountLocal(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT count(*) FROM \"reviews\" JOIN users ON users.id = reviews.user_id WHERE \"reviews\".\"deleted_at\" IS NULL AND ((users.local = $1))") + "$").
+		WithArgs(true).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	count, err := repo.CountLocal()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 12, count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountLocal_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT count(*) FROM \"reviews\"")).
+		WillReturnError(fmt.Errorf("oops"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	count, err := repo.CountLocal()
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, count)
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,48 @@
+// Package tombstones contains the repository for the IRIs of deleted local objects.
+package tombstones
+
+import (
+	"errors"
+
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrNotFound is returned when a record cannot be found.
+	ErrNotFound = errors.New("tombstone could not be found")
+	// ErrNotSaved is returned when a record cannot be saved.
+	ErrNotSaved = errors.New("tombstone could not be saved")
+	// ErrStorage is returned when an unknown storage issue occurs.
+	ErrStorage = errors.New("error with storage")
+)
+
+// New creates a new Repository instance for tombstones.
+func New(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Repository is used for querying and saving tombstones.
+type Repository struct {
+	db *gorm.DB
+}
+
+// Get returns the tombstone of the object that had the given IRI.
+func (r *Repository) Get(iri string) (*model.Tombstone, error) {
+	var tombstone model.Tombstone
+	if err := r.db.Where("id = ?", iri).First(&tombstone).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrStorage
+	}
+	return &tombstone, nil
+}
+
+// Create saves a tombstone.
+func (r *Repository) Create(tombstone *model.Tombstone) error {
+	if err := r.db.Create(tombstone).Error; err != nil {
+		return ErrNotSaved
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package tombstones
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/exlibris-fed/exlibris/model"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	ts := time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"tombstones\"  WHERE (id = $1) ORDER BY \"tombstones\".\"id\" ASC LIMIT 1") + "$").
+		WithArgs("https://exlibris.example/user/bob/review/10698c21-f094-4a83-8ec7-3221fa9e806e").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "former_type", "created_at"}).
+			AddRow("https://exlibris.example/user/bob/review/10698c21-f094-4a83-8ec7-3221fa9e806e", "Note", ts))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	tombstone, err := repo.Get("https://exlibris.example/user/bob/review/10698c21-f094-4a83-8ec7-3221fa9e806e")
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, tombstone) {
+		assert.Equal(t, "Note", tombstone.FormerType)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_ErrNotFound(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"tombstones\"")).
+		WillReturnError(gorm.ErrRecordNotFound)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	tombstone, err := repo.Get("https://exlibris.example/user/bob")
+
+	assert.Nil(t, tombstone)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreate(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"tombstones\" (\"id\",\"former_type\",\"created_at\") VALUES ($1,$2,$3) RETURNING \"tombstones\".\"id\"")+"$").
+		WithArgs("https://exlibris.example/user/bob", "Person", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("https://exlibris.example/user/bob"))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Create(&model.Tombstone{
+		ID:         "https://exlibris.example/user/bob",
+		FormerType: "Person",
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreate_ErrNotSaved(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("INSERT INTO \"tombstones\"")).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Create(&model.Tombstone{
+		ID:         "https://exlibris.example/user/bob",
+		FormerType: "Person",
+	})
+
+	assert.True(t, errors.Is(err, ErrNotSaved))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
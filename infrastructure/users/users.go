@@ -4,6 +4,7 @@ package users
 import (
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/exlibris-fed/exlibris/infrastructure/registrationkeys"
 	"github.com/exlibris-fed/exlibris/model"
@@ -20,6 +21,8 @@ var (
 	ErrStorage = errors.New("error with storage")
 	// ErrDuplicate occurs when the user already exists.
 	ErrDuplicate = errors.New("user already exists")
+	// ErrNotDeleted is returned when a record cannot be deleted.
+	ErrNotDeleted = errors.New("user could not be deleted")
 )
 
 // New creates a new Repository instance for users.
@@ -36,10 +39,10 @@ type Repository struct {
 	keyRepo *registrationkeys.Repository
 }
 
-// GetByUsername returns a User object given a username. It does not fill in any related objects via `Preload`.
+// GetByUsername returns a local User object given a username, in any case, since actor IRIs lowercase it. Remote users, whose usernames include their server, are never returned. It does not fill in any related objects via `Preload`.
 func (r *Repository) GetByUsername(name string) (*model.User, error) {
 	var user model.User
-	result := r.db.Where("username = ?", name).First(&user)
+	result := r.db.Where("LOWER(username) = LOWER(?) AND local = ?", name, true).First(&user)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, ErrNotFound
@@ -49,10 +52,36 @@ func (r *Repository) GetByUsername(name string) (*model.User, error) {
 	return &user, nil
 }
 
-// GetByUsernameWithFollowers returns a User object given a username. It includes their list of followers.
+// GetByIRI returns a remote User object given the id of their actor.
+func (r *Repository) GetByIRI(iri string) (*model.User, error) {
+	var user model.User
+	result := r.db.Where("human_id = ? AND local = ?", iri, false).First(&user)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrStorage
+	}
+	return &user, nil
+}
+
+// GetByUsernameWithFollowers returns a local User object given a username. It includes their list of followers, but not pending follow requests.
 func (r *Repository) GetByUsernameWithFollowers(name string) (*model.User, error) {
 	var user model.User
-	result := r.db.Preload("Followers").Where("username = ?", name).First(&user)
+	result := r.db.Preload("Followers", "pending = ?", false).Where("LOWER(username) = LOWER(?) AND local = ?", name, true).First(&user)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, ErrStorage
+	}
+	return &user, nil
+}
+
+// GetByUsernameWithFollowing returns a local User object given a username. It includes the list of actors they follow.
+func (r *Repository) GetByUsernameWithFollowing(name string) (*model.User, error) {
+	var user model.User
+	result := r.db.Preload("Following", "accepted = ?", true).Where("LOWER(username) = LOWER(?) AND local = ?", name, true).First(&user)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, ErrNotFound
@@ -62,6 +91,58 @@ func (r *Repository) GetByUsernameWithFollowers(name string) (*model.User, error
 	return &user, nil
 }
 
+// HasFollower returns whether the actor with the given IRI follows a user. Pending follow requests don't count.
+func (r *Repository) HasFollower(user *model.User, actorIRI string) (bool, error) {
+	var count int
+	if err := r.db.Model(&model.Follower{}).
+		Where("user_id = ? AND id = ? AND pending = ?", user.ID, actorIRI, false).
+		Count(&count).
+		Error; err != nil {
+		return false, ErrStorage
+	}
+	return count > 0, nil
+}
+
+// CountLocal returns how many verified users have registered on this server.
+func (r *Repository) CountLocal() (int, error) {
+	var count int
+	if err := r.db.Model(&model.User{}).
+		Where("local = ? AND verified = ?", true, true).
+		Count(&count).
+		Error; err != nil {
+		return 0, ErrStorage
+	}
+	return count, nil
+}
+
+// CountActiveLocal returns how many users of this server have logged a read, written a review or replied to one since the given time.
+func (r *Repository) CountActiveLocal(since time.Time) (int, error) {
+	var count int
+	if err := r.db.Model(&model.User{}).
+		Where("local = ? AND verified = ?", true, true).
+		Where("id IN (?) OR id IN (?) OR id IN (?)",
+			r.db.Model(&model.Read{}).Select("user_id").Where("created_at >= ?", since).QueryExpr(),
+			r.db.Model(&model.Review{}).Select("user_id").Where("created_at >= ?", since).QueryExpr(),
+			r.db.Model(&model.Reply{}).Select("user_id").Where("created_at >= ?", since).QueryExpr(),
+		).
+		Count(&count).
+		Error; err != nil {
+		return 0, ErrStorage
+	}
+	return count, nil
+}
+
+// MarkLocal sets the local flag on users registered before remote users were stored, who didn't have it set. Remote users are told apart by their HumanID, which is their actor IRI rather than "domain/@username".
+func (r *Repository) MarkLocal() error {
+	if err := r.db.Model(&model.User{}).
+		Where("local = ? AND human_id NOT LIKE ?", false, "http%").
+		Update("local", true).
+		Error; err != nil {
+		return ErrStorage
+	}
+	return nil
+}
+
 // Create the given user with a registration key.
 func (r *Repository) Create(user *model.User, key *model.RegistrationKey) (*model.User, error) {
 
@@ -125,3 +206,17 @@ func (r *Repository) Activate(id uuid.UUID) error {
 	}
 	return nil
 }
+
+// Delete removes a user. Local users are only marked as deleted, so that their username is never given to someone else and activities can still be signed on their behalf until they've been delivered. Remote users are removed along with everything of theirs.
+func (r *Repository) Delete(user *model.User) error {
+	db := r.db
+	if !user.Local {
+		db = db.Unscoped()
+	}
+	if err := db.Where("id = ?", user.ID).
+		Delete(&model.User{}).
+		Error; err != nil {
+		return ErrNotDeleted
+	}
+	return nil
+}
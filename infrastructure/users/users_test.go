@@ -35,8 +35,8 @@ func TestGetByUsername(t *testing.T) {
 	defer teardown()
 
 	conn, mock, _ := sqlmock.New()
-	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"users\" WHERE \"users\".\"deleted_at\" IS NULL AND ((username = $1)) ORDER BY \"users\".\"id\" ASC LIMIT 1") + "$").
-		WithArgs("bob").
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"users\" WHERE \"users\".\"deleted_at\" IS NULL AND ((LOWER(username) = LOWER($1) AND local = $2)) ORDER BY \"users\".\"id\" ASC LIMIT 1")+"$").
+		WithArgs("bob", true).
 		WillReturnRows(usersRows)
 	db, _ := gorm.Open("postgres", conn)
 
@@ -54,8 +54,8 @@ func TestGetByUsername(t *testing.T) {
 
 func TestGetByUsername_ErrNotFound(t *testing.T) {
 	conn, mock, _ := sqlmock.New()
-	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"users\" WHERE \"users\".\"deleted_at\" IS NULL AND ((username = $1)) ORDER BY \"users\".\"id\" ASC LIMIT 1") + "$").
-		WithArgs("bob").
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"users\" WHERE \"users\".\"deleted_at\" IS NULL AND ((LOWER(username) = LOWER($1) AND local = $2)) ORDER BY \"users\".\"id\" ASC LIMIT 1")+"$").
+		WithArgs("bob", true).
 		WillReturnError(gorm.ErrRecordNotFound)
 	db, _ := gorm.Open("postgres", conn)
 
@@ -70,8 +70,8 @@ func TestGetByUsername_ErrNotFound(t *testing.T) {
 
 func TestGetByUsername_ErrStorage(t *testing.T) {
 	conn, mock, _ := sqlmock.New()
-	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT * FROM \"users\" WHERE \"users\".\"deleted_at\" IS NULL AND ((username = $1)) ORDER BY \"users\".\"id\" ASC LIMIT 1") + "$").
-		WithArgs("bob").
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"users\" WHERE \"users\".\"deleted_at\" IS NULL AND ((LOWER(username) = LOWER($1) AND local = $2)) ORDER BY \"users\".\"id\" ASC LIMIT 1")+"$").
+		WithArgs("bob", true).
 		WillReturnError(fmt.Errorf("oops"))
 	db, _ := gorm.Open("postgres", conn)
 
@@ -90,8 +90,8 @@ func TestCreate(t *testing.T) {
 
 	conn, mock, _ := sqlmock.New()
 	mock.ExpectBegin()
-	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"users\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"human_id\",\"username\",\"display_name\",\"email\",\"password\",\"private_key\",\"summary\",\"local\",\"verified\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13) RETURNING \"users\".\"id\"")+"$").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "b3032140-e824-4b39-9be2-47e99f383f2b", "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true).
+	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"users\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"human_id\",\"username\",\"display_name\",\"email\",\"password\",\"private_key\",\"summary\",\"local\",\"verified\",\"admin\",\"instance\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15) RETURNING \"users\".\"id\"")+"$").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "b3032140-e824-4b39-9be2-47e99f383f2b", "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true, false, false).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("b3032140-e824-4b39-9be2-47e99f383f2b"))
 	mock.ExpectCommit()
 	mock.ExpectBegin()
@@ -141,8 +141,8 @@ func TestCreate_ErrNotCreated_User(t *testing.T) {
 
 	conn, mock, _ := sqlmock.New()
 	mock.ExpectBegin()
-	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"users\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"human_id\",\"username\",\"display_name\",\"email\",\"password\",\"private_key\",\"summary\",\"local\",\"verified\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13) RETURNING \"users\".\"id\"")+"$").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "b3032140-e824-4b39-9be2-47e99f383f2b", "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true).
+	mock.ExpectQuery("^"+regexp.QuoteMeta("INSERT INTO \"users\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"human_id\",\"username\",\"display_name\",\"email\",\"password\",\"private_key\",\"summary\",\"local\",\"verified\",\"admin\",\"instance\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15) RETURNING \"users\".\"id\"")+"$").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "b3032140-e824-4b39-9be2-47e99f383f2b", "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true, false, false).
 		WillReturnError(fmt.Errorf("oops"))
 	mock.ExpectRollback()
 	db, _ := gorm.Open("postgres", conn)
@@ -189,7 +189,7 @@ func TestCreate_ErrNotCreated_Key(t *testing.T) {
 
 	conn, mock, _ := sqlmock.New()
 	mock.ExpectBegin()
-	mock.ExpectQuery("^" + regexp.QuoteMeta("INSERT INTO \"users\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"human_id\",\"username\",\"display_name\",\"email\",\"password\",\"private_key\",\"summary\",\"local\",\"verified\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13) RETURNING \"users\".\"id\"") + "$").
+	mock.ExpectQuery("^" + regexp.QuoteMeta("INSERT INTO \"users\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"human_id\",\"username\",\"display_name\",\"email\",\"password\",\"private_key\",\"summary\",\"local\",\"verified\",\"admin\",\"instance\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15) RETURNING \"users\".\"id\"") + "$").
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("b3032140-e824-4b39-9be2-47e99f383f2b"))
 	mock.ExpectCommit()
 	mock.ExpectBegin()
@@ -240,7 +240,7 @@ func TestCreate_ErrDuplicate(t *testing.T) {
 
 	conn, mock, _ := sqlmock.New()
 	mock.ExpectBegin()
-	mock.ExpectQuery("^" + regexp.QuoteMeta("INSERT INTO \"users\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"human_id\",\"username\",\"display_name\",\"email\",\"password\",\"private_key\",\"summary\",\"local\",\"verified\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13) RETURNING \"users\".\"id\"") + "$").
+	mock.ExpectQuery("^" + regexp.QuoteMeta("INSERT INTO \"users\" (\"created_at\",\"updated_at\",\"deleted_at\",\"id\",\"human_id\",\"username\",\"display_name\",\"email\",\"password\",\"private_key\",\"summary\",\"local\",\"verified\",\"admin\",\"instance\") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15) RETURNING \"users\".\"id\"") + "$").
 		WillReturnError(fmt.Errorf("duplicate key value violates unique constraint"))
 	mock.ExpectRollback()
 	db, _ := gorm.Open("postgres", conn)
@@ -288,8 +288,8 @@ func TestSave(t *testing.T) {
 	db, _ := gorm.Open("postgres", conn)
 	repo := New(db)
 	mock.ExpectBegin()
-	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"users\" SET \"updated_at\" = $1, \"deleted_at\" = $2, \"human_id\" = $3, \"username\" = $4, \"display_name\" = $5, \"email\" = $6, \"password\" = $7, \"private_key\" = $8, \"summary\" = $9, \"local\" = $10, \"verified\" = $11  WHERE \"users\".\"deleted_at\" IS NULL AND \"users\".\"id\" = $12")+"$").
-		WithArgs(sqlmock.AnyArg(), nil, "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true, "b3032140-e824-4b39-9be2-47e99f383f2b").
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"users\" SET \"updated_at\" = $1, \"deleted_at\" = $2, \"human_id\" = $3, \"username\" = $4, \"display_name\" = $5, \"email\" = $6, \"password\" = $7, \"private_key\" = $8, \"summary\" = $9, \"local\" = $10, \"verified\" = $11, \"admin\" = $12, \"manually_approves_followers\" = $13, \"key_version\" = $14, \"instance\" = $15  WHERE \"users\".\"deleted_at\" IS NULL AND \"users\".\"id\" = $16")+"$").
+		WithArgs(sqlmock.AnyArg(), nil, "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true, false, false, 0, false, "b3032140-e824-4b39-9be2-47e99f383f2b").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 	user, err := repo.Save(&model.User{
@@ -321,7 +321,7 @@ func TestSave_ErrNotFound(t *testing.T) {
 	repo := New(db)
 	mock.ExpectBegin()
 	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"users\" SET")).
-		WithArgs(sqlmock.AnyArg(), nil, "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true, "b3032140-e824-4b39-9be2-47e99f383f2b").
+		WithArgs(sqlmock.AnyArg(), nil, "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true, false, false, 0, false, "b3032140-e824-4b39-9be2-47e99f383f2b").
 		WillReturnError(gorm.ErrRecordNotFound)
 	mock.ExpectRollback()
 	user, err := repo.Save(&model.User{
@@ -350,7 +350,7 @@ func TestSave_ErrStorage(t *testing.T) {
 	conn, mock, _ := sqlmock.New()
 	mock.ExpectBegin()
 	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"users\" SET")).
-		WithArgs(sqlmock.AnyArg(), nil, "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true, "b3032140-e824-4b39-9be2-47e99f383f2b").
+		WithArgs(sqlmock.AnyArg(), nil, "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true, false, false, 0, false, "b3032140-e824-4b39-9be2-47e99f383f2b").
 		WillReturnError(fmt.Errorf("oops"))
 	mock.ExpectRollback()
 	db, _ := gorm.Open("postgres", conn)
@@ -388,8 +388,8 @@ func TestActivate(t *testing.T) {
 		WithArgs("b3032140-e824-4b39-9be2-47e99f383f2b").
 		WillReturnRows(usersRows)
 	mock.ExpectBegin()
-	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"users\" SET \"created_at\" = $1, \"updated_at\" = $2, \"deleted_at\" = $3, \"human_id\" = $4, \"username\" = $5, \"display_name\" = $6, \"email\" = $7, \"password\" = $8, \"private_key\" = $9, \"summary\" = $10, \"local\" = $11, \"verified\" = $12  WHERE \"users\".\"deleted_at\" IS NULL AND \"users\".\"id\" = $13")+"$").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true, "b3032140-e824-4b39-9be2-47e99f383f2b").
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"users\" SET \"created_at\" = $1, \"updated_at\" = $2, \"deleted_at\" = $3, \"human_id\" = $4, \"username\" = $5, \"display_name\" = $6, \"email\" = $7, \"password\" = $8, \"private_key\" = $9, \"summary\" = $10, \"local\" = $11, \"verified\" = $12, \"admin\" = $13, \"manually_approves_followers\" = $14, \"key_version\" = $15, \"instance\" = $16  WHERE \"users\".\"deleted_at\" IS NULL AND \"users\".\"id\" = $17")+"$").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true, false, false, 0, false, "b3032140-e824-4b39-9be2-47e99f383f2b").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 	mock.ExpectBegin()
@@ -431,8 +431,8 @@ func TestActivate_User_ErrStorage(t *testing.T) {
 		WithArgs("b3032140-e824-4b39-9be2-47e99f383f2b").
 		WillReturnRows(usersRows)
 	mock.ExpectBegin()
-	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"users\" SET \"created_at\" = $1, \"updated_at\" = $2, \"deleted_at\" = $3, \"human_id\" = $4, \"username\" = $5, \"display_name\" = $6, \"email\" = $7, \"password\" = $8, \"private_key\" = $9, \"summary\" = $10, \"local\" = $11, \"verified\" = $12  WHERE \"users\".\"deleted_at\" IS NULL AND \"users\".\"id\" = $13")+"$").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true, "b3032140-e824-4b39-9be2-47e99f383f2b").
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"users\" SET \"created_at\" = $1, \"updated_at\" = $2, \"deleted_at\" = $3, \"human_id\" = $4, \"username\" = $5, \"display_name\" = $6, \"email\" = $7, \"password\" = $8, \"private_key\" = $9, \"summary\" = $10, \"local\" = $11, \"verified\" = $12, \"admin\" = $13, \"manually_approves_followers\" = $14, \"key_version\" = $15, \"instance\" = $16  WHERE \"users\".\"deleted_at\" IS NULL AND \"users\".\"id\" = $17")+"$").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true, false, false, 0, false, "b3032140-e824-4b39-9be2-47e99f383f2b").
 		WillReturnError(fmt.Errorf("oops"))
 	mock.ExpectRollback()
 	db, _ := gorm.Open("postgres", conn)
@@ -454,8 +454,8 @@ func TestActivate_RegistrationKeys_ErrStorage(t *testing.T) {
 		WithArgs("b3032140-e824-4b39-9be2-47e99f383f2b").
 		WillReturnRows(usersRows)
 	mock.ExpectBegin()
-	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"users\" SET \"created_at\" = $1, \"updated_at\" = $2, \"deleted_at\" = $3, \"human_id\" = $4, \"username\" = $5, \"display_name\" = $6, \"email\" = $7, \"password\" = $8, \"private_key\" = $9, \"summary\" = $10, \"local\" = $11, \"verified\" = $12  WHERE \"users\".\"deleted_at\" IS NULL AND \"users\".\"id\" = $13")+"$").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true, "b3032140-e824-4b39-9be2-47e99f383f2b").
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"users\" SET \"created_at\" = $1, \"updated_at\" = $2, \"deleted_at\" = $3, \"human_id\" = $4, \"username\" = $5, \"display_name\" = $6, \"email\" = $7, \"password\" = $8, \"private_key\" = $9, \"summary\" = $10, \"local\" = $11, \"verified\" = $12, \"admin\" = $13, \"manually_approves_followers\" = $14, \"key_version\" = $15, \"instance\" = $16  WHERE \"users\".\"deleted_at\" IS NULL AND \"users\".\"id\" = $17")+"$").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "bob@mainframe", "bob", "guardianBob", "bob@mainframe.local", sqlmock.AnyArg(), sqlmock.AnyArg(), "summary", true, true, false, false, 0, false, "b3032140-e824-4b39-9be2-47e99f383f2b").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 	mock.ExpectBegin()
@@ -471,3 +471,190 @@ func TestActivate_RegistrationKeys_ErrStorage(t *testing.T) {
 	assert.True(t, errors.Is(err, ErrStorage))
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestGetByIRI(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"users\" WHERE \"users\".\"deleted_at\" IS NULL AND ((human_id = $1 AND local = $2)) ORDER BY \"users\".\"id\" ASC LIMIT 1")+"$").
+		WithArgs("https://mastodon.example/users/alice", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "human_id", "username", "local"}).
+			AddRow("0c8f4b9e-5b8a-4f8e-9a43-2d1e2f5f1b0a", "https://mastodon.example/users/alice", "alice@mastodon.example", false))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	user, err := repo.GetByIRI("https://mastodon.example/users/alice")
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, user) {
+		assert.Equal(t, "alice@mastodon.example", user.Username)
+		assert.Equal(t, "https://mastodon.example/users/alice", user.IRI().String())
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByIRI_ErrNotFound(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT * FROM \"users\"")).
+		WithArgs("https://mastodon.example/users/alice", false).
+		WillReturnError(gorm.ErrRecordNotFound)
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	user, err := repo.GetByIRI("https://mastodon.example/users/alice")
+
+	assert.Nil(t, user)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHasFollower(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT count(*) FROM \"followers\"  WHERE (user_id = $1 AND id = $2 AND pending = $3)")+"$").
+		WithArgs("b3032140-e824-4b39-9be2-47e99f383f2b", "https://mastodon.example/users/alice", false).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	follows, err := repo.HasFollower(&model.User{
+		Base: model.Base{
+			ID: uuid.MustParse("b3032140-e824-4b39-9be2-47e99f383f2b"),
+		},
+	}, "https://mastodon.example/users/alice")
+
+	assert.NoError(t, err)
+	assert.True(t, follows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHasFollower_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT count(*) FROM \"followers\"")).
+		WillReturnError(fmt.Errorf("oops"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	follows, err := repo.HasFollower(&model.User{
+		Base: model.Base{
+			ID: uuid.MustParse("b3032140-e824-4b39-9be2-47e99f383f2b"),
+		},
+	}, "https://mastodon.example/users/alice")
+
+	assert.Error(t, err)
+	assert.False(t, follows)
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete_Local(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"users\" SET \"deleted_at\"=$1  WHERE \"users\".\"deleted_at\" IS NULL AND ((id = $2))")+"$").
+		WithArgs(sqlmock.AnyArg(), uuid.MustParse("b3032140-e824-4b39-9be2-47e99f383f2b")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Delete(&model.User{
+		Base: model.Base{
+			ID: uuid.MustParse("b3032140-e824-4b39-9be2-47e99f383f2b"),
+		},
+		Local: true,
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete_Remote(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("DELETE FROM \"users\"  WHERE (id = $1)") + "$").
+		WithArgs(uuid.MustParse("b3032140-e824-4b39-9be2-47e99f383f2b")).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	err := repo.Delete(&model.User{
+		Base: model.Base{
+			ID: uuid.MustParse("b3032140-e824-4b39-9be2-47e99f383f2b"),
+		},
+	})
+
+	assert.True(t, errors.Is(err, ErrNotDeleted))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountLocal(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT count(*) FROM \"users\"  WHERE \"users\".\"deleted_at\" IS NULL AND ((local = $1 AND verified = $2))")+"$").
+		WithArgs(true, true).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	count, err := repo.CountLocal()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountLocal_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT count(*) FROM \"users\"")).
+		WillReturnError(fmt.Errorf("oops"))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	count, err := repo.CountLocal()
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, count)
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountActiveLocal(t *testing.T) {
+	since := time.Date(2020, 6, 11, 12, 0, 0, 0, time.UTC)
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^"+regexp.QuoteMeta("SELECT count(*) FROM \"users\"  WHERE \"users\".\"deleted_at\" IS NULL AND ((local = $1 AND verified = $2) AND (id IN (SELECT user_id FROM \"reads\"  WHERE \"reads\".\"deleted_at\" IS NULL AND ((created_at >= $3))) OR id IN (SELECT user_id FROM \"reviews\"  WHERE \"reviews\".\"deleted_at\" IS NULL AND ((created_at >= $4))) OR id IN (SELECT user_id FROM \"replies\"  WHERE \"replies\".\"deleted_at\" IS NULL AND ((created_at >= $5)))))")+"$").
+		WithArgs(true, true, since, since, since).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	count, err := repo.CountActiveLocal(since)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkLocal(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	// remote users, whose HumanID is their actor IRI, must be left alone
+	mock.ExpectBegin()
+	mock.ExpectExec("^"+regexp.QuoteMeta("UPDATE \"users\" SET \"local\" = $1, \"updated_at\" = $2  WHERE \"users\".\"deleted_at\" IS NULL AND ((local = $3 AND human_id NOT LIKE $4))")+"$").
+		WithArgs(true, sqlmock.AnyArg(), false, "http%").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	assert.NoError(t, repo.MarkLocal())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkLocal_ErrStorage(t *testing.T) {
+	conn, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta("UPDATE \"users\" SET")).
+		WillReturnError(fmt.Errorf("oops"))
+	mock.ExpectRollback()
+	db, _ := gorm.Open("postgres", conn)
+
+	repo := New(db)
+	assert.True(t, errors.Is(repo.MarkLocal(), ErrStorage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -13,11 +13,19 @@ import (
 )
 
 const (
-	// KeySize is the size of the keypair to use.
-	KeySize = 1024
+	// DefaultKeySize is the size of the keypairs generated for users, unless the operator chooses another.
+	DefaultKeySize = 2048
 )
 
-// New creates an RSA private key with the default size.
+// KeySize is the size of the keypairs generated for users. It is set from the configuration at startup.
+var KeySize = DefaultKeySize
+
+// IsAllowedSize returns whether keypairs of the given size may be generated. Smaller keys are considered weak by other servers, and larger ones are slow to generate and sign with.
+func IsAllowedSize(bits int) bool {
+	return bits == 2048 || bits == 4096
+}
+
+// New creates an RSA private key with the configured size.
 func New() (k *rsa.PrivateKey, err error) {
 	k, err = rsa.GenerateKey(rand.Reader, KeySize)
 	return
@@ -51,6 +59,18 @@ func MarshalPublicKey(p crypto.PublicKey) (string, error) {
 	return string(pb), nil
 }
 
+// ParsePublicKey takes the PEM representation of a public key, as found in an actor's `publicKeyPem`, and returns the key it contains.
+func ParsePublicKey(s string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in public key")
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
 // SerializeRSAPrivateKey takes a private key and returns its byte representation.
 func SerializeRSAPrivateKey(k *rsa.PrivateKey) ([]byte, error) {
 	return x509.MarshalPKCS8PrivateKey(k)
@@ -1,16 +1,19 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"time"
 
+	"github.com/exlibris-fed/exlibris/activitypub"
 	"github.com/exlibris-fed/exlibris/config"
 	"github.com/exlibris-fed/exlibris/handler"
 	"github.com/exlibris-fed/exlibris/handler/middleware"
 	"github.com/exlibris-fed/exlibris/infrastructure"
+	"github.com/exlibris-fed/exlibris/key"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -19,13 +22,23 @@ import (
 
 func main() {
 	cfg := config.Load()
+	key.KeySize = cfg.KeySize
 
 	db := infrastructure.New(cfg.DSN)
 	defer db.Close()
 
 	infrastructure.Migrate(db)
 
-	h := handler.New(db, cfg)
+	ap := activitypub.New(db, cfg)
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:], db, ap); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	ap.Start(context.Background())
+
+	h := handler.New(db, cfg, ap)
 	m := middleware.New(db)
 
 	r := mux.NewRouter()
@@ -37,25 +50,81 @@ func main() {
 	api.HandleFunc("/authenticate", h.Authenticate).Methods(http.MethodPost, http.MethodOptions)
 	api.HandleFunc("/verify/resend/{user}", h.ResendVerificationKey).Methods(http.MethodPost, http.MethodOptions)
 	api.HandleFunc("/verify/{key}", h.VerifyKey).Methods(http.MethodGet, http.MethodOptions)
+	api.Handle("/user/lookup", m.WithUserModel(http.HandlerFunc(h.LookupUser))).Methods(http.MethodGet, http.MethodOptions)
 	api.Handle("/user/{username}", http.HandlerFunc(h.HandleActivityPubProfile))
+	api.Handle("/account", m.WithUserModel(http.HandlerFunc(h.DeleteAccount))).Methods(http.MethodDelete, http.MethodOptions)
+	api.Handle("/account/key", m.WithUserModel(http.HandlerFunc(h.RotateKey))).Methods(http.MethodPost, http.MethodOptions)
 
 	books := api.PathPrefix("/book").Subrouter()
 	books.Use(m.WithUserModel)
 	books.HandleFunc("", h.SearchBooks).Methods(http.MethodGet, http.MethodOptions)
 	books.HandleFunc("/{book}/read", h.Read).Methods(http.MethodPost, http.MethodOptions)
 	books.HandleFunc("/read", h.GetReads).Methods(http.MethodGet, http.MethodOptions)
+	books.HandleFunc("/read/{id}", h.DeleteRead).Methods(http.MethodDelete, http.MethodOptions)
 	books.HandleFunc("/{book}", h.GetBook).Methods(http.MethodGet, http.MethodOptions)
 	books.HandleFunc("/{book}/review", h.Review).Methods(http.MethodPost, http.MethodOptions, http.MethodGet)
+	books.HandleFunc("/{book}/review/{id}", h.EditReview).Methods(http.MethodPut, http.MethodOptions)
+	books.HandleFunc("/{book}/review/{id}", h.DeleteReview).Methods(http.MethodDelete)
+	books.HandleFunc("/{book}/review/{id}/replies", h.Reply).Methods(http.MethodPost, http.MethodOptions)
+	books.HandleFunc("/{book}/review/{id}/replies/{reply}", h.DeleteReply).Methods(http.MethodDelete, http.MethodOptions)
+
+	followers := api.PathPrefix("/followers").Subrouter()
+	followers.Use(m.WithUserModel)
+	followers.HandleFunc("", h.GetFollowers).Methods(http.MethodGet, http.MethodOptions)
+	followers.HandleFunc("/requests", h.GetFollowRequests).Methods(http.MethodGet, http.MethodOptions)
+	followers.HandleFunc("/requests/approve", h.ApproveFollowRequest).Methods(http.MethodPost, http.MethodOptions)
+	followers.HandleFunc("/requests/reject", h.RejectFollowRequest).Methods(http.MethodPost, http.MethodOptions)
+
+	following := api.PathPrefix("/following").Subrouter()
+	following.Use(m.WithUserModel)
+	following.HandleFunc("", h.GetFollowing).Methods(http.MethodGet)
+	following.HandleFunc("", h.Follow).Methods(http.MethodPost, http.MethodOptions)
+	following.HandleFunc("/undo", h.Unfollow).Methods(http.MethodPost, http.MethodOptions)
+
+	blocks := api.PathPrefix("/blocks").Subrouter()
+	blocks.Use(m.WithUserModel)
+	blocks.HandleFunc("", h.GetBlocks).Methods(http.MethodGet)
+	blocks.HandleFunc("", h.Block).Methods(http.MethodPost, http.MethodOptions)
+	blocks.HandleFunc("/undo", h.Unblock).Methods(http.MethodPost, http.MethodOptions)
+
+	likes := api.PathPrefix("/likes").Subrouter()
+	likes.Use(m.WithUserModel)
+	likes.HandleFunc("", h.Like).Methods(http.MethodPost, http.MethodOptions)
+	likes.HandleFunc("/undo", h.Unlike).Methods(http.MethodPost, http.MethodOptions)
+
+	announces := api.PathPrefix("/announces").Subrouter()
+	announces.Use(m.WithUserModel)
+	announces.HandleFunc("", h.Announce).Methods(http.MethodPost, http.MethodOptions)
+	announces.HandleFunc("/undo", h.Unannounce).Methods(http.MethodPost, http.MethodOptions)
+
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(m.WithUserModel, m.Admin)
+	admin.HandleFunc("/domain-blocks", h.GetDomainBlocks).Methods(http.MethodGet)
+	admin.HandleFunc("/domain-blocks", h.BlockDomain).Methods(http.MethodPost, http.MethodOptions)
+	admin.HandleFunc("/domain-blocks/{domain}", h.UnblockDomain).Methods(http.MethodDelete, http.MethodOptions)
+	admin.HandleFunc("/relays", h.GetRelays).Methods(http.MethodGet)
+	admin.HandleFunc("/relays", h.AddRelay).Methods(http.MethodPost, http.MethodOptions)
+	admin.HandleFunc("/relays/{id}", h.RemoveRelay).Methods(http.MethodDelete, http.MethodOptions)
 
 	// inbox/outbox handle authentication as part of the go-fed flow. ExtractUsername will populate it if present.
-	r.HandleFunc("/user/{username}", http.HandlerFunc(h.HandleActivityPubProfile))
+	r.HandleFunc("/user/{username}", h.HandleProfile)
+	r.HandleFunc("/@{username}", h.HandleProfile).Methods(http.MethodGet)
+	r.HandleFunc("/user/{username}/key", h.HandlePublicKey).Methods(http.MethodGet)
 	r.Handle("/user/{username}/inbox", m.WithUserModel(http.HandlerFunc(h.HandleInbox)))
 	r.Handle("/user/{username}/outbox", m.WithUserModel(http.HandlerFunc(h.HandleOutbox)))
-	r.PathPrefix("/user/").Handler(http.HandlerFunc(h.HandleActivityPubAction))
+	r.HandleFunc("/inbox", h.HandleSharedInbox).Methods(http.MethodPost)
+	r.HandleFunc("/book/{book:OL[0-9]+W}", h.HandleBook).Methods(http.MethodGet)
+	r.HandleFunc("/book/{book:OL[0-9]+W}/work", h.HandleBookWork).Methods(http.MethodGet)
+	r.HandleFunc("/author/{author:OL[0-9]+A}", h.HandleAuthor).Methods(http.MethodGet)
+	r.PathPrefix("/user/").Handler(m.WithUserModel(http.HandlerFunc(h.HandleActivityPubAction)))
 
 	// App
 	r.HandleFunc("/.well-known/acme-challenge/{id}", h.HandleChallenge)
-	r.HandleFunc("/.well-known/webfinger", h.HandleWebfinger)
+	r.HandleFunc("/.well-known/webfinger", h.HandleWebfinger).Methods(http.MethodGet)
+	r.HandleFunc("/.well-known/host-meta", h.HandleHostMeta).Methods(http.MethodGet)
+	r.HandleFunc("/.well-known/host-meta.json", h.HandleHostMeta).Methods(http.MethodGet)
+	r.HandleFunc("/.well-known/nodeinfo", h.HandleNodeInfoDiscovery).Methods(http.MethodGet)
+	r.HandleFunc("/nodeinfo/{version:2\\.[01]}", h.HandleNodeInfo).Methods(http.MethodGet)
 	r.PathPrefix("/").Handler(http.HandlerFunc(h.HandleStaticFile))
 	corsRouter := handlers.CORS(handlers.AllowedOrigins([]string{"*"}),
 		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", "Access-Control-Allow-Origin"}))
@@ -2,7 +2,10 @@ package model
 
 import (
 	"fmt"
+	"log"
 	"net/url"
+	"regexp"
+	"strings"
 
 	"github.com/exlibris-fed/openlibrary-go"
 	"github.com/go-fed/activity/streams"
@@ -35,6 +38,21 @@ func (a *Author) ToType() vocab.Type {
 	return author
 }
 
+// Key returns the bare key of the author on OpenLibrary (`OL1A`), as it appears in our URLs.
+func (a *Author) Key() string {
+	return strings.TrimPrefix(a.OpenLibraryID, "/authors/")
+}
+
+// IRI returns the IRI the author is served at, for servers such as BookWyrm which fetch the authors of the books they're sent.
+func (a *Author) IRI() *url.URL {
+	u, err := url.Parse(fmt.Sprintf(authorURL, a.Key()))
+	if err != nil {
+		log.Printf("error creating IRI for author %s: %s", a.OpenLibraryID, err)
+		return nil
+	}
+	return u
+}
+
 // NewAuthor creates an author from an openlibrary Author
 func NewAuthor(author openlibrary.Author) *Author {
 	return &Author{
@@ -42,3 +60,28 @@ func NewAuthor(author openlibrary.Author) *Author {
 		Name:          author.Name,
 	}
 }
+
+var regexpAuthorID = regexp.MustCompile("/authors/(OL[0-9]+A)")
+
+// AuthorFromType creates an author from an ActivityPub Person, such as one a Document is attributed to.
+func AuthorFromType(person vocab.ActivityStreamsPerson) (*Author, error) {
+	var id *url.URL
+	if person.GetJSONLDId() != nil {
+		id = person.GetJSONLDId().Get()
+	}
+	if id == nil || id.Host != "openlibrary.org" {
+		return nil, fmt.Errorf("%v is not an OpenLibrary author", id)
+	}
+	pieces := regexpAuthorID.FindAllStringSubmatch(id.Path, -1)
+	if len(pieces) == 0 {
+		return nil, fmt.Errorf("%v is not an OpenLibrary author", id)
+	}
+
+	author := &Author{
+		OpenLibraryID: "/authors/" + pieces[len(pieces)-1][1],
+	}
+	if name := person.GetActivityStreamsName(); name != nil && name.Len() > 0 && name.At(0).IsXMLSchemaString() {
+		author.Name = name.At(0).GetXMLSchemaString()
+	}
+	return author, nil
+}
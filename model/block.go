@@ -0,0 +1,50 @@
+package model
+
+import (
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+	"github.com/google/uuid"
+)
+
+// A Block is the IRI of an actor a user has blocked. Nothing from them is delivered to the user, and they can't follow the user.
+type Block struct {
+	ID        string    `gorm:"primary_key"`
+	User      User      `gorm:"association_autoupdate:false"`
+	UserID    uuid.UUID `gorm:"primary_key"`
+	BlockID   string    `gorm:"not null;index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ToType returns the Block activity the user sent. The User must be populated.
+func (b *Block) ToType() vocab.Type {
+	block := streams.NewActivityStreamsBlock()
+
+	if u, err := url.Parse(b.BlockID); err == nil {
+		id := streams.NewJSONLDIdProperty()
+		id.SetIRI(u)
+		block.SetJSONLDId(id)
+	} else {
+		log.Printf("error parsing block id '%s': %s", b.BlockID, err.Error())
+	}
+
+	actor := streams.NewActivityStreamsActorProperty()
+	actor.AppendIRI(b.User.IRI())
+	block.SetActivityStreamsActor(actor)
+
+	if u, err := url.Parse(b.ID); err == nil {
+		object := streams.NewActivityStreamsObjectProperty()
+		object.AppendIRI(u)
+		block.SetActivityStreamsObject(object)
+
+		to := streams.NewActivityStreamsToProperty()
+		to.AppendIRI(u)
+		block.SetActivityStreamsTo(to)
+	}
+
+	return block
+}
@@ -2,13 +2,17 @@ package model
 
 import (
 	"fmt"
+	"log"
 	"net/url"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/exlibris-fed/openlibrary-go"
 	"github.com/go-fed/activity/streams"
 	"github.com/go-fed/activity/streams/vocab"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // A Book is something that can be read. Currently this only supports things which are in the Library of Congress API, but eventually it'd be great to support fanfiction and other online-only sources.
@@ -20,7 +24,17 @@ type Book struct {
 	ISBN          string   `json:"isbn,omitempty"`
 	Authors       []Author `gorm:"many2many:book_authors;null"`
 	Description   string   `gorm:"null" json:"description"`
-	Covers        []Cover  `gorm:"foreignkey:BookID;association_foreignkey:OpenLibraryID;null" json:"covers"`
+
+	// ISBN10 is the edition's ISBN-10. ISBN holds the ISBN-13 instead when there is one.
+	ISBN10 string `json:"isbn10,omitempty"`
+	// EditionID is the OpenLibrary id of the edition the book's details come from, in the form it is stored in (`/books/OL1M`).
+	EditionID string `json:"edition_id,omitempty"`
+	// WikidataID is the book's item on Wikidata, such as `Q42`, if OpenLibrary knows it.
+	WikidataID string         `json:"wikidata_id,omitempty"`
+	Pages      int            `json:"pages,omitempty"`
+	Subjects   pq.StringArray `gorm:"type:text[]" json:"subjects,omitempty"`
+
+	Covers []Cover `gorm:"foreignkey:BookID;association_foreignkey:OpenLibraryID;null" json:"covers"`
 }
 
 // NewBook returns a new instance of a book
@@ -30,6 +44,7 @@ func NewBook(book openlibrary.Work, editions []openlibrary.Edition, authors []Au
 		Title:         book.Title,
 		Authors:       authors,
 		Description:   string(book.Description),
+		WikidataID:    book.RemoteIds.Wikidata,
 	}
 
 	// @TODO: This is just blindly taking the first edition returns in editions, could be smarter?
@@ -40,8 +55,11 @@ func NewBook(book openlibrary.Work, editions []openlibrary.Edition, authors []Au
 			result.Covers = append(result.Covers, Cover{Base: Base{ID: uuid.New()}, URL: book.CoverURL(openlibrary.SizeMedium), Type: string(openlibrary.SizeMedium)})
 			result.Covers = append(result.Covers, Cover{Base: Base{ID: uuid.New()}, URL: book.CoverURL(openlibrary.SizeSmall), Type: string(openlibrary.SizeSmall)})
 		}
+		result.EditionID = edition.Key
+		result.Pages = edition.NumberOfPages
 		if len(edition.Isbn10) > 0 {
 			result.ISBN = edition.Isbn10[0]
+			result.ISBN10 = edition.Isbn10[0]
 		}
 		if len(edition.Isbn13) > 0 {
 			result.ISBN = edition.Isbn13[0]
@@ -64,11 +82,62 @@ func NewBook(book openlibrary.Work, editions []openlibrary.Edition, authors []Au
 	return result
 }
 
-// ToType returns a representation of a book as an ActivityPub object.
+// URL returns the book's page on OpenLibrary.
+func (b *Book) URL() string {
+	return "https://openlibrary.org" + b.OpenLibraryID
+}
+
+// Key returns the bare key of the book's OpenLibrary work (`OL1W`), as it appears in our URLs.
+func (b *Book) Key() string {
+	return strings.TrimPrefix(b.OpenLibraryID, "/works/")
+}
+
+// EditionIRI returns the IRI the book is served at as an edition, for servers such as BookWyrm which only know about books they can fetch. It's the same as the book's page, which browsers are sent to.
+func (b *Book) EditionIRI() *url.URL {
+	u, err := url.Parse(fmt.Sprintf(bookURL, b.Key()))
+	if err != nil {
+		log.Printf("error creating edition IRI for book %s: %s", b.OpenLibraryID, err)
+		return nil
+	}
+	return u
+}
+
+// WorkIRI returns the IRI the book is served at as a work, which its edition belongs to.
+func (b *Book) WorkIRI() *url.URL {
+	u, err := url.Parse(fmt.Sprintf(bookURL+"/work", b.Key()))
+	if err != nil {
+		log.Printf("error creating work IRI for book %s: %s", b.OpenLibraryID, err)
+		return nil
+	}
+	return u
+}
+
+// EditionURL returns the page on OpenLibrary of the edition the book's details come from, or an empty string if we don't know which it is.
+func (b *Book) EditionURL() string {
+	if b.EditionID == "" {
+		return ""
+	}
+	return "https://openlibrary.org" + b.EditionID
+}
+
+// cover returns the URL of the largest cover we have for the book, or an empty string if it has none.
+func (b *Book) cover() string {
+	for _, cover := range b.Covers {
+		if cover.Type == string(openlibrary.SizeLarge) {
+			return cover.URL
+		}
+	}
+	if len(b.Covers) > 0 {
+		return b.Covers[0].URL
+	}
+	return ""
+}
+
+// ToType returns a representation of a book as an ActivityPub Document. Its id is the work on OpenLibrary, and the identifiers and details in VocabularyContext are included as well, so that other servers can tell which book it is even if they use another catalogue.
 func (b *Book) ToType() vocab.Type {
 	book := streams.NewActivityStreamsDocument()
 
-	u, err := url.Parse(fmt.Sprintf("https://openlibrary.org/works/%s/", b.OpenLibraryID))
+	u, err := url.Parse(b.URL())
 	if err == nil {
 		id := streams.NewJSONLDIdProperty()
 		id.SetIRI(u)
@@ -93,5 +162,160 @@ func (b *Book) ToType() vocab.Type {
 	}
 	book.SetActivityStreamsAttributedTo(authors)
 
+	properties := book.GetUnknownProperties()
+	if key, ok := openLibraryKey(b.OpenLibraryID); ok {
+		properties["openlibraryKey"] = key
+	}
+	if len(b.ISBN) == 13 {
+		properties["isbn13"] = b.ISBN
+	}
+	if b.ISBN10 != "" {
+		properties["isbn10"] = b.ISBN10
+	} else if len(b.ISBN) == 10 {
+		properties["isbn10"] = b.ISBN
+	}
+	if b.WikidataID != "" {
+		properties["wikidata"] = b.WikidataID
+	}
+	if edition := b.EditionURL(); edition != "" {
+		properties["edition"] = edition
+	}
+	if b.Pages > 0 {
+		properties["pages"] = b.Pages
+	}
+	if len(b.Subjects) > 0 {
+		properties["subjects"] = []string(b.Subjects)
+	}
+	if cover := b.cover(); cover != "" {
+		properties["cover"] = map[string]interface{}{
+			"type": "Image",
+			"url":  cover,
+			"name": b.Title,
+		}
+	}
+
 	return book
 }
+
+var (
+	regexpWorkID      = regexp.MustCompile("/works/(OL[0-9]+W)")
+	regexpEditionID   = regexp.MustCompile("^/books/OL[0-9]+M$")
+	regexpWorkKey     = regexp.MustCompile("^(?:/works/)?(OL[0-9]+W)$")
+	regexpWikidataID  = regexp.MustCompile("^Q[0-9]+$")
+	regexpISBNSpacing = regexp.MustCompile("[- ]")
+)
+
+// openLibraryKey returns the bare key of an OpenLibrary work (`OL1W`), which is how BookWyrm and the OpenLibrary API refer to it. It accepts both the bare key and the form we store ids in.
+func openLibraryKey(id string) (string, bool) {
+	pieces := regexpWorkKey.FindStringSubmatch(id)
+	if pieces == nil {
+		return "", false
+	}
+	return pieces[1], true
+}
+
+// BookIDFromIRI returns the OpenLibrary id of the work at an IRI, in the form it is stored in (`/works/OL1W`). It returns false if the IRI isn't an OpenLibrary work.
+func BookIDFromIRI(u *url.URL) (string, bool) {
+	if u == nil || u.Host != "openlibrary.org" {
+		return "", false
+	}
+	pieces := regexpWorkID.FindAllStringSubmatch(u.Path, -1)
+	if len(pieces) == 0 {
+		return "", false
+	}
+	return "/works/" + pieces[len(pieces)-1][1], true
+}
+
+// BookFromType creates a book from an ActivityPub Document, such as the object of a Read from another server. The book is identified by its id if that is an OpenLibrary work, and otherwise by its openlibraryKey, so that books from servers which give them ids of their own can be matched up with ours. Its authors are included, but only with the information in the document.
+func BookFromType(document vocab.ActivityStreamsDocument) (*Book, error) {
+	var id *url.URL
+	if document.GetJSONLDId() != nil {
+		id = document.GetJSONLDId().Get()
+	}
+	properties := document.GetUnknownProperties()
+	bookID, ok := BookIDFromIRI(id)
+	if !ok {
+		key, isString := properties["openlibraryKey"].(string)
+		if key, ok = openLibraryKey(key); !isString || !ok {
+			return nil, fmt.Errorf("%v is not an OpenLibrary work", id)
+		}
+		bookID = "/works/" + key
+	}
+
+	book := &Book{
+		OpenLibraryID: bookID,
+	}
+	if name := document.GetActivityStreamsName(); name != nil && name.Len() > 0 && name.At(0).IsXMLSchemaString() {
+		book.Title = name.At(0).GetXMLSchemaString()
+	}
+	if book.Title == "" {
+		return nil, fmt.Errorf("book %s has no title", bookID)
+	}
+	if published := document.GetActivityStreamsPublished(); published != nil && published.IsXMLSchemaDateTime() {
+		// @FIXME: we should store int64 instead of int, currently reducing precision
+		book.Published = int(published.Get().Unix())
+	}
+	bookProperties(book, properties)
+
+	if attributedTo := document.GetActivityStreamsAttributedTo(); attributedTo != nil {
+		for iter := attributedTo.Begin(); iter != attributedTo.End(); iter = iter.Next() {
+			if !iter.IsActivityStreamsPerson() {
+				continue
+			}
+			if author, err := AuthorFromType(iter.GetActivityStreamsPerson()); err == nil {
+				book.Authors = append(book.Authors, *author)
+			}
+		}
+	}
+
+	return book, nil
+}
+
+// bookProperties fills in a book from the properties in VocabularyContext. Anything which isn't in the form we send it in is ignored.
+func bookProperties(book *Book, properties map[string]interface{}) {
+	if isbn, ok := properties["isbn10"].(string); ok {
+		if isbn = regexpISBNSpacing.ReplaceAllString(isbn, ""); len(isbn) == 10 {
+			book.ISBN = isbn
+			book.ISBN10 = isbn
+		}
+	}
+	if isbn, ok := properties["isbn13"].(string); ok {
+		if isbn = regexpISBNSpacing.ReplaceAllString(isbn, ""); len(isbn) == 13 {
+			book.ISBN = isbn
+		}
+	}
+	if wikidata, ok := properties["wikidata"].(string); ok && regexpWikidataID.MatchString(wikidata) {
+		book.WikidataID = wikidata
+	}
+	if edition, ok := properties["edition"].(string); ok {
+		if u, err := url.Parse(edition); err == nil && u.Host == "openlibrary.org" && regexpEditionID.MatchString(u.Path) {
+			book.EditionID = u.Path
+		}
+	}
+	// JSON numbers are decoded as float64
+	if pages, ok := properties["pages"].(float64); ok && pages > 0 {
+		book.Pages = int(pages)
+	}
+	switch subjects := properties["subjects"].(type) {
+	case []string:
+		book.Subjects = append(book.Subjects, subjects...)
+	case []interface{}:
+		for _, subject := range subjects {
+			if subject, ok := subject.(string); ok && subject != "" {
+				book.Subjects = append(book.Subjects, subject)
+			}
+		}
+	}
+
+	var cover string
+	switch c := properties["cover"].(type) {
+	case string:
+		cover = c
+	case map[string]interface{}:
+		cover, _ = c["url"].(string)
+	}
+	// covers are shown as they are, so only ones from OpenLibrary are trusted
+	if u, err := url.Parse(cover); cover != "" && err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host == "covers.openlibrary.org" {
+		book.Covers = append(book.Covers, Cover{Base: Base{ID: uuid.New()}, URL: cover, Type: string(openlibrary.SizeLarge)})
+	}
+}
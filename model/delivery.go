@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DeliveryStatusPending is the status of a delivery that has yet to succeed and will be retried.
+	DeliveryStatusPending = "pending"
+
+	// DeliveryStatusDead is the status of a delivery that failed too many times and will not be retried.
+	DeliveryStatusDead = "dead"
+)
+
+// A Delivery is an activity waiting to be sent to a single remote inbox on behalf of a user. Deliveries are removed once they succeed.
+type Delivery struct {
+	Base
+	ActivityID    string    `gorm:"not null;index"`
+	Inbox         string    `gorm:"not null"`
+	Host          string    `gorm:"not null;index"`
+	Payload       string    `gorm:"not null"`
+	User          User      `gorm:"association_autoupdate:false"`
+	UserID        uuid.UUID `gorm:"not null"`
+	Status        string    `gorm:"not null;index"`
+	Attempts      int       `gorm:"not null"`
+	NextAttemptAt time.Time `gorm:"not null;index"`
+	LastError     string
+}
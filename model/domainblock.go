@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+const (
+	// DomainBlockReject refuses all activities from the domain, and never delivers to it.
+	DomainBlockReject = "reject"
+
+	// DomainBlockSilence accepts activities from the domain, but hides them from anyone who doesn't follow the actor.
+	DomainBlockSilence = "silence"
+
+	// DomainBlockMediaReject accepts activities from the domain, but not any media attached to them, such as book covers.
+	DomainBlockMediaReject = "media-reject"
+)
+
+// A DomainBlock limits how this server federates with another one. They are managed by admins.
+type DomainBlock struct {
+	Domain    string `gorm:"primary_key"`
+	Level     string `gorm:"not null"`
+	Reason    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// IsDomainBlockLevel returns whether level is one of the levels a domain can be blocked at.
+func IsDomainBlockLevel(level string) bool {
+	switch level {
+	case DomainBlockReject, DomainBlockSilence, DomainBlockMediaReject:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+	"github.com/google/uuid"
+)
+
+// Following is the IRI of someone a user follows. It is pending until they accept the follow.
+type Following struct {
+	ID        string    `gorm:"primary_key"`
+	User      User      `gorm:"association_autoupdate:false"`
+	UserID    uuid.UUID `gorm:"primary_key"`
+	FollowID  string    `gorm:"not null;index"`
+	Accepted  bool      `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName is the name of the table following is stored in. It would otherwise be "followings".
+func (Following) TableName() string {
+	return "following"
+}
+
+// ToType returns the Follow activity the user sent. The User must be populated.
+func (f *Following) ToType() vocab.Type {
+	return followToType(f.FollowID, f.User.IRI().String(), f.ID)
+}
+
+// followToType returns a Follow activity of object by actor.
+func followToType(followID, actor, object string) vocab.ActivityStreamsFollow {
+	follow := streams.NewActivityStreamsFollow()
+
+	if u, err := url.Parse(followID); err == nil {
+		id := streams.NewJSONLDIdProperty()
+		id.SetIRI(u)
+		follow.SetJSONLDId(id)
+	} else {
+		log.Printf("error parsing follow id '%s': %s", followID, err.Error())
+	}
+
+	if u, err := url.Parse(actor); err == nil {
+		actorProperty := streams.NewActivityStreamsActorProperty()
+		actorProperty.AppendIRI(u)
+		follow.SetActivityStreamsActor(actorProperty)
+	}
+
+	if u, err := url.Parse(object); err == nil {
+		objectProperty := streams.NewActivityStreamsObjectProperty()
+		objectProperty.AppendIRI(u)
+		follow.SetActivityStreamsObject(objectProperty)
+
+		to := streams.NewActivityStreamsToProperty()
+		to.AppendIRI(u)
+		follow.SetActivityStreamsTo(to)
+	}
+
+	return follow
+}
@@ -0,0 +1,13 @@
+package model
+
+const (
+	// ContextKeyDelivery is the key to use for the body of an activity delivered to an inbox, as it was delivered, so that it can be forwarded without changing it.
+	ContextKeyDelivery ContextKey = "delivery"
+)
+
+// A Forward records that an activity from another server was forwarded to the followers of a local user, so that it's only forwarded once however many times it reaches us.
+type Forward struct {
+	BaseEvents
+	// ID is the id of the activity that was forwarded.
+	ID string `gorm:"primary_key"`
+}
@@ -7,7 +7,7 @@ type InboxEntry struct {
 	Base
 	User       User `gorm:"association_autoupdate:false"`
 	UserID     uuid.UUID
-	InboxIRI   string
+	InboxIRI   string `gorm:"index"`
 	URI        string
 	Serialized string
 }
@@ -17,9 +17,14 @@ var (
 	PublicActivityPubIRI *url.URL
 
 	profileURL   string
+	actorURL     string
 	inboxURL     string
 	outboxURL    string
 	followersURL string
+	followingURL string
+	likedURL     string
+	bookURL      string
+	authorURL    string
 )
 
 func init() {
@@ -37,9 +42,14 @@ func init() {
 	domain := os.Getenv("DOMAIN")
 	baseURL := scheme + "://" + domain
 	profileURL = baseURL + "/@%s"
+	actorURL = baseURL + "/user/%s"
 	inboxURL = baseURL + "/user/%s/inbox"
 	outboxURL = baseURL + "/user/%s/outbox"
 	followersURL = baseURL + "/user/%s/followers"
+	followingURL = baseURL + "/user/%s/following"
+	likedURL = baseURL + "/user/%s/liked"
+	bookURL = baseURL + "/book/%s"
+	authorURL = baseURL + "/author/%s"
 }
 
 // A ContextKey is a key used to represent a model in a context
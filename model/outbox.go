@@ -7,7 +7,7 @@ type OutboxEntry struct {
 	Base
 	User       User `gorm:"association_autoupdate:false"`
 	UserID     uuid.UUID
-	OutboxIRI  string
+	OutboxIRI  string `gorm:"index"`
 	URI        string
 	Serialized string
 }
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+)
+
+const (
+	// ReactionLike is the kind of a reaction that likes a read or review.
+	ReactionLike = "Like"
+
+	// ReactionAnnounce is the kind of a reaction that shares, or boosts, a read or review with the actor's followers.
+	ReactionAnnounce = "Announce"
+)
+
+// A Reaction is a Like or Announce of a read or review, by a local or remote actor. Its ID is the IRI of the activity, and the object is kept by IRI so that reactions to remote objects can be stored too.
+type Reaction struct {
+	ID        string `gorm:"primary_key"`
+	Kind      string `gorm:"not null;index"`
+	ActorIRI  string `gorm:"not null;index"`
+	ObjectIRI string `gorm:"not null;index"`
+	// AuthorIRI is who wrote the object, so that they can be told about the reaction.
+	AuthorIRI string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// reactionActivity is an activity that reacts to an object.
+type reactionActivity interface {
+	vocab.Type
+	SetActivityStreamsActor(vocab.ActivityStreamsActorProperty)
+	SetActivityStreamsObject(vocab.ActivityStreamsObjectProperty)
+	SetActivityStreamsTo(vocab.ActivityStreamsToProperty)
+	SetActivityStreamsCc(vocab.ActivityStreamsCcProperty)
+	SetActivityStreamsPublished(vocab.ActivityStreamsPublishedProperty)
+}
+
+// reactable is an object that can be liked and shared.
+type reactable interface {
+	SetActivityStreamsLikes(vocab.ActivityStreamsLikesProperty)
+	SetActivityStreamsShares(vocab.ActivityStreamsSharesProperty)
+}
+
+// setReactionCollections links a local object to the collections of its likes and shares, which are found under its IRI.
+func setReactionCollections(object reactable, iri *url.URL) {
+	if iri == nil {
+		return
+	}
+	if u, err := url.Parse(iri.String() + "/likes"); err == nil {
+		likes := streams.NewActivityStreamsLikesProperty()
+		likes.SetIRI(u)
+		object.SetActivityStreamsLikes(likes)
+	}
+	if u, err := url.Parse(iri.String() + "/shares"); err == nil {
+		shares := streams.NewActivityStreamsSharesProperty()
+		shares.SetIRI(u)
+		object.SetActivityStreamsShares(shares)
+	}
+}
+
+// ToType returns the Like or Announce activity of the reaction. A like is only sent to the author of the object, while an announce is public and copied to the author and the actor's followers.
+func (r *Reaction) ToType() vocab.Type {
+	var a reactionActivity
+	if r.Kind == ReactionAnnounce {
+		a = streams.NewActivityStreamsAnnounce()
+	} else {
+		a = streams.NewActivityStreamsLike()
+	}
+
+	if u, err := url.Parse(r.ID); err == nil {
+		id := streams.NewJSONLDIdProperty()
+		id.SetIRI(u)
+		a.SetJSONLDId(id)
+	} else {
+		log.Printf("error parsing reaction id '%s': %s", r.ID, err.Error())
+	}
+
+	if u, err := url.Parse(r.ActorIRI); err == nil {
+		actor := streams.NewActivityStreamsActorProperty()
+		actor.AppendIRI(u)
+		a.SetActivityStreamsActor(actor)
+	}
+
+	if u, err := url.Parse(r.ObjectIRI); err == nil {
+		object := streams.NewActivityStreamsObjectProperty()
+		object.AppendIRI(u)
+		a.SetActivityStreamsObject(object)
+	}
+
+	a.SetActivityStreamsTo(r.to())
+	a.SetActivityStreamsCc(r.cc())
+
+	if !r.CreatedAt.IsZero() {
+		published := streams.NewActivityStreamsPublishedProperty()
+		published.Set(r.CreatedAt)
+		a.SetActivityStreamsPublished(published)
+	}
+
+	return a
+}
+
+// UndoToType returns an Undo of the reaction with the given id, sent to everyone the reaction was.
+func (r *Reaction) UndoToType(undoID *url.URL) vocab.Type {
+	undo := streams.NewActivityStreamsUndo()
+
+	id := streams.NewJSONLDIdProperty()
+	id.SetIRI(undoID)
+	undo.SetJSONLDId(id)
+
+	if u, err := url.Parse(r.ActorIRI); err == nil {
+		actor := streams.NewActivityStreamsActorProperty()
+		actor.AppendIRI(u)
+		undo.SetActivityStreamsActor(actor)
+	}
+
+	object := streams.NewActivityStreamsObjectProperty()
+	if err := object.AppendType(r.ToType()); err != nil {
+		log.Printf("error adding reaction %s to undo: %s", r.ID, err.Error())
+	}
+	undo.SetActivityStreamsObject(object)
+
+	undo.SetActivityStreamsTo(r.to())
+	undo.SetActivityStreamsCc(r.cc())
+
+	return undo
+}
+
+// to addresses a like to the author of the object, and an announce to the public.
+func (r *Reaction) to() vocab.ActivityStreamsToProperty {
+	to := streams.NewActivityStreamsToProperty()
+	if r.Kind == ReactionAnnounce {
+		if PublicActivityPubIRI != nil {
+			to.AppendIRI(PublicActivityPubIRI)
+		}
+		return to
+	}
+	if u, err := url.Parse(r.AuthorIRI); err == nil && r.AuthorIRI != "" {
+		to.AppendIRI(u)
+	}
+	return to
+}
+
+// cc copies an announce to the author of the object and the followers of the actor who shared it.
+func (r *Reaction) cc() vocab.ActivityStreamsCcProperty {
+	cc := streams.NewActivityStreamsCcProperty()
+	if r.Kind != ReactionAnnounce {
+		return cc
+	}
+	if u, err := url.Parse(r.AuthorIRI); err == nil && r.AuthorIRI != "" {
+		cc.AppendIRI(u)
+	}
+	if u, err := url.Parse(r.ActorIRI + "/followers"); err == nil {
+		cc.AppendIRI(u)
+	}
+	return cc
+}
@@ -22,6 +22,9 @@ type Read struct {
 	BookID string
 	User   User `gorm:"association_autoupdate:false"`
 	UserID uuid.UUID
+
+	// FollowersOnly reads are only addressed to the user's followers, rather than to the public.
+	FollowersOnly bool
 }
 
 // ToType returns a representation of a read activity as an ActivityPub object.
@@ -47,10 +50,20 @@ func (r *Read) ToType() vocab.Type {
 
 	toProperty := streams.NewActivityStreamsToProperty()
 	toProperty.AppendIRI(r.User.FollowersIRI())
-	if PublicActivityPubIRI != nil {
+	if PublicActivityPubIRI != nil && !r.FollowersOnly {
 		toProperty.AppendIRI(PublicActivityPubIRI)
 	}
 	read.SetActivityStreamsTo(toProperty)
 
+	if !r.CreatedAt.IsZero() {
+		published := streams.NewActivityStreamsPublishedProperty()
+		published.Set(r.CreatedAt)
+		read.SetActivityStreamsPublished(published)
+	}
+
+	if r.User.Local {
+		setReactionCollections(read, u)
+	}
+
 	return read
 }
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/go-fed/activity/pub"
+	"github.com/go-fed/activity/streams/vocab"
+	"github.com/google/uuid"
+)
+
+const (
+	// RelayPending is the status of a relay that hasn't answered the instance's follow yet.
+	RelayPending = "pending"
+
+	// RelayAccepted is the status of a relay that accepted the instance's follow, and announces public activities from its other subscribers to it.
+	RelayAccepted = "accepted"
+
+	// RelayRejected is the status of a relay that declined the instance's follow.
+	RelayRejected = "rejected"
+)
+
+// A Relay is an ActivityPub relay this server is subscribed to. The instance actor follows the public collection of the relay, which announces public activities from all of its subscribers to the ones that follow it. Relays are managed by admins.
+type Relay struct {
+	ID uuid.UUID `gorm:"primary_key"`
+	// Inbox is where the relay is sent activities, as the admin gave it.
+	Inbox string `gorm:"unique;not null"`
+	// ActorIRI is the relay's actor, which is only known once it has answered the follow.
+	ActorIRI string `gorm:"index"`
+	FollowID string `gorm:"not null;index"`
+	Status   string `gorm:"not null"`
+	// Publish relays also get our public reads, to announce to their other subscribers.
+	Publish   bool `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Accepted returns whether the relay has accepted the follow.
+func (r *Relay) Accepted() bool {
+	return r.Status == RelayAccepted
+}
+
+// InboxIRI returns the relay's inbox.
+func (r *Relay) InboxIRI() (*url.URL, error) {
+	return url.Parse(r.Inbox)
+}
+
+// FollowToType returns the Follow of the public collection that subscribes the instance actor to the relay, which is how Mastodon and most relays expect to be followed.
+func (r *Relay) FollowToType(actor *url.URL) vocab.Type {
+	return followToType(r.FollowID, actor.String(), pub.PublicActivityPubIRI)
+}
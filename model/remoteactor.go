@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// A RemoteActor is the cached actor document of a person, service or other actor on another server. It is kept so that their inbox, shared inbox and key don't have to be dereferenced for every interaction, and refreshed once it's stale or their key stops verifying.
+type RemoteActor struct {
+	BaseEvents
+	ID          string `gorm:"primary_key"`
+	Inbox       string `gorm:"not null"`
+	SharedInbox string
+	PublicKeyID string `gorm:"index"`
+	// Document is the actor as it was served, so that it can be handed to go-fed in place of dereferencing it again.
+	Document  string    `gorm:"type:text;not null"`
+	FetchedAt time.Time `gorm:"not null"`
+}
+
+// Stale returns whether the actor was fetched longer than ttl ago.
+func (a *RemoteActor) Stale(now time.Time, ttl time.Duration) bool {
+	return now.Sub(a.FetchedAt) > ttl
+}
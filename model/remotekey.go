@@ -0,0 +1,14 @@
+package model
+
+const (
+	// ContextKeySignedBy is the key to use for the IRI of the remote actor whose HTTP Signature was verified on the current request. It should not be set until the signature has been verified.
+	ContextKeySignedBy ContextKey = "signedby"
+)
+
+// A RemoteKey is the public key of an actor on another server. It is cached so that HTTP Signatures can be verified without dereferencing the key on every request.
+type RemoteKey struct {
+	BaseEvents
+	ID    string `gorm:"primary_key"`
+	Owner string `gorm:"not null;index"`
+	PEM   string `gorm:"not null"`
+}
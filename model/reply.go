@@ -0,0 +1,191 @@
+package model
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+	"github.com/google/uuid"
+)
+
+// A Reply is a comment on a review, or on another reply to it. Every reply in a thread belongs to the review at its root, so that the whole discussion can be fetched at once.
+type Reply struct {
+	Base
+	// URI is the ActivityPub id of a reply from another server. The ids of local replies are derived from their author and ID instead.
+	URI      string    `gorm:"index"`
+	Review   Review    `gorm:"association_autoupdate:false"`
+	ReviewID uuid.UUID `gorm:"index"`
+
+	// InReplyTo is the IRI of the review or reply this one answers.
+	InReplyTo string `gorm:"not null;index"`
+
+	// InReplyToAuthor is the IRI of whoever wrote what this answers, so that they can be told about it.
+	InReplyToAuthor string
+
+	User   User      `gorm:"association_autoupdate:false"`
+	UserID uuid.UUID `gorm:"index"`
+	Text   string
+}
+
+// IRI returns the ActivityPub id of the reply.
+func (r *Reply) IRI() *url.URL {
+	if r.URI != "" {
+		u, err := url.Parse(r.URI)
+		if err != nil {
+			log.Printf("error parsing IRI for remote reply %s: %s", r.ID, err)
+			return nil
+		}
+		return u
+	}
+	u, err := url.Parse(fmt.Sprintf(actorURL+"/reply/%s", strings.ToLower(r.User.Username), r.ID))
+	if err != nil {
+		log.Printf("error creating IRI for reply %s: %s", r.ID, err)
+		return nil
+	}
+	return u
+}
+
+// ToType returns a representation of a reply as an ActivityPub Note in reply to the review or reply it answers. Whoever wrote that is mentioned, so that their server tells them about it.
+func (r *Reply) ToType() vocab.Type {
+	note := streams.NewActivityStreamsNote()
+
+	id := streams.NewJSONLDIdProperty()
+	id.SetIRI(r.IRI())
+	note.SetJSONLDId(id)
+
+	attributedTo := streams.NewActivityStreamsAttributedToProperty()
+	attributedTo.AppendIRI(r.User.IRI())
+	note.SetActivityStreamsAttributedTo(attributedTo)
+
+	if u, err := url.Parse(r.InReplyTo); err == nil {
+		inReplyTo := streams.NewActivityStreamsInReplyToProperty()
+		inReplyTo.AppendIRI(u)
+		note.SetActivityStreamsInReplyTo(inReplyTo)
+	}
+
+	note.SetActivityStreamsTo(r.to())
+	note.SetActivityStreamsCc(r.cc())
+
+	if !r.CreatedAt.IsZero() {
+		published := streams.NewActivityStreamsPublishedProperty()
+		published.Set(r.CreatedAt)
+		note.SetActivityStreamsPublished(published)
+	}
+
+	content := streams.NewActivityStreamsContentProperty()
+	content.AppendXMLSchemaString(paragraphs(r.Text))
+	note.SetActivityStreamsContent(content)
+	note.SetActivityStreamsSource(textSource(r.Text))
+
+	if u, err := url.Parse(r.InReplyToAuthor); err == nil && r.InReplyToAuthor != "" {
+		mention := streams.NewActivityStreamsMention()
+		href := streams.NewActivityStreamsHrefProperty()
+		href.Set(u)
+		mention.SetActivityStreamsHref(href)
+		tag := streams.NewActivityStreamsTagProperty()
+		tag.AppendActivityStreamsMention(mention)
+		note.SetActivityStreamsTag(tag)
+	}
+
+	if r.URI == "" {
+		setReplies(note, r.IRI())
+	}
+
+	return note
+}
+
+// CreateToType returns the Create activity that publishes a reply. Its id is derived from the reply's, so that it can be looked up again.
+func (r *Reply) CreateToType() vocab.Type {
+	create := streams.NewActivityStreamsCreate()
+
+	u, err := url.Parse(r.IRI().String() + "/activity")
+	if err != nil {
+		log.Printf("error generating url ID for creation of reply %s: %s", r.ID, err.Error())
+		return nil
+	}
+	id := streams.NewJSONLDIdProperty()
+	id.SetIRI(u)
+	create.SetJSONLDId(id)
+
+	actor := streams.NewActivityStreamsActorProperty()
+	actor.AppendIRI(r.User.IRI())
+	create.SetActivityStreamsActor(actor)
+
+	create.SetActivityStreamsTo(r.to())
+	create.SetActivityStreamsCc(r.cc())
+
+	object := streams.NewActivityStreamsObjectProperty()
+	object.AppendActivityStreamsNote(r.ToType().(vocab.ActivityStreamsNote))
+	create.SetActivityStreamsObject(object)
+
+	return create
+}
+
+// to addresses a reply to the public, like the review it's part of.
+func (r *Reply) to() vocab.ActivityStreamsToProperty {
+	to := streams.NewActivityStreamsToProperty()
+	if PublicActivityPubIRI != nil {
+		to.AppendIRI(PublicActivityPubIRI)
+	}
+	return to
+}
+
+// cc copies a reply to whoever wrote what it answers and to its author's followers.
+func (r *Reply) cc() vocab.ActivityStreamsCcProperty {
+	cc := streams.NewActivityStreamsCcProperty()
+	if u, err := url.Parse(r.InReplyToAuthor); err == nil && r.InReplyToAuthor != "" {
+		cc.AppendIRI(u)
+	}
+	cc.AppendIRI(r.User.FollowersIRI())
+	return cc
+}
+
+// setReplies links a local object to the collection of replies to it, which is found under its IRI.
+func setReplies(note vocab.ActivityStreamsNote, iri *url.URL) {
+	if iri == nil {
+		return
+	}
+	u, err := url.Parse(iri.String() + "/replies")
+	if err != nil {
+		return
+	}
+	replies := streams.NewActivityStreamsRepliesProperty()
+	replies.SetIRI(u)
+	note.SetActivityStreamsReplies(replies)
+}
+
+// ReplyFromType creates a reply from an ActivityPub Note, such as a comment on a review from Mastodon. Only the text is kept; the review, what it answers, and the author have to be filled in by the caller.
+func ReplyFromType(note vocab.ActivityStreamsNote) (*Reply, error) {
+	if note.GetJSONLDId() == nil || note.GetJSONLDId().Get() == nil {
+		return nil, fmt.Errorf("note has no id")
+	}
+	reply := &Reply{
+		Base: Base{
+			ID: uuid.New(),
+		},
+		URI:  note.GetJSONLDId().Get().String(),
+		Text: noteText(note),
+	}
+	if published := note.GetActivityStreamsPublished(); published != nil && published.IsXMLSchemaDateTime() {
+		reply.CreatedAt = published.Get()
+	}
+	return reply, nil
+}
+
+// InReplyTo returns the IRI of what a Note answers, or nil if it isn't a reply.
+func InReplyTo(note vocab.ActivityStreamsNote) *url.URL {
+	inReplyTo := note.GetActivityStreamsInReplyTo()
+	if inReplyTo == nil || inReplyTo.Len() == 0 {
+		return nil
+	}
+	if inReplyTo.At(0).IsIRI() {
+		return inReplyTo.At(0).GetIRI()
+	}
+	if t := inReplyTo.At(0).GetType(); t != nil && t.GetJSONLDId() != nil {
+		return t.GetJSONLDId().Get()
+	}
+	return nil
+}
@@ -1,13 +1,255 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+	"github.com/google/uuid"
+)
+
+// MaxRating is the highest rating a review can give a book.
+const MaxRating = 5
 
 // Review models a book review
 type Review struct {
 	Base
-	Book   Book      `gorm:"association_autoupdate:false"`
+	// URI is the ActivityPub id of a review from another server. The ids of local reviews are derived from their author and ID instead.
+	URI    string    `gorm:"index"`
+	Book   Book      `gorm:"foreignkey:OpenLibraryID;association_foreignkey:BookID;association_autoupdate:false"`
 	BookID string    `gorm:"index"`
 	User   User      `gorm:"association_autoupdate:false"`
 	UserID uuid.UUID `gorm:"index"`
 	Text   string
+
+	// Spoiler warns readers that the review gives away the book, and is shown in place of it until they choose to read on.
+	Spoiler string
+
+	// Rating is how many stars out of MaxRating the reviewer gave the book, or 0 if they didn't rate it.
+	Rating int
+}
+
+// IRI returns the ActivityPub id of the review.
+func (r *Review) IRI() *url.URL {
+	if r.URI != "" {
+		u, err := url.Parse(r.URI)
+		if err != nil {
+			log.Printf("error parsing IRI for remote review %s: %s", r.ID, err)
+			return nil
+		}
+		return u
+	}
+	u, err := url.Parse(fmt.Sprintf(actorURL+"/review/%s", strings.ToLower(r.User.Username), r.ID))
+	if err != nil {
+		log.Printf("error creating IRI for review %s: %s", r.ID, err)
+		return nil
+	}
+	return u
+}
+
+// ToType returns a representation of a review as an ActivityPub Note. The content is readable on its own, so that software which knows nothing about books still shows something sensible; the book itself is attached as a tag, and the rating as a `rating` property, for other exlibris servers.
+func (r *Review) ToType() vocab.Type {
+	note := streams.NewActivityStreamsNote()
+
+	id := streams.NewJSONLDIdProperty()
+	id.SetIRI(r.IRI())
+	note.SetJSONLDId(id)
+
+	attributedTo := streams.NewActivityStreamsAttributedToProperty()
+	attributedTo.AppendIRI(r.User.IRI())
+	note.SetActivityStreamsAttributedTo(attributedTo)
+
+	note.SetActivityStreamsTo(r.to())
+	note.SetActivityStreamsCc(r.cc())
+
+	if !r.CreatedAt.IsZero() {
+		published := streams.NewActivityStreamsPublishedProperty()
+		published.Set(r.CreatedAt)
+		note.SetActivityStreamsPublished(published)
+	}
+
+	content := streams.NewActivityStreamsContentProperty()
+	content.AppendXMLSchemaString(r.content())
+	note.SetActivityStreamsContent(content)
+	note.SetActivityStreamsSource(textSource(r.Text))
+
+	if r.Spoiler != "" {
+		summary := streams.NewActivityStreamsSummaryProperty()
+		summary.AppendXMLSchemaString(r.Spoiler)
+		note.SetActivityStreamsSummary(summary)
+		// Mastodon only hides the content behind the summary if the note is marked sensitive
+		note.GetUnknownProperties()["sensitive"] = true
+	}
+
+	tag := streams.NewActivityStreamsTagProperty()
+	tag.AppendActivityStreamsDocument(r.Book.ToType().(vocab.ActivityStreamsDocument))
+	note.SetActivityStreamsTag(tag)
+
+	note.GetUnknownProperties()["inReplyToBook"] = r.Book.URL()
+	if r.Rating > 0 {
+		note.GetUnknownProperties()["rating"] = r.Rating
+	}
+
+	if r.URI == "" {
+		setReactionCollections(note, r.IRI())
+		setReplies(note, r.IRI())
+	}
+
+	return note
+}
+
+// CreateToType returns the Create activity that publishes a review. Its id is derived from the review's, so that it can be looked up again.
+func (r *Review) CreateToType() vocab.Type {
+	create := streams.NewActivityStreamsCreate()
+
+	u, err := url.Parse(r.IRI().String() + "/activity")
+	if err != nil {
+		log.Printf("error generating url ID for creation of review %s: %s", r.ID, err.Error())
+		return nil
+	}
+	id := streams.NewJSONLDIdProperty()
+	id.SetIRI(u)
+	create.SetJSONLDId(id)
+
+	actor := streams.NewActivityStreamsActorProperty()
+	actor.AppendIRI(r.User.IRI())
+	create.SetActivityStreamsActor(actor)
+
+	create.SetActivityStreamsTo(r.to())
+	create.SetActivityStreamsCc(r.cc())
+
+	if !r.CreatedAt.IsZero() {
+		published := streams.NewActivityStreamsPublishedProperty()
+		published.Set(r.CreatedAt)
+		create.SetActivityStreamsPublished(published)
+	}
+
+	object := streams.NewActivityStreamsObjectProperty()
+	object.AppendActivityStreamsNote(r.ToType().(vocab.ActivityStreamsNote))
+	create.SetActivityStreamsObject(object)
+
+	return create
+}
+
+// to addresses a review to the public, the way Mastodon addresses public posts.
+func (r *Review) to() vocab.ActivityStreamsToProperty {
+	to := streams.NewActivityStreamsToProperty()
+	if PublicActivityPubIRI != nil {
+		to.AppendIRI(PublicActivityPubIRI)
+	}
+	return to
+}
+
+// cc copies a review to its author's followers.
+func (r *Review) cc() vocab.ActivityStreamsCcProperty {
+	cc := streams.NewActivityStreamsCcProperty()
+	cc.AppendIRI(r.User.FollowersIRI())
+	return cc
+}
+
+// content renders a review as HTML, headed by a link to the book and the rating.
+func (r *Review) content() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<p>Review of <a href="%s">%s</a>`, html.EscapeString(r.Book.URL()), html.EscapeString(r.Book.Title))
+	if r.Rating > 0 {
+		fmt.Fprintf(&b, " %s%s", strings.Repeat("★", r.Rating), strings.Repeat("☆", MaxRating-r.Rating))
+	}
+	b.WriteString("</p>")
+
+	b.WriteString(paragraphs(r.Text))
+	return b.String()
+}
+
+// paragraphs renders plain text as HTML paragraphs. Blank lines separate paragraphs, and other line breaks are kept.
+func paragraphs(text string) string {
+	var b strings.Builder
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "<p>%s</p>", strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>"))
+	}
+	return b.String()
+}
+
+// textSource returns the plain text a Note was written in, as its source.
+func textSource(text string) vocab.ActivityStreamsSourceProperty {
+	source := streams.NewActivityStreamsObject()
+	content := streams.NewActivityStreamsContentProperty()
+	content.AppendXMLSchemaString(text)
+	source.SetActivityStreamsContent(content)
+	mediaType := streams.NewActivityStreamsMediaTypeProperty()
+	mediaType.Set("text/plain")
+	source.SetActivityStreamsMediaType(mediaType)
+	property := streams.NewActivityStreamsSourceProperty()
+	property.SetActivityStreamsObject(source)
+	return property
+}
+
+// ReviewFromType creates a review from an ActivityPub Note, such as one from another exlibris server. Only the text of the review is kept; the book and author have to be filled in by the caller. The plain text source is preferred, and the HTML content is only used if there isn't one.
+func ReviewFromType(note vocab.ActivityStreamsNote) (*Review, error) {
+	if note.GetJSONLDId() == nil || note.GetJSONLDId().Get() == nil {
+		return nil, fmt.Errorf("note has no id")
+	}
+	review := &Review{
+		Base: Base{
+			ID: uuid.New(),
+		},
+		URI: note.GetJSONLDId().Get().String(),
+	}
+
+	review.Text = noteText(note)
+
+	if summary := note.GetActivityStreamsSummary(); summary != nil && summary.Len() > 0 && summary.At(0).IsXMLSchemaString() {
+		review.Spoiler = summary.At(0).GetXMLSchemaString()
+	}
+
+	if published := note.GetActivityStreamsPublished(); published != nil && published.IsXMLSchemaDateTime() {
+		review.CreatedAt = published.Get()
+	}
+
+	// JSON numbers are decoded as float64
+	switch rating := note.GetUnknownProperties()["rating"].(type) {
+	case float64:
+		review.Rating = int(rating)
+	case int:
+		review.Rating = rating
+	}
+	if review.Rating < 0 || review.Rating > MaxRating {
+		review.Rating = 0
+	}
+
+	return review, nil
+}
+
+// noteText returns the text of a Note, preferring the plain text source to the HTML content.
+func noteText(note vocab.ActivityStreamsNote) string {
+	if source := note.GetActivityStreamsSource(); source != nil && source.IsActivityStreamsObject() {
+		if content := source.GetActivityStreamsObject().GetActivityStreamsContent(); content != nil && content.Len() > 0 && content.At(0).IsXMLSchemaString() {
+			return content.At(0).GetXMLSchemaString()
+		}
+	}
+	if content := note.GetActivityStreamsContent(); content != nil && content.Len() > 0 && content.At(0).IsXMLSchemaString() {
+		return content.At(0).GetXMLSchemaString()
+	}
+	return ""
+}
+
+// BookDocument returns the book a Note reviews, from the Documents tagged on it. It returns nil if no book is tagged.
+func BookDocument(note vocab.ActivityStreamsNote) vocab.ActivityStreamsDocument {
+	tags := note.GetActivityStreamsTag()
+	if tags == nil {
+		return nil
+	}
+	for iter := tags.Begin(); iter != tags.End(); iter = iter.Next() {
+		if iter.IsActivityStreamsDocument() {
+			return iter.GetActivityStreamsDocument()
+		}
+	}
+	return nil
 }
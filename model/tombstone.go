@@ -0,0 +1,42 @@
+package model
+
+import (
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+)
+
+// A Tombstone marks where a local object used to be. Its IRI is never reused, and anyone who fetches it is told it is gone rather than that it never existed.
+type Tombstone struct {
+	ID         string `gorm:"primary_key"`
+	FormerType string `gorm:"not null"`
+	CreatedAt  time.Time
+}
+
+// ToType returns a representation of the deleted object as an ActivityPub Tombstone.
+func (t *Tombstone) ToType() vocab.Type {
+	tombstone := streams.NewActivityStreamsTombstone()
+
+	if u, err := url.Parse(t.ID); err == nil {
+		id := streams.NewJSONLDIdProperty()
+		id.SetIRI(u)
+		tombstone.SetJSONLDId(id)
+	} else {
+		log.Printf("error parsing tombstone id '%s': %s", t.ID, err.Error())
+	}
+
+	formerType := streams.NewActivityStreamsFormerTypeProperty()
+	formerType.AppendXMLSchemaString(t.FormerType)
+	tombstone.SetActivityStreamsFormerType(formerType)
+
+	if !t.CreatedAt.IsZero() {
+		deleted := streams.NewActivityStreamsDeletedProperty()
+		deleted.Set(t.CreatedAt)
+		tombstone.SetActivityStreamsDeleted(deleted)
+	}
+
+	return tombstone
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"path"
 	"strings"
 	"time"
 
@@ -30,6 +31,9 @@ const (
 
 	// ContextKeyJWT is the key to use for a User's JWT in a context
 	ContextKeyJWT ContextKey = "jwt"
+
+	// ContextKeyInboxOwner is the key to use for the local User whose inbox an activity is being delivered to.
+	ContextKeyInboxOwner ContextKey = "inboxowner"
 )
 
 // A User is a person interacting with the app. They may not be registered on this server.
@@ -43,9 +47,22 @@ type User struct {
 	PrivateKey       []byte `json:"-"`
 	Summary          string
 	Followers        []Follower        `gorm:"foreignkey:UserID"`
+	Following        []Following       `gorm:"foreignkey:UserID"`
 	CryptoPrivateKey crypto.PrivateKey `gorm:"-"`
 	Local            bool              `json:"-"`
 	Verified         bool              `json:"-"`
+
+	// Admin users can manage how this server federates with others.
+	Admin bool `json:"-"`
+
+	// ManuallyApprovesFollowers users must approve follow requests before they take effect.
+	ManuallyApprovesFollowers bool `gorm:"default:true" json:"-"`
+
+	// KeyVersion counts how many times the user's keypair has been rotated, so that each key is served under its own id.
+	KeyVersion int `gorm:"default:0" json:"-"`
+
+	// Instance is set on the actor that represents the server itself, rather than a person.
+	Instance bool `json:"-"`
 }
 
 // NewUser creates a user and handles generating the ID, key and hashed password.
@@ -63,6 +80,9 @@ func NewUser(username, password, email, displayName string) (*User, error) {
 		Username:    username,
 		Email:       email,
 		DisplayName: displayName,
+		Local:       true,
+
+		ManuallyApprovesFollowers: true,
 	}
 	u.SetPassword(password)
 	if err := u.GenerateKeys(); err != nil {
@@ -71,6 +91,64 @@ func NewUser(username, password, email, displayName string) (*User, error) {
 	return &u, nil
 }
 
+// NewInstanceActor creates the actor that represents the server itself, for things it does rather than any of its users, such as subscribing to relays and signing requests made outside of a user's. It is an Application named after the domain, like Mastodon's, and has no password so that nobody can log in as it.
+func NewInstanceActor(domain string) (*User, error) {
+	u := User{
+		Base: Base{
+			ID: uuid.New(),
+		},
+		HumanID:     fmt.Sprintf("%s/@%s", domain, strings.ToLower(domain)),
+		Username:    strings.ToLower(domain),
+		DisplayName: domain,
+		Local:       true,
+		Instance:    true,
+	}
+	if err := u.GenerateKeys(); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// NewRemoteUser creates a user who is registered on another server, from their actor. The person may be nil if only their IRI is known, in which case their username is guessed from it.
+func NewRemoteUser(actor *url.URL, person vocab.ActivityStreamsPerson) *User {
+	username := path.Base(actor.Path)
+	u := User{
+		Base: Base{
+			ID: uuid.New(),
+		},
+		HumanID: actor.String(),
+	}
+	u.UpdateFromType(person)
+	if u.Username == "" {
+		u.Username = fmt.Sprintf("%s@%s", username, actor.Host)
+	}
+	if u.DisplayName == "" {
+		u.DisplayName = username
+	}
+	return &u
+}
+
+// UpdateFromType copies a remote user's name and summary from their actor. It does nothing if the person is nil.
+func (u *User) UpdateFromType(person vocab.ActivityStreamsPerson) {
+	if person == nil {
+		return
+	}
+	actor, err := url.Parse(u.HumanID)
+	if err != nil {
+		return
+	}
+	if p := person.GetActivityStreamsPreferredUsername(); p != nil && p.IsXMLSchemaString() && p.GetXMLSchemaString() != "" {
+		u.Username = fmt.Sprintf("%s@%s", p.GetXMLSchemaString(), actor.Host)
+		u.DisplayName = p.GetXMLSchemaString()
+	}
+	if n := person.GetActivityStreamsName(); n != nil && n.Len() > 0 && n.At(0).IsXMLSchemaString() && n.At(0).GetXMLSchemaString() != "" {
+		u.DisplayName = n.At(0).GetXMLSchemaString()
+	}
+	if s := person.GetActivityStreamsSummary(); s != nil && s.Len() > 0 && s.At(0).IsXMLSchemaString() {
+		u.Summary = s.At(0).GetXMLSchemaString()
+	}
+}
+
 // SetPassword is used to hash the password the user wishes to use.
 func (u *User) SetPassword(password string) {
 	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -81,6 +159,33 @@ func (u *User) SetPassword(password string) {
 	u.Password = hashed
 }
 
+// RotateKeys replaces a user's keypair with a new one, which is served under a new key id so that other servers don't keep using the cached old one.
+func (u *User) RotateKeys() error {
+	if err := u.GenerateKeys(); err != nil {
+		return err
+	}
+	u.KeyVersion++
+	u.CryptoPrivateKey = nil
+	return nil
+}
+
+// PublicKeyID returns the id of the user's current public key, which is a fragment of their actor so that dereferencing it finds the key. Keys from before the first rotation keep the original id.
+func (u *User) PublicKeyID() string {
+	if u.KeyVersion == 0 {
+		return u.IRI().String() + "#main-key"
+	}
+	return fmt.Sprintf("%s#main-key-%d", u.IRI().String(), u.KeyVersion)
+}
+
+// PublicKeyPEM returns the PEM encoding of the user's public key.
+func (u *User) PublicKeyPEM() (string, error) {
+	pk, err := key.DeserializeRSAPrivateKey(u.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	return key.MarshalPublicKeyFromPrivateKey(pk)
+}
+
 // GenerateKeys is used on user registration to generate a private key for a user. It can theoretically be used to invalidate all existing tokens/sessions.
 func (u *User) GenerateKeys() error {
 	k, err := key.New()
@@ -95,9 +200,17 @@ func (u *User) GenerateKeys() error {
 	return nil
 }
 
-// IRI returns a url representing the user's profile
+// IRI returns a url representing the user's profile. This is the id of the user's actor. Remote users keep the id they have on their own server.
 func (u *User) IRI() *url.URL {
-	URL, err := url.Parse(fmt.Sprintf("https://%s", strings.ToLower(u.Username)))
+	if !u.Local {
+		URL, err := url.Parse(u.HumanID)
+		if err != nil {
+			log.Printf("error parsing IRI for remote user %s (%s): %s", u.ID, u.Username, err)
+			return nil
+		}
+		return URL
+	}
+	URL, err := url.Parse(fmt.Sprintf(actorURL, strings.ToLower(u.Username)))
 	if err != nil {
 		log.Printf("error creating IRI for user %s (%s): %s", u.ID, u.Username, err)
 		return nil
@@ -135,6 +248,26 @@ func (u *User) FollowersIRI() *url.URL {
 	return URL
 }
 
+// FollowingIRI returns a url representing who the user follows
+func (u *User) FollowingIRI() *url.URL {
+	URL, err := url.Parse(fmt.Sprintf(followingURL, strings.ToLower(u.Username)))
+	if err != nil {
+		log.Printf("error creating following IRI for user %s (%s): %s", u.Username, u.Username, err)
+		return nil
+	}
+	return URL
+}
+
+// LikedIRI returns a url representing what the user has liked
+func (u *User) LikedIRI() *url.URL {
+	URL, err := url.Parse(fmt.Sprintf(likedURL, strings.ToLower(u.Username)))
+	if err != nil {
+		log.Printf("error creating liked IRI for user %s (%s): %s", u.Username, u.Username, err)
+		return nil
+	}
+	return URL
+}
+
 // IsPassword verifies that the specified password matches what's in the database.
 func (u *User) IsPassword(password string) bool {
 	return bcrypt.CompareHashAndPassword(u.Password, []byte(password)) == nil
@@ -163,13 +296,27 @@ func (u *User) GenerateJWT() (string, error) {
 	return t, nil
 }
 
-// ToType returns a representation of a user as an ActivityPub object.
+// actorType is the ActivityStreams type of a user's actor.
+type actorType interface {
+	vocab.Type
+	SetJSONLDId(vocab.JSONLDIdProperty)
+	SetActivityStreamsInbox(vocab.ActivityStreamsInboxProperty)
+	SetActivityStreamsOutbox(vocab.ActivityStreamsOutboxProperty)
+	SetActivityStreamsLiked(vocab.ActivityStreamsLikedProperty)
+	SetActivityStreamsName(vocab.ActivityStreamsNameProperty)
+	SetActivityStreamsPreferredUsername(vocab.ActivityStreamsPreferredUsernameProperty)
+	SetW3IDSecurityV1PublicKey(vocab.W3IDSecurityV1PublicKeyProperty)
+}
+
+// ToType returns a representation of a user as an ActivityPub object. Users are Persons, and the instance actor is an Application.
 func (u *User) ToType() vocab.Type {
-	user := streams.NewActivityStreamsPerson()
+	var user actorType = streams.NewActivityStreamsPerson()
+	if u.Instance {
+		user = streams.NewActivityStreamsApplication()
+	}
 
-	URL, err := url.Parse(u.HumanID)
-	if err != nil {
-		log.Printf("error generating user ID for user '%s': %s", u.Username, err.Error())
+	URL := u.IRI()
+	if URL == nil {
 		return nil
 	}
 
@@ -185,6 +332,12 @@ func (u *User) ToType() vocab.Type {
 	outboxProperty.SetIRI(u.OutboxIRI())
 	user.SetActivityStreamsOutbox(outboxProperty)
 
+	if u.Local {
+		likedProperty := streams.NewActivityStreamsLikedProperty()
+		likedProperty.SetIRI(u.LikedIRI())
+		user.SetActivityStreamsLiked(likedProperty)
+	}
+
 	name := streams.NewActivityStreamsNameProperty()
 	name.AppendXMLSchemaString(u.DisplayName)
 	user.SetActivityStreamsName(name)
@@ -193,21 +346,56 @@ func (u *User) ToType() vocab.Type {
 	username.SetXMLSchemaString(u.Username)
 	user.SetActivityStreamsPreferredUsername(username)
 
+	if u.Local {
+		if pem, err := u.PublicKeyPEM(); err == nil {
+			user.SetW3IDSecurityV1PublicKey(u.publicKeyProperty(pem))
+		} else {
+			log.Printf("unable to marshal public key for user %s: %s", u.Username, err.Error())
+		}
+	}
+
 	// TODO `followers`, `following`, `url` and `summary`
 
 	return user
 }
 
+// publicKeyProperty returns the `publicKey` of the user's actor, so that other servers can verify what they sign.
+func (u *User) publicKeyProperty(pem string) vocab.W3IDSecurityV1PublicKeyProperty {
+	publicKey := streams.NewW3IDSecurityV1PublicKey()
+
+	if keyID, err := url.Parse(u.PublicKeyID()); err == nil {
+		id := streams.NewJSONLDIdProperty()
+		id.SetIRI(keyID)
+		publicKey.SetJSONLDId(id)
+	}
+
+	owner := streams.NewW3IDSecurityV1OwnerProperty()
+	owner.SetIRI(u.IRI())
+	publicKey.SetW3IDSecurityV1Owner(owner)
+
+	publicKeyPem := streams.NewW3IDSecurityV1PublicKeyPemProperty()
+	publicKeyPem.Set(pem)
+	publicKey.SetW3IDSecurityV1PublicKeyPem(publicKeyPem)
+
+	property := streams.NewW3IDSecurityV1PublicKeyProperty()
+	property.AppendW3IDSecurityV1PublicKey(publicKey)
+	return property
+}
+
 // FollowersToType renders the users' followers as an OrderedCollection. It is returned as
-// the list in reverse order, so that new entries are first.
+// the list in reverse order, so that new entries are first. Pending followers are not included.
 func (u *User) FollowersToType() vocab.Type {
 	followers := streams.NewActivityStreamsOrderedCollection()
 
 	id := streams.NewJSONLDIdProperty()
 	id.SetIRI(u.FollowersIRI())
+	followers.SetJSONLDId(id)
 
 	items := streams.NewActivityStreamsOrderedItemsProperty()
 	for _, follower := range u.Followers {
+		if follower.Pending {
+			continue
+		}
 		log.Println("appending follower", follower.ID)
 		iri, err := url.Parse(follower.ID)
 		if err != nil {
@@ -220,3 +408,28 @@ func (u *User) FollowersToType() vocab.Type {
 
 	return followers
 }
+
+// FollowingToType renders who the user follows as an OrderedCollection, newest first. Only accepted follows are included.
+func (u *User) FollowingToType() vocab.Type {
+	following := streams.NewActivityStreamsOrderedCollection()
+
+	id := streams.NewJSONLDIdProperty()
+	id.SetIRI(u.FollowingIRI())
+	following.SetJSONLDId(id)
+
+	items := streams.NewActivityStreamsOrderedItemsProperty()
+	for _, f := range u.Following {
+		if !f.Accepted {
+			continue
+		}
+		iri, err := url.Parse(f.ID)
+		if err != nil {
+			log.Println("error parsing url for following list:", err.Error())
+			continue
+		}
+		items.PrependIRI(iri)
+	}
+	following.SetActivityStreamsOrderedItems(items)
+
+	return following
+}
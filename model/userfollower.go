@@ -1,12 +1,24 @@
 package model
 
 import (
+	"time"
+
+	"github.com/go-fed/activity/streams/vocab"
 	"github.com/google/uuid"
 )
 
-// A Follower is the IRI of someone who follows a user.
+// A Follower is the IRI of someone who follows a user. Followers of users who manually approve them are pending until the user does so.
 type Follower struct {
-	ID     string `gorm:"primary_key"`
-	User   User   `gorm:"association_autoupdate:false"`
-	UserID uuid.UUID
+	ID        string    `gorm:"primary_key"`
+	User      User      `gorm:"association_autoupdate:false"`
+	UserID    uuid.UUID `gorm:"primary_key"`
+	FollowID  string
+	Pending   bool `gorm:"not null;index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// FollowToType returns the Follow activity the follower sent. The User must be populated.
+func (f *Follower) FollowToType() vocab.ActivityStreamsFollow {
+	return followToType(f.FollowID, f.ID, f.User.IRI().String())
 }
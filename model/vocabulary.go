@@ -0,0 +1,65 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// VocabularyNamespace is the IRI the exlibris extensions to ActivityStreams are defined under.
+	VocabularyNamespace = "https://github.com/exlibris-fed/exlibris/ns#"
+
+	activityStreamsContext = "https://www.w3.org/ns/activitystreams"
+)
+
+// VocabularyContext is the JSON-LD context defining the properties exlibris adds to books, reads and reviews. It's included inline in everything we serve and send, since the namespace isn't a document that can be fetched. Where there is one, the names match the ones BookWyrm uses for the same thing, so that books can be matched up between the two.
+var VocabularyContext = map[string]interface{}{
+	"exlibris":       VocabularyNamespace,
+	"schema":         "http://schema.org#",
+	"isbn10":         "exlibris:isbn10",
+	"isbn13":         "exlibris:isbn13",
+	"openlibraryKey": "exlibris:openlibraryKey",
+	"wikidata":       "exlibris:wikidata",
+	"edition": map[string]interface{}{
+		"@id":   "exlibris:edition",
+		"@type": "@id",
+	},
+	"pages":    "schema:numberOfPages",
+	"subjects": "exlibris:subjects",
+	"cover":    "exlibris:cover",
+	"rating":   "schema:ratingValue",
+	"inReplyToBook": map[string]interface{}{
+		"@id":   "exlibris:inReplyToBook",
+		"@type": "@id",
+	},
+	"sensitive": "as:sensitive",
+}
+
+// WithVocabulary adds VocabularyContext to the @context of a serialized ActivityStreams value, as returned by streams.Serialize, unless it's already there.
+func WithVocabulary(m map[string]interface{}) map[string]interface{} {
+	var context []interface{}
+	switch c := m["@context"].(type) {
+	case nil:
+		context = []interface{}{activityStreamsContext}
+	case []interface{}:
+		context = c
+	default:
+		context = []interface{}{c}
+	}
+	for _, c := range context {
+		if definitions, ok := c.(map[string]interface{}); ok && definitions["exlibris"] == VocabularyNamespace {
+			return m
+		}
+	}
+	m["@context"] = append(context, VocabularyContext)
+	return m
+}
+
+// MarshalWithVocabulary adds VocabularyContext to the @context of an ActivityStreams value which has already been serialized to JSON, such as an activity go-fed is delivering.
+func MarshalWithVocabulary(b []byte) ([]byte, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("error parsing activity: %w", err)
+	}
+	return json.Marshal(WithVocabulary(m))
+}